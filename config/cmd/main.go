@@ -33,10 +33,93 @@ func generaetEmptyConfig() {
 	conf.Log.PanicLogName = "gin_panic.log"
 	conf.JwtSignKeyHex = "7E6F6D6E6B6C6B6A6968676665646362"
 	conf.JwtSecretHex = "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4"
+	conf.JwtKeys.Current = "k1"
+	conf.JwtKeys.Keys = []config.JwtKeyConfig{
+		{ID: "k1", SignKeyHex: "7E6F6D6E6B6C6B6A6968676665646362", SecretHex: "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4"},
+	}
 	conf.ServerPort = "6969"
+	conf.TrustedProxies = []string{}
 	conf.Vedio.BasePath = "./uploads"
 	conf.Vedio.UrlPrefix = "static"
 	conf.Vedio.Domain = "http://192.168.1.105"
+	conf.Redis.Addr = "127.0.0.1:6379"
+	conf.MQ.Favorite.Broker = config.MQBrokerSimple
+	conf.MQ.Favorite.WorkerNum = 10
+	conf.MQ.Favorite.MaxLen = 100000
+	conf.MQ.Favorite.OverflowPolicy = config.MQOverflowBlock
+	conf.MQ.Favorite.BatchSize = 100
+	conf.MQ.Favorite.BatchIntervalMs = 50
+	conf.MQ.Comment.Broker = config.MQBrokerSimple
+	conf.MQ.Comment.WorkerNum = 10
+	conf.MQ.Comment.MaxLen = 100000
+	conf.MQ.Comment.OverflowPolicy = config.MQOverflowBlock
+	conf.MQ.Follow.Broker = config.MQBrokerSimple
+	conf.MQ.Follow.WorkerNum = 10
+	conf.MQ.Follow.MaxLen = 100000
+	conf.MQ.Follow.OverflowPolicy = config.MQOverflowBlock
+	conf.MQ.Follow.BatchSize = 100
+	conf.MQ.Follow.BatchIntervalMs = 50
+	conf.MQ.CommentLike.Broker = config.MQBrokerSimple
+	conf.MQ.CommentLike.WorkerNum = 10
+	conf.MQ.CommentLike.MaxLen = 100000
+	conf.MQ.CommentLike.OverflowPolicy = config.MQOverflowBlock
+	conf.MQ.PushTimeoutMs = 1000
+	conf.MQ.Consumer = "douyin2-1"
+	conf.MQ.ClaimMinIdleSeconds = 60
+	conf.MQ.MaxDeliveries = 10
+	conf.IDGen.WorkerID = 1
+	conf.IDGen.MaxBackwardMs = 10
+	conf.Idempotency.Store = config.IdempotencyStoreMemory
+	conf.Idempotency.TTLSeconds = 86400
+	conf.Moderation.SensitiveWordsFile = "./config/conf/sensitive_words.txt"
+	conf.Moderation.CommentMaxRunes = 500
+	conf.Moderation.CommentMaxLinks = 1
+	conf.Moderation.RateWindowSeconds = 60
+	conf.Moderation.RateMaxCount = 10
+	conf.Moderation.RateMaxDuplicate = 2
+	// 不默认指定审核员，避免第一个注册的用户成为审核员
+	conf.Moderation.ModeratorIDs = []uint{}
+	conf.Token.AccessTTLSeconds = 7200
+	conf.Token.RefreshTTLSeconds = 2592000
+	conf.Token.RevocationStore = config.TokenRevocationStoreMemory
+	conf.Token.DisableQueryToken = false
+	conf.Token.CookieName = "douyin_token"
+	conf.Token.RefreshCookieName = "douyin_refresh_token"
+	conf.Token.CookieSecure = false
+	conf.Token.LegacyTokenCutoff = ""
+	conf.LoginGuard.WindowSeconds = 900
+	conf.LoginGuard.DelayAfter = 3
+	conf.LoginGuard.BaseDelayMillis = 500
+	conf.LoginGuard.MaxDelayMillis = 4000
+	conf.LoginGuard.UserLockThreshold = 10
+	conf.LoginGuard.IPLockThreshold = 50
+	conf.LoginGuard.LockSeconds = 900
+	conf.LoginGuard.Store = config.LoginGuardStoreMemory
+	conf.Password.Algorithm = config.PasswordAlgorithmBcrypt
+	conf.Password.BcryptCost = 10
+	conf.Password.Argon2Time = 2
+	conf.Password.Argon2MemoryKiB = 19456
+	conf.Password.Argon2Threads = 1
+	conf.Password.MinLength = 8
+	conf.Password.MaxLength = 64
+	conf.Password.MinCharClasses = 2
+	conf.Password.BlocklistFile = ""
+	conf.Password.AllowQueryCredentials = false
+	conf.Mail.Driver = config.MailDriverLog
+	conf.Mail.Host = "smtp.example.com"
+	conf.Mail.Port = 587
+	conf.Mail.Username = "noreply@example.com"
+	conf.Mail.Password = "123456"
+	conf.Mail.From = "noreply@example.com"
+	conf.Mail.FromName = "douyin"
+	conf.Account.CodeSecretHex = "6368616e676520746869732070617373776f726420746f206120736563726574"
+	conf.Account.VerifyCodeTTLSeconds = 86400
+	conf.Account.ResetCodeTTLSeconds = 1800
+	conf.Account.SendIntervalSeconds = 60
+	conf.Account.VerifyURL = ""
+	conf.Account.ResetURL = ""
+	conf.Metrics.ListenAddr = "127.0.0.1:9100"
+	conf.Metrics.Token = ""
 	err := saveNewConfig(conf, fileName, "yaml", targetPath)
 	if err != nil {
 		panic(err)
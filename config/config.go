@@ -4,14 +4,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sync"
 
 	"github.com/Doraemonkeys/douyin2/pkg/log"
 	"github.com/Doraemonkeys/douyin2/utils"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
 var allConfig Config
 
+// 保护allConfig.JwtKeys，配置文件修改时会重新加载
+var jwtKeysLock sync.RWMutex
+var watchJwtKeysOnce sync.Once
+
 func init() {
 	file := "config.yaml"
 	basePath := "./config/conf"
@@ -44,14 +51,90 @@ func GetJwtConfig() JwtConfig {
 	return JwtConfig{allConfig.JwtSignKeyHex, allConfig.JwtSecretHex}
 }
 
+// GetJwtKeysConfig 获取jwt密钥环配置，未配置时Keys为空
+func GetJwtKeysConfig() JwtKeysConfig {
+	jwtKeysLock.RLock()
+	defer jwtKeysLock.RUnlock()
+	return allConfig.JwtKeys
+}
+
+// WatchJwtKeys 监听配置文件，jwt密钥环配置变化时调用onChange。只有第一次调用生效。
+func WatchJwtKeys(onChange func(JwtKeysConfig)) {
+	watchJwtKeysOnce.Do(func() {
+		viper.OnConfigChange(func(e fsnotify.Event) {
+			var newConfig Config
+			if err := viper.Unmarshal(&newConfig); err != nil {
+				fmt.Println("重新解析配置文件失败, error:" + err.Error())
+				return
+			}
+			jwtKeysLock.Lock()
+			changed := !reflect.DeepEqual(allConfig.JwtKeys, newConfig.JwtKeys)
+			allConfig.JwtKeys = newConfig.JwtKeys
+			jwtKeysLock.Unlock()
+			if changed {
+				onChange(newConfig.JwtKeys)
+			}
+		})
+		viper.WatchConfig()
+	})
+}
+
 func GetServerPort() string {
 	return allConfig.ServerPort
 }
 
+func GetTrustedProxies() []string {
+	return allConfig.TrustedProxies
+}
+
 func GetVedioConfig() VedioConfig {
 	return allConfig.Vedio
 }
 
+func GetRedisConfig() RedisConfig {
+	return allConfig.Redis
+}
+
+func GetMQConfig() MQConfig {
+	return allConfig.MQ
+}
+
+func GetIDGenConfig() IDGenConfig {
+	return allConfig.IDGen
+}
+
+func GetIdempotencyConfig() IdempotencyConfig {
+	return allConfig.Idempotency
+}
+
+func GetModerationConfig() ModerationConfig {
+	return allConfig.Moderation
+}
+
+func GetTokenConfig() TokenConfig {
+	return allConfig.Token
+}
+
+func GetLoginGuardConfig() LoginGuardConfig {
+	return allConfig.LoginGuard
+}
+
+func GetPasswordConfig() PasswordConfig {
+	return allConfig.Password
+}
+
+func GetMailConfig() MailConfig {
+	return allConfig.Mail
+}
+
+func GetAccountConfig() AccountConfig {
+	return allConfig.Account
+}
+
+func GetMetricsConfig() MetricsConfig {
+	return allConfig.Metrics
+}
+
 func IsDebug() bool {
 	if log.PraseLevel(GetLogConfig().Level) < log.PraseLevel("debug") {
 		return false
@@ -7,10 +7,37 @@ type Config struct {
 	JwtSignKeyHex string `mapstructure:"jwt_sign_key_hex" yaml:"jwt_sign_key_hex"`
 	//jwt加密密钥
 	JwtSecretHex string `mapstructure:"jwt_secret_hex" yaml:"jwt_secret_hex"`
+	//jwt密钥环，配置后代替jwt_sign_key_hex和jwt_secret_hex，修改后无需重启
+	JwtKeys JwtKeysConfig `mapstructure:"jwt_keys" yaml:"jwt_keys"`
 	//服务端口号
 	ServerPort string `mapstructure:"server_port" yaml:"server_port"`
+	//可信的反向代理地址或网段，只有来自这些地址的请求才使用X-Forwarded-For中的客户端IP。
+	//为空时不信任任何代理，客户端IP为连接的对端地址
+	TrustedProxies []string `mapstructure:"trusted_proxies" yaml:"trusted_proxies"`
 	//视频配置
 	Vedio VedioConfig `mapstructure:"vedio" yaml:"vedio"`
+	//redis配置
+	Redis RedisConfig `mapstructure:"redis" yaml:"redis"`
+	//消息队列配置
+	MQ MQConfig `mapstructure:"mq" yaml:"mq"`
+	//ID生成器配置
+	IDGen IDGenConfig `mapstructure:"id_gen" yaml:"id_gen"`
+	//幂等键配置
+	Idempotency IdempotencyConfig `mapstructure:"idempotency" yaml:"idempotency"`
+	//内容审核配置
+	Moderation ModerationConfig `mapstructure:"moderation" yaml:"moderation"`
+	//token有效期及吊销配置
+	Token TokenConfig `mapstructure:"token" yaml:"token"`
+	//登录失败限制配置
+	LoginGuard LoginGuardConfig `mapstructure:"login_guard" yaml:"login_guard"`
+	//密码hash和密码策略配置
+	Password PasswordConfig `mapstructure:"password" yaml:"password"`
+	//邮件配置
+	Mail MailConfig `mapstructure:"mail" yaml:"mail"`
+	//邮箱验证和重置密码配置
+	Account AccountConfig `mapstructure:"account" yaml:"account"`
+	//监控接口配置
+	Metrics MetricsConfig `mapstructure:"metrics" yaml:"metrics"`
 }
 
 type MysqlConfig struct {
@@ -39,6 +66,25 @@ type JwtConfig struct {
 	SecretHex  string `mapstructure:"secret_hex" yaml:"secret_hex"`
 }
 
+type JwtKeysConfig struct {
+	//签发token使用的密钥id
+	Current string `mapstructure:"current" yaml:"current"`
+	//所有有效的密钥，包括当前密钥和仍需验证的旧密钥。
+	//从jwt_sign_key_hex迁移时应保留原密钥，其签发的token没有密钥id，会依次尝试所有密钥
+	Keys []JwtKeyConfig `mapstructure:"keys" yaml:"keys"`
+	//接受旧版本AES-CBC加密的token，默认不接受。
+	//AES-CBC加密的token都在引入token类型之前签发，需同时配置token.legacy_token_cutoff，在此之前视为access token
+	AcceptCBC bool `mapstructure:"accept_cbc" yaml:"accept_cbc"`
+}
+
+type JwtKeyConfig struct {
+	//密钥id，不能包含"."
+	ID         string `mapstructure:"id" yaml:"id"`
+	SignKeyHex string `mapstructure:"sign_key_hex" yaml:"sign_key_hex"`
+	//AES-GCM加密密钥(16、24或32字节)，为空时不加密token
+	SecretHex string `mapstructure:"secret_hex" yaml:"secret_hex"`
+}
+
 type VedioConfig struct {
 	//视频存储的根目录
 	BasePath string `mapstructure:"base_path" yaml:"base_path"`
@@ -47,3 +93,226 @@ type VedioConfig struct {
 	// e.g. http://localhost:8080
 	Domain string `mapstructure:"domain" yaml:"domain"`
 }
+
+type RedisConfig struct {
+	// e.g. 127.0.0.1:6379
+	Addr     string `mapstructure:"addr" yaml:"addr"`
+	Password string `mapstructure:"password" yaml:"password"`
+	DB       int    `mapstructure:"db" yaml:"db"`
+}
+
+// 每个消息队列可以单独选择消息代理
+type MQConfig struct {
+	Favorite MQItemConfig `mapstructure:"favorite" yaml:"favorite"`
+	Comment  MQItemConfig `mapstructure:"comment" yaml:"comment"`
+	Follow   MQItemConfig `mapstructure:"follow" yaml:"follow"`
+	//评论点赞
+	CommentLike MQItemConfig `mapstructure:"comment_like" yaml:"comment_like"`
+	//队列已满(block策略)时请求最多等待的时间(毫秒)，超时后响应服务繁忙。为0时使用默认值(1000)
+	PushTimeoutMs int `mapstructure:"push_timeout_ms" yaml:"push_timeout_ms"`
+	//Redis Stream消费者名称，多个服务副本必须配置不同的值，重启后应保持不变。为空时使用主机名
+	Consumer string `mapstructure:"consumer" yaml:"consumer"`
+	//Redis Stream中未确认的消息空闲多少秒后被重新认领，为0时使用默认值(60)
+	ClaimMinIdleSeconds int `mapstructure:"claim_min_idle_seconds" yaml:"claim_min_idle_seconds"`
+	//Redis Stream中一条消息的最大投递次数，超过后丢弃，为0时使用默认值(10)
+	MaxDeliveries int `mapstructure:"max_deliveries" yaml:"max_deliveries"`
+}
+
+const (
+	// 进程内的SimpleMQ(默认)
+	MQBrokerSimple = "simple"
+	// Redis Stream，可在多个服务副本间分摊消息
+	MQBrokerRedis = "redis"
+)
+
+type MQItemConfig struct {
+	//simple,redis
+	Broker string `mapstructure:"broker" yaml:"broker"`
+	//worker数量，为0时使用默认值
+	WorkerNum int `mapstructure:"worker_num" yaml:"worker_num"`
+	//Broker为redis时的Stream名称，为空时使用默认值
+	Stream string `mapstructure:"stream" yaml:"stream"`
+	//批量处理的消息数，大于1时开启批量模式(仅对simple生效，redis时不能配置，且需要消息队列支持批量处理)
+	BatchSize int `mapstructure:"batch_size" yaml:"batch_size"`
+	//批量模式下最长等待时间(毫秒)，到时间后即使不足BatchSize也会处理
+	BatchIntervalMs int `mapstructure:"batch_interval_ms" yaml:"batch_interval_ms"`
+	//队列最大长度，0表示不限制(仅对simple生效，redis时不能配置)
+	MaxLen int `mapstructure:"max_len" yaml:"max_len"`
+	//队列已满时的处理策略: block,reject,drop_oldest。默认block(仅对simple生效)
+	OverflowPolicy string `mapstructure:"overflow_policy" yaml:"overflow_policy"`
+	//Broker为redis时需开启，确认同一key的消息(如同一用户对同一视频的点赞和取消点赞)可能不按顺序处理
+	Unordered bool `mapstructure:"unordered" yaml:"unordered"`
+}
+
+const (
+	MQOverflowBlock      = "block"
+	MQOverflowReject     = "reject"
+	MQOverflowDropOldest = "drop_oldest"
+)
+
+type IDGenConfig struct {
+	//机器ID(0-31)，多个服务副本必须配置不同的值
+	WorkerID int64 `mapstructure:"worker_id" yaml:"worker_id"`
+	//可容忍的时钟回拨时间(毫秒)，为0时使用默认值
+	MaxBackwardMs int `mapstructure:"max_backward_ms" yaml:"max_backward_ms"`
+}
+
+type IdempotencyConfig struct {
+	//memory,redis。多个服务副本时应使用redis
+	Store string `mapstructure:"store" yaml:"store"`
+	//幂等键保存时间(秒)，为0时使用默认值
+	TTLSeconds int `mapstructure:"ttl_seconds" yaml:"ttl_seconds"`
+}
+
+const (
+	// 进程内存储(默认)
+	IdempotencyStoreMemory = "memory"
+	// Redis存储，多个服务副本共享
+	IdempotencyStoreRedis = "redis"
+)
+
+type ModerationConfig struct {
+	//敏感词词典文件，每行一个敏感词，可用"敏感词,级别"指定级别(mask,hold,reject)，为空时不过滤敏感词
+	SensitiveWordsFile string `mapstructure:"sensitive_words_file" yaml:"sensitive_words_file"`
+	//评论最大字符数，为0时使用默认值
+	CommentMaxRunes int `mapstructure:"comment_max_runes" yaml:"comment_max_runes"`
+	//评论中允许的最大链接数，超过时拒绝
+	CommentMaxLinks int `mapstructure:"comment_max_links" yaml:"comment_max_links"`
+	//发评频率的统计窗口(秒)，为0时不限制频率
+	RateWindowSeconds int `mapstructure:"rate_window_seconds" yaml:"rate_window_seconds"`
+	//窗口内每个用户最多发表的评论数，超过时等待人工审核
+	RateMaxCount int `mapstructure:"rate_max_count" yaml:"rate_max_count"`
+	//窗口内每个用户最多重复发表相同内容的次数，超过时等待人工审核
+	RateMaxDuplicate int `mapstructure:"rate_max_duplicate" yaml:"rate_max_duplicate"`
+	//可以人工审核评论的用户id，为空时没有人可以审核，等待审核的评论不会公开。
+	//默认为空，部署后在配置文件中填写审核员的用户id，如 moderator_ids: [1, 2]，重启后生效
+	ModeratorIDs []uint `mapstructure:"moderator_ids" yaml:"moderator_ids"`
+}
+
+type TokenConfig struct {
+	//access token有效期(秒)，为0时使用默认值
+	AccessTTLSeconds int `mapstructure:"access_ttl_seconds" yaml:"access_ttl_seconds"`
+	//refresh token有效期(秒)，为0时使用默认值
+	RefreshTTLSeconds int `mapstructure:"refresh_ttl_seconds" yaml:"refresh_ttl_seconds"`
+	//token吊销列表的存储: memory,redis。多个服务副本时应使用redis
+	RevocationStore string `mapstructure:"revocation_store" yaml:"revocation_store"`
+	//不从URL查询参数中读取token，避免token出现在访问日志和代理缓存中
+	DisableQueryToken bool `mapstructure:"disable_query_token" yaml:"disable_query_token"`
+	//保存access token的cookie名称，为空时不使用cookie
+	CookieName string `mapstructure:"cookie_name" yaml:"cookie_name"`
+	//保存refresh token的cookie名称，为空时不使用cookie。该cookie只发送给刷新token和退出登录的接口
+	RefreshCookieName string `mapstructure:"refresh_cookie_name" yaml:"refresh_cookie_name"`
+	//cookie只通过HTTPS发送
+	CookieSecure bool `mapstructure:"cookie_secure" yaml:"cookie_secure"`
+	//旧版本签发的没有token类型的token在此时间(RFC3339格式，如2026-12-01T00:00:00+08:00)之前视为access token。
+	//这些token没有有效期也无法单独吊销，为空时不接受，升级后持有旧token的用户需要重新登录
+	LegacyTokenCutoff string `mapstructure:"legacy_token_cutoff" yaml:"legacy_token_cutoff"`
+}
+
+const (
+	// 进程内存储(默认)
+	TokenRevocationStoreMemory = "memory"
+	// Redis存储，多个服务副本共享
+	TokenRevocationStoreRedis = "redis"
+)
+
+// 为0的字段使用默认值
+type LoginGuardConfig struct {
+	//统计失败次数的滑动窗口(秒)
+	WindowSeconds int `mapstructure:"window_seconds" yaml:"window_seconds"`
+	//同一用户名失败次数超过该值后开始延迟响应
+	DelayAfter int `mapstructure:"delay_after" yaml:"delay_after"`
+	//第一次延迟的时间(毫秒)，之后每次失败翻倍
+	BaseDelayMillis int `mapstructure:"base_delay_millis" yaml:"base_delay_millis"`
+	//延迟的上限(毫秒)
+	MaxDelayMillis int `mapstructure:"max_delay_millis" yaml:"max_delay_millis"`
+	//窗口内同一用户名失败该次数后锁定
+	UserLockThreshold int `mapstructure:"user_lock_threshold" yaml:"user_lock_threshold"`
+	//窗口内同一IP失败该次数后锁定
+	IPLockThreshold int `mapstructure:"ip_lock_threshold" yaml:"ip_lock_threshold"`
+	//锁定时长(秒)
+	LockSeconds int `mapstructure:"lock_seconds" yaml:"lock_seconds"`
+	//失败记录的存储: memory,redis。多个服务副本时应使用redis
+	Store string `mapstructure:"store" yaml:"store"`
+}
+
+const (
+	// 进程内存储(默认)
+	LoginGuardStoreMemory = "memory"
+	// Redis存储，多个服务副本共享
+	LoginGuardStoreRedis = "redis"
+)
+
+// 为0的字段使用默认值
+type PasswordConfig struct {
+	//密码hash算法: bcrypt,argon2id，为空时使用bcrypt。修改后旧密码在用户下次登录时重新计算hash
+	Algorithm string `mapstructure:"algorithm" yaml:"algorithm"`
+	//bcrypt的cost(4-31)
+	BcryptCost int `mapstructure:"bcrypt_cost" yaml:"bcrypt_cost"`
+	//argon2id的迭代次数
+	Argon2Time uint32 `mapstructure:"argon2_time" yaml:"argon2_time"`
+	//argon2id使用的内存(KiB)
+	Argon2MemoryKiB uint32 `mapstructure:"argon2_memory_kib" yaml:"argon2_memory_kib"`
+	//argon2id的并行度
+	Argon2Threads uint8 `mapstructure:"argon2_threads" yaml:"argon2_threads"`
+	//密码最少字符数
+	MinLength int `mapstructure:"min_length" yaml:"min_length"`
+	//密码最多字符数
+	MaxLength int `mapstructure:"max_length" yaml:"max_length"`
+	//密码至少包含的字符种类数(小写字母、大写字母、数字、其他符号)
+	MinCharClasses int `mapstructure:"min_char_classes" yaml:"min_char_classes"`
+	//常见密码黑名单文件，每行一个，在内置黑名单之外额外禁止，为空时只使用内置黑名单
+	BlocklistFile string `mapstructure:"blocklist_file" yaml:"blocklist_file"`
+	//兼容旧客户端，允许登录和注册从URL查询参数中读取用户名和密码，密码会出现在访问日志中
+	AllowQueryCredentials bool `mapstructure:"allow_query_credentials" yaml:"allow_query_credentials"`
+}
+
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+type MailConfig struct {
+	//发送方式: smtp,log。为空时只将邮件输出到日志
+	Driver string `mapstructure:"driver" yaml:"driver"`
+	//SMTP服务器，服务器支持时使用STARTTLS
+	Host string `mapstructure:"host" yaml:"host"`
+	Port int    `mapstructure:"port" yaml:"port"`
+	//为空时不认证
+	Username string `mapstructure:"username" yaml:"username"`
+	Password string `mapstructure:"password" yaml:"password"`
+	//发件人地址
+	From string `mapstructure:"from" yaml:"from"`
+	//发件人名称
+	FromName string `mapstructure:"from_name" yaml:"from_name"`
+}
+
+const (
+	// 只输出到日志(默认)，用于开发环境
+	MailDriverLog = "log"
+	// 通过SMTP服务器发送
+	MailDriverSMTP = "smtp"
+)
+
+// 为0的字段使用默认值
+type AccountConfig struct {
+	//验证码的签名密钥(十六进制，至少32字节)，为空时每次启动随机生成，重启后未使用的验证码失效
+	CodeSecretHex string `mapstructure:"code_secret_hex" yaml:"code_secret_hex"`
+	//邮箱验证码有效期(秒)
+	VerifyCodeTTLSeconds int `mapstructure:"verify_code_ttl_seconds" yaml:"verify_code_ttl_seconds"`
+	//重置密码验证码有效期(秒)
+	ResetCodeTTLSeconds int `mapstructure:"reset_code_ttl_seconds" yaml:"reset_code_ttl_seconds"`
+	//向同一用户或邮箱发送邮件的最小间隔(秒)
+	SendIntervalSeconds int `mapstructure:"send_interval_seconds" yaml:"send_interval_seconds"`
+	//邮件中验证邮箱的链接，%s替换为验证码，为空时邮件中只包含验证码
+	VerifyURL string `mapstructure:"verify_url" yaml:"verify_url"`
+	//邮件中重置密码的链接，%s替换为验证码，为空时邮件中只包含验证码
+	ResetURL string `mapstructure:"reset_url" yaml:"reset_url"`
+}
+
+type MetricsConfig struct {
+	//监控接口(/metrics)单独的监听地址，不对外暴露，如127.0.0.1:9100。为空时不提供监控接口
+	ListenAddr string `mapstructure:"listen_addr" yaml:"listen_addr"`
+	//访问监控接口需要携带的Bearer token，为空时不校验
+	Token string `mapstructure:"token" yaml:"token"`
+}
@@ -1,9 +1,17 @@
 package initiate
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/database"
 	"github.com/Doraemonkeys/douyin2/internal/msgQueue"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idgen"
 	"github.com/Doraemonkeys/douyin2/internal/server"
 	"github.com/Doraemonkeys/douyin2/pkg/log"
 	"github.com/gin-gonic/gin"
@@ -13,6 +21,7 @@ import (
 func Run() {
 	initGlobalLogger()
 	logrus.Info("hello world")
+	initIDGen()
 	initMysql()
 	initVideoStorageServer()
 
@@ -22,16 +31,32 @@ func Run() {
 	database.InitVideoCommentCacher(cacheSize)
 	database.InitUserCacher(cacheSize)
 	database.InitUserFavoriteCacher(cacheSize)
+	database.InitIdempotencyStore()
+	database.InitTokenRevocationList()
+	database.InitLoginGuard()
+	database.InitAccountLists()
+
+	// init content moderation
+	services.InitModeration()
+	services.InitPassword()
+	services.InitAccount()
 
 	// init message queue
 	msgQueue.InitFavoriteMQ()
 	msgQueue.InitCommentMQ()
 	msgQueue.InitFollowMQ()
+	msgQueue.InitCommentLikeMQ()
 
 	// main logic
-	runDouyinServer()
+	douyinServer := server.NewDouyinServer()
+	go runDouyinServer(douyinServer)
+
+	waitForShutdown(douyinServer)
 }
 
+// 优雅退出的最长等待时间
+const shutdownTimeout = 15 * time.Second
+
 func initGlobalLogger() {
 	logConfig := config.GetGlobalLoggerConfig()
 	err := log.InitGlobalLogger(logConfig)
@@ -45,6 +70,28 @@ func initGlobalLogger() {
 	}
 }
 
+// BackfillCommentCount 根据评论表重新计算视频和用户的评论数
+func BackfillCommentCount() {
+	initGlobalLogger()
+	initMysql()
+	videoNum, userNum, err := services.BackfillCommentCounts()
+	if err != nil {
+		logrus.Error("回填评论数失败, error: ", err)
+		return
+	}
+	logrus.Infof("回填评论数完成, 修正视频数: %d, 修正用户数: %d", videoNum, userNum)
+	database.CloseMysqlDB()
+}
+
+func initIDGen() {
+	idGenConfig := config.GetIDGenConfig()
+	maxBackward := time.Duration(idGenConfig.MaxBackwardMs) * time.Millisecond
+	err := idgen.Init(idGenConfig.WorkerID, maxBackward)
+	if err != nil {
+		panic("初始化ID生成器失败, error:" + err.Error())
+	}
+}
+
 func initMysql() {
 	database.GetMysqlDB()
 }
@@ -53,10 +100,37 @@ func initVideoStorageServer() {
 	database.GetVideoSaver()
 }
 
-func runDouyinServer() {
-	douyinServer := server.NewDouyinServer()
+func runDouyinServer(douyinServer *server.DouyinServer) {
 	err := douyinServer.Run(":" + config.GetServerPort())
 	if err != nil {
 		panic("启动服务失败, error:" + err.Error())
 	}
 }
+
+// waitForShutdown 等待退出信号，依次停止HTTP服务、排空消息队列、关闭MySQL和Redis
+func waitForShutdown(douyinServer *server.DouyinServer) {
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logrus.Info("收到退出信号: ", sig, ", 开始关闭服务")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 1. 停止HTTP服务，不再产生新的消息
+	if err := douyinServer.Shutdown(ctx); err != nil {
+		logrus.Error("关闭HTTP服务失败: ", err)
+	}
+	// 2. 排空消息队列
+	if err := msgQueue.CloseAllMQ(ctx); err != nil {
+		logrus.Error("关闭消息队列失败: ", err)
+	}
+	// 3. 关闭MySQL和Redis
+	if err := database.CloseMysqlDB(); err != nil {
+		logrus.Error("关闭MySQL失败: ", err)
+	}
+	if err := database.CloseRedisClient(); err != nil {
+		logrus.Error("关闭Redis失败: ", err)
+	}
+	logrus.Info("服务已关闭")
+}
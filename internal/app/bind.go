@@ -0,0 +1,28 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// BindBody 根据Content-Type从JSON或表单请求体中读取参数，表单不读取查询参数
+func BindBody(c *gin.Context, obj any) error {
+	switch c.ContentType() {
+	case binding.MIMEJSON:
+		return c.ShouldBindWith(obj, binding.JSON)
+	case binding.MIMEMultipartPOSTForm:
+		return c.ShouldBindWith(obj, binding.FormMultipart)
+	default:
+		return c.ShouldBindWith(obj, binding.FormPost)
+	}
+}
+
+// BindCredentials 从JSON或表单请求体中读取登录和注册的用户名和密码。
+// allowQuery为true时，请求体中没有时兼容旧客户端从URL查询参数中读取。
+func BindCredentials(c *gin.Context, obj any, allowQuery bool) error {
+	err := BindBody(c, obj)
+	if err != nil && allowQuery {
+		return c.ShouldBindQuery(obj)
+	}
+	return err
+}
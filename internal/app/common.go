@@ -15,6 +15,9 @@ type User struct {
 
 const UserKeyName = "user"
 
+// 请求的幂等键(已按用户隔离)，由幂等中间件设置
+const IdempotencyKeyName = "idempotency_key"
+
 func ZeroCheck[T comparable](v ...T) bool {
 	if !config.IsDebug() {
 		return false
@@ -1,15 +1,19 @@
 package comment
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/enqueue"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
-	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idgen"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/moderation"
 	"github.com/sirupsen/logrus"
 
 	"github.com/Doraemonkeys/douyin2/internal/msgQueue"
@@ -24,6 +28,8 @@ type PostCommentDTO struct {
 	CommentText string `json:"comment_text"`
 	//要删除的评论id，在action_type=2的时候使用
 	CommentId string `json:"comment_id"`
+	//要回复的评论id，在action_type=3的时候使用
+	ParentID string `json:"parent_id"`
 }
 
 const (
@@ -31,11 +37,13 @@ const (
 	PostCommentDTO_ActionType  = "action_type"
 	PostCommentDTO_CommentId   = "comment_id"
 	PostCommentDTO_CommentText = "comment_text"
+	PostCommentDTO_ParentID    = "parent_id"
 )
 
 const (
 	PostCommentDTO_ActionType_Add    = "1"
 	PostCommentDTO_ActionType_Delete = "2"
+	PostCommentDTO_ActionType_Reply  = "3"
 )
 
 const (
@@ -50,6 +58,8 @@ func (dto *PostCommentDTO) newMsg(commenterID uint) msgQueue.CommentMsg {
 	Msg.VideoID = uint(uintID)
 	uintCommentID, _ := strconv.ParseUint(dto.CommentId, 10, 64)
 	Msg.CommentId = uint(uintCommentID)
+	uintParentID, _ := strconv.ParseUint(dto.ParentID, 10, 64)
+	Msg.ParentID = uint(uintParentID)
 	Msg.ActionType = dto.ActionType
 	Msg.CommentText = dto.CommentText
 
@@ -59,12 +69,54 @@ func (dto *PostCommentDTO) newMsg(commenterID uint) msgQueue.CommentMsg {
 
 func PostCommentHandler(c *gin.Context) {
 	var dto PostCommentDTO
-	dto.getAndCheckPostCommentDTO(c)
+	if !dto.getAndCheckPostCommentDTO(c) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
 	mq := msgQueue.GetCommentMQ()
 	user := c.MustGet(app.UserKeyName).(app.User)
 	var Msg msgQueue.CommentMsg = dto.newMsg(user.ID)
-	mq.Push(Msg)
-	if dto.ActionType != PostCommentDTO_ActionType_Add {
+	Msg.IdempotencyKey = c.GetString(app.IdempotencyKeyName)
+	var err error
+	isAdd := dto.ActionType == PostCommentDTO_ActionType_Add || dto.ActionType == PostCommentDTO_ActionType_Reply
+	if isAdd {
+		disabled, err := services.QueryCommentsDisabled(Msg.VideoID)
+		if err != nil && err.Error() == response.ErrVideoNotExists {
+			response.ResponseError(c, response.ErrVideoNotExists)
+			return
+		}
+		if err != nil {
+			logrus.Error("PostCommentHandler:QueryCommentsDisabled: ", err)
+			response.ResponseError(c, response.ErrServerInternal)
+			return
+		}
+		if disabled {
+			response.ResponseError(c, services.ErrCommentsDisabled)
+			return
+		}
+		// 入队前审核，被拒绝的评论直接告知客户端，等待人工审核的评论在响应中标明
+		res := services.ModerateComment(user.ID, dto.CommentText)
+		switch res.Action {
+		case moderation.Reject:
+			response.ResponseError(c, response.ErrCommentRejected+res.Reason)
+			return
+		case moderation.Hold:
+			logrus.Info("评论等待人工审核：", res.Reason, " commenter_id:", user.ID)
+			Msg.Held = true
+		}
+		Msg.CommentText = res.Text
+		// 入队前分配评论id，返回给客户端的即为最终的id
+		Msg.CommentId, err = idgen.NextID()
+		if err != nil {
+			logrus.Error("PostCommentHandler:NextID: ", err)
+			response.ResponseError(c, response.ErrServerInternal)
+			return
+		}
+	}
+	if !enqueue.Push(c, mq, Msg, msgQueue.PushTimeout()) {
+		return
+	}
+	if !isAdd {
 		response.ResponseSuccess(c, SuccComment)
 		return
 	}
@@ -75,98 +127,128 @@ func PostCommentHandler(c *gin.Context) {
 		return
 	}
 	var commentRes response.Comment
-	//评论在消息队列中处理，这里先返回0
-	commentRes.ID = 0
-	commentRes.Content = dto.CommentText
+	//评论在消息队列中写入，id已预先分配
+	commentRes.ID = int(Msg.CommentId)
+	commentRes.ParentID = int(Msg.ParentID)
+	commentRes.Content = Msg.CommentText
+	commentRes.PendingReview = Msg.Held
 	commentRes.CreateDate = time.Now().Format(response.CommentResFormat)
 	commentRes.User.SetValue(commenterInfo, services.QueryUserFollowed(user.ID, user.ID))
 	var res response.PostCommentResponse
 	res.Comment = commentRes
 	res.StatusCode = response.Success
 	res.StatusMsg = SuccComment
+	if Msg.Held {
+		res.StatusMsg = response.MsgCommentHeld
+	}
 	c.JSON(http.StatusOK, res)
 }
 
-func (dto *PostCommentDTO) getAndCheckPostCommentDTO(c *gin.Context) {
+// getAndCheckPostCommentDTO 读取并检查参数，参数不合法时返回false
+func (dto *PostCommentDTO) getAndCheckPostCommentDTO(c *gin.Context) bool {
 	var exist1 bool
 	var exist2 bool
 	dto.VideoID, exist1 = c.GetQuery(PostCommentDTO_VideoID)
 	dto.ActionType, exist2 = c.GetQuery(PostCommentDTO_ActionType)
-	if !exist1 || !exist2 {
-		response.ResponseError(c, response.ErrInvalidParams)
+	if !exist1 || !exist2 || !isValidID(dto.VideoID) {
+		return false
 	}
 	var exist bool
-	if dto.ActionType == PostCommentDTO_ActionType_Add {
+	switch dto.ActionType {
+	case PostCommentDTO_ActionType_Add:
+		dto.CommentText, exist = c.GetQuery(PostCommentDTO_CommentText)
+		return exist
+	case PostCommentDTO_ActionType_Reply:
 		dto.CommentText, exist = c.GetQuery(PostCommentDTO_CommentText)
 		if !exist {
-			response.ResponseError(c, response.ErrInvalidParams)
-			return
+			return false
 		}
-	} else if dto.ActionType == PostCommentDTO_ActionType_Delete {
+		dto.ParentID, exist = c.GetQuery(PostCommentDTO_ParentID)
+		return exist && isValidID(dto.ParentID)
+	case PostCommentDTO_ActionType_Delete:
 		dto.CommentId, exist = c.GetQuery(PostCommentDTO_CommentId)
-		if !exist {
-			response.ResponseError(c, response.ErrInvalidParams)
-			return
-		}
-	} else {
-		response.ResponseError(c, response.ErrInvalidParams)
-		return
+		return exist && isValidID(dto.CommentId)
+	default:
+		return false
 	}
 }
 
+// isValidID id是否为正整数
+func isValidID(id string) bool {
+	n, err := strconv.ParseUint(id, 10, 64)
+	return err == nil && n != 0
+}
+
 type QueryCommentListDTO struct {
 	VideoID uint   `json:"video_id"`
 	Token   string `json:"token"`
+	// 排序方式：new-最新(默认)，hot-最热
+	Order string `json:"order"`
+	// 上一页返回的next_cursor，为空时从第一页开始
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+
+	order models.CommentOrder
+	after *models.CommentCursor
 }
 
 const (
 	QueryCommentListDTO_VideoID = "video_id"
+	QueryCommentListDTO_Order   = "order"
+	QueryCommentListDTO_Cursor  = "cursor"
+	QueryCommentListDTO_Limit   = "limit"
+)
+
+const (
+	QueryCommentListDTO_Order_New = "new"
+	QueryCommentListDTO_Order_Hot = "hot"
+)
+
+const (
+	defaultCommentPageSize = 20
+	maxCommentPageSize     = 50
 )
 
 const CommentResFormat = "2006-01-02 15:04:05"
 
 func QueryCommentListHandler(c *gin.Context) {
 	var dto QueryCommentListDTO
-	dto.getAndCheckQueryCommentListDTO(c)
-
-	// get comments from cache
-	commentCache := database.GetVideoCommentCacher()
-	commentsCache, exist := commentCache.Get(dto.VideoID)
-	if exist {
-		queryCommentListHandler_CacheHit(c, commentsCache)
+	if !dto.getAndCheckQueryCommentListDTO(c) {
+		response.ResponseError(c, response.ErrInvalidParams)
 		return
 	}
-	// get comments from db
-	queryCommentListHandler_CacheMiss(c, dto)
-}
-
-func queryCommentListHandler_CacheHit(c *gin.Context, commentsCache models.CommentCacheModel) {
 	var res response.QueryCommentListResponse
-	var CommentList = make([]response.CommentList, len(commentsCache.CacheMap))
-	var commenterIDMap = make(map[uint]struct{}, len(commentsCache.CacheMap))
-	commentsCache.MapLock.RLock()
-	var i = 0
-	for _, comment := range commentsCache.CacheMap {
-		CommentList[i].ID = int(comment.ID)
-		CommentList[i].Content = comment.Content
-		date := comment.CreatedAt.Format(CommentResFormat)
-		CommentList[i].CreateDate = date
-		if comment.Commenter.ID == 0 {
+	pinned, comments, hasMore, err := services.QueryCommentPage(dto.VideoID, dto.order, dto.after, dto.Limit)
+	if err != nil && err.Error() == services.ErrCommentsDisabled {
+		res.CommentList = []response.CommentList{}
+		res.CommentsDisabled = true
+		res.StatusCode = response.Success
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err != nil {
+		logrus.Error("QueryCommentListHandler: services.QueryCommentPage error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	// 置顶评论排在第一页最前面，下一页的游标仍由非置顶评论生成
+	list := comments
+	if pinned != nil {
+		list = append([]models.CommentModel{*pinned}, comments...)
+	}
+	commenterIDMap := make(map[uint]struct{}, len(list))
+	for _, comment := range list {
+		if comment.UserID == 0 {
 			logrus.Error("commenterID is 0, comment: ", comment.Content, " commentID: ", comment.ID)
 		}
-		CommentList[i].User.ID = int(comment.UserID)
 		commenterIDMap[comment.UserID] = struct{}{}
-		i++
 	}
-	commentsCache.MapLock.RUnlock()
-
 	commenterMap, err := services.GetUserMapByUserIdMap(commenterIDMap)
 	if err != nil {
 		logrus.Error("QueryCommentListHandler: services.GetUserMapByUserIdMap error: ", err)
 		response.ResponseError(c, response.ErrServerInternal)
 		return
 	}
-	//logrus.Debug("QueryCommentListHandler: commenterMap: ", commenterMap)
 	queryer := c.MustGet(app.UserKeyName).(app.User)
 	followedMap, err := services.QueryFollowedMapByUserIDMap(queryer.ID, commenterIDMap)
 	if err != nil {
@@ -174,49 +256,28 @@ func queryCommentListHandler_CacheHit(c *gin.Context, commentsCache models.Comme
 		response.ResponseError(c, response.ErrServerInternal)
 		return
 	}
-	var temp response.User
-	for k, comment := range CommentList {
-		temp.SetValue(commenterMap[uint(comment.User.ID)], followedMap[uint(comment.User.ID)])
-		CommentList[k].User = temp
+	res.CommentList = make([]response.CommentList, len(list))
+	for k, comment := range list {
+		res.CommentList[k].SetCommentValue(comment)
+		res.CommentList[k].User.SetValue(commenterMap[comment.UserID], followedMap[comment.UserID])
 	}
-	res.CommentList = CommentList
-	res.StatusCode = response.Success
-	for _, val := range res.CommentList {
-		app.ZeroCheck(val.ID, val.User.ID)
+	if pinned != nil {
+		res.CommentList[0].IsPinned = true
 	}
-	c.JSON(http.StatusOK, res)
-}
-
-func queryCommentListHandler_CacheMiss(c *gin.Context, dto QueryCommentListDTO) {
-	var res response.QueryCommentListResponse
-	comments, err := services.QueryCommentListWithCommenterByVideoID(dto.VideoID)
-	if err != nil {
-		logrus.Error("QueryCommentListHandler: services.QueryCommentListWithCommenterByVideoID error: ", err)
+	if err := setCommentLiked(queryer.ID, res.CommentList); err != nil {
+		logrus.Error("QueryCommentListHandler: setCommentLiked error: ", err)
 		response.ResponseError(c, response.ErrServerInternal)
 		return
 	}
-	commenterIDMap := make(map[uint]struct{}, len(comments))
-	for _, comment := range comments {
-		commenterIDMap[comment.UserID] = struct{}{}
-	}
-	queryer := c.MustGet(app.UserKeyName).(app.User)
-	followedMap, err := services.QueryFollowedMapByUserIDMap(queryer.ID, commenterIDMap)
-	if err != nil {
-		logrus.Error("QueryCommentListHandler: services.QueryFollowedMapByUserIDMap error: ", err)
+	if err := services.SetCommentSpans(res.CommentList); err != nil {
+		logrus.Error("QueryCommentListHandler: services.SetCommentSpans error: ", err)
 		response.ResponseError(c, response.ErrServerInternal)
 		return
 	}
-	var CommentList = make([]response.CommentList, len(comments))
-	var temp response.User
-	for k, comment := range comments {
-		CommentList[k].ID = int(comment.ID)
-		CommentList[k].Content = comment.Content
-		date := comment.CreatedAt.Format(CommentResFormat)
-		CommentList[k].CreateDate = date
-		temp.SetValue(comment.Commenter, followedMap[comment.UserID])
-		CommentList[k].User = temp
-	}
-	res.CommentList = CommentList
+	res.HasMore = hasMore
+	if hasMore {
+		res.NextCursor = encodeCommentCursor(models.NewCommentCursor(comments[len(comments)-1]))
+	}
 	res.StatusCode = response.Success
 	for _, val := range res.CommentList {
 		app.ZeroCheck(val.ID, val.User.ID)
@@ -224,15 +285,78 @@ func queryCommentListHandler_CacheMiss(c *gin.Context, dto QueryCommentListDTO)
 	c.JSON(http.StatusOK, res)
 }
 
-func (dto *QueryCommentListDTO) getAndCheckQueryCommentListDTO(c *gin.Context) {
+func (dto *QueryCommentListDTO) getAndCheckQueryCommentListDTO(c *gin.Context) bool {
 	strVideoID, exist := c.GetQuery(QueryCommentListDTO_VideoID)
 	if !exist {
-		response.ResponseError(c, response.ErrInvalidParams)
+		return false
 	}
 	uintVideoID, err := strconv.ParseUint(strVideoID, 10, 64)
 	if err != nil {
 		logrus.Error("QueryCommentListHandler: strconv.ParseUint error: ", err)
-		response.ResponseError(c, response.ErrInvalidParams)
+		return false
 	}
 	dto.VideoID = uint(uintVideoID)
+
+	dto.Order = c.Query(QueryCommentListDTO_Order)
+	switch dto.Order {
+	case "", QueryCommentListDTO_Order_New:
+		dto.order = models.CommentOrderNewest
+	case QueryCommentListDTO_Order_Hot:
+		dto.order = models.CommentOrderHot
+	default:
+		return false
+	}
+
+	dto.Cursor = c.Query(QueryCommentListDTO_Cursor)
+	if dto.Cursor != "" {
+		cursor, err := decodeCommentCursor(dto.Cursor)
+		if err != nil {
+			return false
+		}
+		dto.after = &cursor
+	}
+
+	dto.Limit = defaultCommentPageSize
+	if limit := c.Query(QueryCommentListDTO_Limit); limit != "" {
+		dto.Limit, err = strconv.Atoi(limit)
+		if err != nil || dto.Limit < 1 {
+			return false
+		}
+	}
+	if dto.Limit > maxCommentPageSize {
+		dto.Limit = maxCommentPageSize
+	}
+	return true
+}
+
+// 分页游标，对客户端不透明
+type commentCursorJSON struct {
+	LikeCount uint  `json:"l,omitempty"`
+	CreatedAt int64 `json:"t"`
+	ID        uint  `json:"i"`
+}
+
+func encodeCommentCursor(cursor models.CommentCursor) string {
+	data, _ := json.Marshal(commentCursorJSON{
+		LikeCount: cursor.LikeCount,
+		CreatedAt: cursor.CreatedAt.UnixNano(),
+		ID:        cursor.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCommentCursor(s string) (models.CommentCursor, error) {
+	var cursor commentCursorJSON
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.CommentCursor{}, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return models.CommentCursor{}, err
+	}
+	return models.CommentCursor{
+		LikeCount: cursor.LikeCount,
+		CreatedAt: time.Unix(0, cursor.CreatedAt),
+		ID:        cursor.ID,
+	}, nil
 }
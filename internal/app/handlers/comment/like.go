@@ -0,0 +1,76 @@
+package comment
+
+import (
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/enqueue"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/Doraemonkeys/douyin2/internal/msgQueue"
+	"github.com/gin-gonic/gin"
+)
+
+const SuccCommentLike = "操作成功"
+
+type PostCommentLikeDTO struct {
+	CommentID uint   `json:"comment_id"`
+	Token     string `json:"token"`
+	// 1-点赞，2-取消点赞
+	ActionType string `json:"action_type"`
+}
+
+const (
+	PostCommentLikeDTO_CommentID  = "comment_id"
+	PostCommentLikeDTO_ActionType = "action_type"
+)
+
+const (
+	// 1-点赞，2-取消点赞
+	PostCommentLikeDTO_ActionType_Like   = "1"
+	PostCommentLikeDTO_ActionType_Unlike = "2"
+)
+
+// PostCommentLikeHandler 点赞/取消点赞评论，通过消息队列异步处理
+func PostCommentLikeHandler(c *gin.Context) {
+	var dto PostCommentLikeDTO
+	commentID, err := strconv.ParseUint(c.Query(PostCommentLikeDTO_CommentID), 10, 64)
+	dto.ActionType = c.Query(PostCommentLikeDTO_ActionType)
+	if err != nil || commentID == 0 ||
+		(dto.ActionType != PostCommentLikeDTO_ActionType_Like && dto.ActionType != PostCommentLikeDTO_ActionType_Unlike) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	dto.CommentID = uint(commentID)
+	action, _ := strconv.Atoi(dto.ActionType)
+	user := c.MustGet(app.UserKeyName).(app.User)
+
+	// 发送到消息队列
+	msg := msgQueue.CommentLikeMsg{
+		CommentID:  dto.CommentID,
+		UserID:     user.ID,
+		ActionType: action,
+		// 由幂等中间件设置，用于消息去重
+		IdempotencyKey: c.GetString(app.IdempotencyKeyName),
+	}
+	if !enqueue.Push(c, msgQueue.GetCommentLikeMQ(), msg, msgQueue.PushTimeout()) {
+		return
+	}
+	response.ResponseSuccess(c, SuccCommentLike)
+}
+
+// setCommentLiked 设置查询者是否点赞了列表中的评论
+func setCommentLiked(queryerID uint, commentList []response.CommentList) error {
+	commentIDs := make([]uint, len(commentList))
+	for k, comment := range commentList {
+		commentIDs[k] = uint(comment.ID)
+	}
+	likedMap, err := services.QueryCommentLikedMap(queryerID, commentIDs)
+	if err != nil {
+		return err
+	}
+	for k, comment := range commentList {
+		commentList[k].IsLiked = likedMap[uint(comment.ID)]
+	}
+	return nil
+}
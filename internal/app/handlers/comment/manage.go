@@ -0,0 +1,95 @@
+package comment
+
+import (
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const SuccCommentManage = "操作成功"
+
+const (
+	CommentManageDTO_VideoID    = "video_id"
+	CommentManageDTO_CommentID  = "comment_id"
+	CommentManageDTO_ActionType = "action_type"
+)
+
+const (
+	// 1-隐藏，2-取消隐藏
+	PostCommentHideDTO_ActionType_Hide   = "1"
+	PostCommentHideDTO_ActionType_Unhide = "2"
+	// 1-置顶，2-取消置顶
+	PostCommentPinDTO_ActionType_Pin   = "1"
+	PostCommentPinDTO_ActionType_Unpin = "2"
+	// 1-关闭评论，2-开启评论
+	PostCommentSwitchDTO_ActionType_Close = "1"
+	PostCommentSwitchDTO_ActionType_Open  = "2"
+)
+
+// PostCommentHideHandler 视频作者隐藏/取消隐藏自己视频下的评论
+func PostCommentHideHandler(c *gin.Context) {
+	commentID, err := strconv.ParseUint(c.Query(CommentManageDTO_CommentID), 10, 64)
+	actionType := c.Query(CommentManageDTO_ActionType)
+	if err != nil || commentID == 0 ||
+		(actionType != PostCommentHideDTO_ActionType_Hide && actionType != PostCommentHideDTO_ActionType_Unhide) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	user := c.MustGet(app.UserKeyName).(app.User)
+	err = services.HideComment(uint(commentID), user.ID, actionType == PostCommentHideDTO_ActionType_Hide)
+	responseCommentManage(c, err)
+}
+
+// PostCommentPinHandler 视频作者置顶/取消置顶自己视频下的评论，每个视频最多置顶一条评论
+func PostCommentPinHandler(c *gin.Context) {
+	videoID, err := strconv.ParseUint(c.Query(CommentManageDTO_VideoID), 10, 64)
+	actionType := c.Query(CommentManageDTO_ActionType)
+	if err != nil || videoID == 0 ||
+		(actionType != PostCommentPinDTO_ActionType_Pin && actionType != PostCommentPinDTO_ActionType_Unpin) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	var commentID uint64
+	if actionType == PostCommentPinDTO_ActionType_Pin {
+		commentID, err = strconv.ParseUint(c.Query(CommentManageDTO_CommentID), 10, 64)
+		if err != nil || commentID == 0 {
+			response.ResponseError(c, response.ErrInvalidParams)
+			return
+		}
+	}
+	user := c.MustGet(app.UserKeyName).(app.User)
+	err = services.PinComment(uint(videoID), uint(commentID), user.ID)
+	responseCommentManage(c, err)
+}
+
+// PostCommentSwitchHandler 视频作者关闭/开启自己视频的评论
+func PostCommentSwitchHandler(c *gin.Context) {
+	videoID, err := strconv.ParseUint(c.Query(CommentManageDTO_VideoID), 10, 64)
+	actionType := c.Query(CommentManageDTO_ActionType)
+	if err != nil || videoID == 0 ||
+		(actionType != PostCommentSwitchDTO_ActionType_Close && actionType != PostCommentSwitchDTO_ActionType_Open) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	user := c.MustGet(app.UserKeyName).(app.User)
+	err = services.SetCommentsDisabled(uint(videoID), user.ID, actionType == PostCommentSwitchDTO_ActionType_Close)
+	responseCommentManage(c, err)
+}
+
+func responseCommentManage(c *gin.Context, err error) {
+	if err == nil {
+		response.ResponseSuccess(c, SuccCommentManage)
+		return
+	}
+	switch err.Error() {
+	case services.ErrNotVideoAuthor, services.ErrCommentNotExists, services.ErrCannotPin, response.ErrVideoNotExists:
+		response.ResponseError(c, err.Error())
+	default:
+		logrus.Error("comment manage failed, err: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+	}
+}
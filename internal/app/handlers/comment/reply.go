@@ -0,0 +1,107 @@
+package comment
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type QueryCommentReplyListDTO struct {
+	CommentID uint `json:"comment_id"`
+	// 页码，从1开始
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+}
+
+const (
+	QueryCommentReplyListDTO_CommentID = "comment_id"
+	QueryCommentReplyListDTO_Page      = "page"
+	QueryCommentReplyListDTO_PageSize  = "page_size"
+)
+
+const (
+	defaultReplyPageSize = 20
+	maxReplyPageSize     = 50
+)
+
+// QueryCommentReplyListHandler 分页查询评论的直接回复，按时间顺序
+func QueryCommentReplyListHandler(c *gin.Context) {
+	var dto QueryCommentReplyListDTO
+	if !dto.getAndCheckDTO(c) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	offset := (dto.Page - 1) * dto.PageSize
+	replies, total, err := services.QueryCommentReplies(dto.CommentID, offset, dto.PageSize)
+	if err != nil && err.Error() == services.ErrCommentsDisabled {
+		response.ResponseError(c, services.ErrCommentsDisabled)
+		return
+	}
+	if err != nil {
+		logrus.Error("QueryCommentReplyListHandler: services.QueryCommentReplies error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	commenterIDMap := make(map[uint]struct{}, len(replies))
+	for _, reply := range replies {
+		commenterIDMap[reply.UserID] = struct{}{}
+	}
+	queryer := c.MustGet(app.UserKeyName).(app.User)
+	followedMap, err := services.QueryFollowedMapByUserIDMap(queryer.ID, commenterIDMap)
+	if err != nil {
+		logrus.Error("QueryCommentReplyListHandler: services.QueryFollowedMapByUserIDMap error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	var res response.QueryCommentReplyListResponse
+	res.CommentList = make([]response.CommentList, len(replies))
+	for k, reply := range replies {
+		res.CommentList[k].SetCommentValue(reply)
+		res.CommentList[k].User.SetValue(reply.Commenter, followedMap[reply.UserID])
+	}
+	if err := setCommentLiked(queryer.ID, res.CommentList); err != nil {
+		logrus.Error("QueryCommentReplyListHandler: setCommentLiked error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	if err := services.SetCommentSpans(res.CommentList); err != nil {
+		logrus.Error("QueryCommentReplyListHandler: services.SetCommentSpans error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	res.Total = int(total)
+	res.HasMore = offset+len(replies) < int(total)
+	res.StatusCode = response.Success
+	c.JSON(http.StatusOK, res)
+}
+
+func (dto *QueryCommentReplyListDTO) getAndCheckDTO(c *gin.Context) bool {
+	commentID, err := strconv.ParseUint(c.Query(QueryCommentReplyListDTO_CommentID), 10, 64)
+	if err != nil || commentID == 0 {
+		return false
+	}
+	dto.CommentID = uint(commentID)
+	dto.Page = 1
+	if page := c.Query(QueryCommentReplyListDTO_Page); page != "" {
+		dto.Page, err = strconv.Atoi(page)
+		if err != nil || dto.Page < 1 {
+			return false
+		}
+	}
+	dto.PageSize = defaultReplyPageSize
+	if pageSize := c.Query(QueryCommentReplyListDTO_PageSize); pageSize != "" {
+		dto.PageSize, err = strconv.Atoi(pageSize)
+		if err != nil || dto.PageSize < 1 {
+			return false
+		}
+	}
+	if dto.PageSize > maxReplyPageSize {
+		dto.PageSize = maxReplyPageSize
+	}
+	return true
+}
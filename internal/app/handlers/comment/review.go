@@ -0,0 +1,126 @@
+package comment
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const SuccCommentReview = "审核成功"
+
+type QueryPendingCommentListDTO struct {
+	// 上一页最后一条评论的id，为空时从最早提交的评论开始
+	Cursor uint `json:"cursor"`
+	Limit  int  `json:"limit"`
+}
+
+const (
+	QueryPendingCommentListDTO_Cursor = "cursor"
+	QueryPendingCommentListDTO_Limit  = "limit"
+)
+
+const (
+	PostCommentReviewDTO_CommentID  = "comment_id"
+	PostCommentReviewDTO_ActionType = "action_type"
+)
+
+const (
+	// 1-通过，2-不通过
+	PostCommentReviewDTO_ActionType_Pass   = "1"
+	PostCommentReviewDTO_ActionType_Reject = "2"
+)
+
+// checkModerator 检查登录用户是否为审核员，不是时返回错误响应
+func checkModerator(c *gin.Context) bool {
+	user := c.MustGet(app.UserKeyName).(app.User)
+	if !services.IsModerator(user.ID) {
+		response.ResponseError(c, response.ErrNotModerator)
+		return false
+	}
+	return true
+}
+
+// QueryPendingCommentListHandler 审核员按提交顺序分页查询等待人工审核的评论
+func QueryPendingCommentListHandler(c *gin.Context) {
+	if !checkModerator(c) {
+		return
+	}
+	var dto QueryPendingCommentListDTO
+	if !dto.getAndCheckDTO(c) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	comments, hasMore, err := services.QueryPendingComments(dto.Cursor, dto.Limit)
+	if err != nil {
+		logrus.Error("QueryPendingCommentListHandler: services.QueryPendingComments error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	var res response.QueryPendingCommentListResponse
+	res.CommentList = make([]response.PendingComment, len(comments))
+	for k, comment := range comments {
+		res.CommentList[k].SetValue(comment, comment.Commenter, false)
+		res.CommentList[k].PendingReview = true
+		res.CommentList[k].VideoID = int(comment.VideoID)
+	}
+	res.HasMore = hasMore
+	if hasMore {
+		res.NextCursor = strconv.FormatUint(uint64(comments[len(comments)-1].ID), 10)
+	}
+	res.StatusCode = response.Success
+	c.JSON(http.StatusOK, res)
+}
+
+func (dto *QueryPendingCommentListDTO) getAndCheckDTO(c *gin.Context) bool {
+	if cursor := c.Query(QueryPendingCommentListDTO_Cursor); cursor != "" {
+		id, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return false
+		}
+		dto.Cursor = uint(id)
+	}
+	dto.Limit = defaultCommentPageSize
+	if limit := c.Query(QueryPendingCommentListDTO_Limit); limit != "" {
+		var err error
+		dto.Limit, err = strconv.Atoi(limit)
+		if err != nil || dto.Limit < 1 {
+			return false
+		}
+	}
+	if dto.Limit > maxCommentPageSize {
+		dto.Limit = maxCommentPageSize
+	}
+	return true
+}
+
+// PostCommentReviewHandler 审核员审核等待人工审核的评论，通过时公开评论，不通过时删除评论，
+// 审核结果会通知评论者
+func PostCommentReviewHandler(c *gin.Context) {
+	if !checkModerator(c) {
+		return
+	}
+	commentID, err := strconv.ParseUint(c.Query(PostCommentReviewDTO_CommentID), 10, 64)
+	actionType := c.Query(PostCommentReviewDTO_ActionType)
+	if err != nil || commentID == 0 ||
+		(actionType != PostCommentReviewDTO_ActionType_Pass && actionType != PostCommentReviewDTO_ActionType_Reject) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	err = services.ReviewComment(uint(commentID), actionType == PostCommentReviewDTO_ActionType_Pass)
+	if err == nil {
+		response.ResponseSuccess(c, SuccCommentReview)
+		return
+	}
+	switch err.Error() {
+	case services.ErrCommentNotExists, services.ErrCommentNotPending:
+		response.ResponseError(c, err.Error())
+	default:
+		logrus.Error("PostCommentReviewHandler: services.ReviewComment error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+	}
+}
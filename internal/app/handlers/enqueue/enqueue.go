@@ -0,0 +1,32 @@
+// Package enqueue 处理函数将请求放入消息队列异步处理
+package enqueue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Push 将消息放入消息队列，放入失败时写出错误响应并返回false。
+// 队列已满时按队列的溢出策略处理：block策略最多等待timeout(请求结束时提前返回)，
+// 超时或reject策略拒绝时响应服务繁忙。
+func Push[T any](c *gin.Context, mq messageQueue.MQ[T], msg T, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	err := mq.PushContext(ctx, msg)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, messageQueue.ErrMQFull) || errors.Is(err, context.DeadlineExceeded) {
+		response.ResponseBusy(c)
+		return false
+	}
+	logrus.Error("push msg failed, err: ", err)
+	response.ResponseError(c, response.ErrServerInternal)
+	return false
+}
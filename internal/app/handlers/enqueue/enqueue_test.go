@@ -0,0 +1,109 @@
+package enqueue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+	"github.com/gin-gonic/gin"
+)
+
+// newFullMQ 创建已满的消息队列，close(block)后恢复消费
+func newFullMQ(policy messageQueue.OverflowPolicy, block chan struct{}) *messageQueue.SimpleMQ[int] {
+	// worker阻塞在第一条消息上，sendMsg随后阻塞在发送消息上，队列不再被消费
+	mq := messageQueue.NewSimpleMQ(1, func(msg int) { <-block }, messageQueue.WithMaxLen[int](5, policy))
+	for i := 0; i < 10; i++ {
+		mq.TryPush(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for i := 10; i < 20; i++ {
+		mq.TryPush(i)
+	}
+	return mq
+}
+
+func newTestRouter(mq messageQueue.MQ[int], timeout time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/action/", func(c *gin.Context) {
+		if !Push(c, mq, 100, timeout) {
+			return
+		}
+		response.ResponseSuccess(c, "ok")
+	})
+	return router
+}
+
+func doRequest(router *gin.Engine) (int, response.CommonResponse) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/action/", nil)
+	router.ServeHTTP(w, req)
+	var res response.CommonResponse
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res
+}
+
+func TestPush_BlockTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	mq := newFullMQ(messageQueue.OverflowBlock, block)
+	router := newTestRouter(mq, 100*time.Millisecond)
+
+	start := time.Now()
+	code, res := doRequest(router)
+	// 队列已满时等待到超时才响应服务繁忙
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("responded after %v, want at least 100ms", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || res.StatusCode != response.ServerBusy {
+		t.Errorf("response = %d, %d, want %d, %d", code, res.StatusCode, http.StatusServiceUnavailable, response.ServerBusy)
+	}
+}
+
+func TestPush_BlockUntilNotFull(t *testing.T) {
+	block := make(chan struct{})
+	mq := newFullMQ(messageQueue.OverflowBlock, block)
+	defer mq.Close(context.Background())
+	router := newTestRouter(mq, 5*time.Second)
+
+	// 等待期间队列恢复消费，请求成功
+	time.AfterFunc(50*time.Millisecond, func() { close(block) })
+	code, res := doRequest(router)
+	if code != http.StatusOK || res.StatusCode != response.Success {
+		t.Errorf("response = %d, %d, want %d, %d", code, res.StatusCode, http.StatusOK, response.Success)
+	}
+}
+
+func TestPush_Reject(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	mq := newFullMQ(messageQueue.OverflowReject, block)
+	router := newTestRouter(mq, 5*time.Second)
+
+	// reject策略不等待
+	start := time.Now()
+	code, res := doRequest(router)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("responded after %v, want immediately", elapsed)
+	}
+	if code != http.StatusServiceUnavailable || res.StatusCode != response.ServerBusy {
+		t.Errorf("response = %d, %d, want %d, %d", code, res.StatusCode, http.StatusServiceUnavailable, response.ServerBusy)
+	}
+}
+
+func TestPush_Closed(t *testing.T) {
+	mq := messageQueue.NewSimpleMQ(1, func(msg int) {})
+	mq.Close(context.Background())
+	router := newTestRouter(mq, time.Second)
+
+	code, res := doRequest(router)
+	if code != http.StatusOK || res.StatusCode != response.Failed || res.StatusMsg != response.ErrServerInternal {
+		t.Errorf("response = %d, %d, %q, want %d, %d, %q",
+			code, res.StatusCode, res.StatusMsg, http.StatusOK, response.Failed, response.ErrServerInternal)
+	}
+}
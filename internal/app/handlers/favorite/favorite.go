@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/enqueue"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
@@ -48,12 +49,16 @@ func PostFavorHandler(c *gin.Context) {
 	action, _ := strconv.Atoi(postFavorDTO.ActionType)
 
 	// 发送到消息队列
-	mq := msgQueue.GetFavoriteMQ()
-	mq.Push(msgQueue.FavoriteMSg{
+	msg := msgQueue.FavoriteMSg{
 		VideoID:    uint(postFavorDTO.VideoID),
 		UserID:     user.ID,
 		ActionType: action,
-	})
+		// 由幂等中间件设置，用于消息去重
+		IdempotencyKey: c.GetString(app.IdempotencyKeyName),
+	}
+	if !enqueue.Push(c, msgQueue.GetFavoriteMQ(), msg, msgQueue.PushTimeout()) {
+		return
+	}
 	response.ResponseSuccess(c, FavoriteSuccess)
 }
 
@@ -136,6 +141,7 @@ func queryFavorVideoListHandler_CacheHit(c *gin.Context, queryFavorVideoListDTO
 		}
 	}
 	res.SetValues(videoAndAuthorInfos, FollowedMap)
+	services.SetVideoTitleSpans(res.VideoList)
 	res.StatusCode = response.Success
 	for _, val := range res.VideoList {
 		app.ZeroCheck(val.ID, val.Author.ID)
@@ -174,6 +180,7 @@ func queryFavorVideoListHandler_CacheMiss(c *gin.Context, queryFavorVideoListDTO
 		return
 	}
 	res.SetValues(videoList, followedMap)
+	services.SetVideoTitleSpans(res.VideoList)
 	res.StatusCode = response.Success
 	for _, val := range res.VideoList {
 		app.ZeroCheck(val.ID, val.Author.ID)
@@ -60,6 +60,7 @@ func (p *proxyFeedVideoList) DoNoToken(feedRequest FeedVideeDTO) {
 	}
 	var dummyMap map[uint]bool = make(map[uint]bool)
 	res.SetValues(videoModels, dummyMap, dummyMap)
+	services.SetVideoTitleSpans(res.VideoList)
 	res.CommonResponse.StatusCode = response.Success
 	p.Context.JSON(http.StatusOK, res)
 }
@@ -122,6 +123,7 @@ func (p *proxyFeedVideoList) DoHasToken(feedRequest FeedVideeDTO) {
 		app.ZeroCheck(video.Author.ID)
 	}
 	res.SetValues(videoModels, likesVideoInFeedListMap, FollowedMap)
+	services.SetVideoTitleSpans(res.VideoList)
 	res.CommonResponse.StatusCode = response.Success
 	p.Context.JSON(http.StatusOK, res)
 }
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/enqueue"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/msgQueue"
@@ -63,8 +64,9 @@ func PostFollowActionHandler(c *gin.Context) {
 	var p PostFollowActionDTO
 	p.getAndCheckDTO(c)
 	msg := p.newMsg(c)
-	que := msgQueue.GetFollowMQ()
-	que.Push(msg)
+	if !enqueue.Push(c, msgQueue.GetFollowMQ(), msg, msgQueue.PushTimeout()) {
+		return
+	}
 	response.ResponseSuccess(c, SuccessFollowed)
 }
 
@@ -74,6 +76,7 @@ func (p *PostFollowActionDTO) newMsg(c *gin.Context) msgQueue.FollowMsg {
 	msg.ActionType = p.ActionType
 	user := c.MustGet(app.UserKeyName).(app.User)
 	msg.UserID = user.ID
+	msg.IdempotencyKey = c.GetString(app.IdempotencyKeyName)
 	return msg
 }
 
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Doraemonkeys/douyin2/internal/msgQueue"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+	"github.com/gin-gonic/gin"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// TokenAuth 校验Authorization请求头中的Bearer token，token为空时不校验
+func TokenAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		auth := c.GetHeader("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+// MQMetricsHandler 以Prometheus文本格式输出各消息队列的运行状态
+func MQMetricsHandler(c *gin.Context) {
+	all := msgQueue.GetAllMQStats()
+	var b strings.Builder
+
+	gauge := func(name, help string, value func(messageQueue.Stats) float64) {
+		writeMetric(&b, name, "gauge", help, all, value)
+	}
+	counter := func(name, help string, value func(messageQueue.Stats) float64) {
+		writeMetric(&b, name, "counter", help, all, value)
+	}
+	gauge("douyin_mq_depth", "Number of messages waiting in the queue.",
+		func(s messageQueue.Stats) float64 { return float64(s.Depth) })
+	gauge("douyin_mq_delayed", "Number of delayed messages not yet due.",
+		func(s messageQueue.Stats) float64 { return float64(s.Delayed) })
+	counter("douyin_mq_pushed_total", "Messages accepted by the queue.",
+		func(s messageQueue.Stats) float64 { return float64(s.Pushed) })
+	counter("douyin_mq_rejected_total", "Messages rejected because the queue was full.",
+		func(s messageQueue.Stats) float64 { return float64(s.Rejected) })
+	counter("douyin_mq_dropped_total", "Messages dropped by the drop-oldest overflow policy.",
+		func(s messageQueue.Stats) float64 { return float64(s.Dropped) })
+	gauge("douyin_mq_push_rate", "Messages pushed per second over the last 10 seconds.",
+		func(s messageQueue.Stats) float64 { return s.PushRate })
+	counter("douyin_mq_processed_total", "Messages processed by the workers.",
+		func(s messageQueue.Stats) float64 { return float64(s.Processed) })
+	counter("douyin_mq_handler_errors_total", "Handler calls that returned an error.",
+		func(s messageQueue.Stats) float64 { return float64(s.Errors) })
+	gauge("douyin_mq_workers", "Number of workers.",
+		func(s messageQueue.Stats) float64 { return float64(s.WorkerNum) })
+	gauge("douyin_mq_busy_workers", "Number of workers currently handling messages.",
+		func(s messageQueue.Stats) float64 { return float64(s.BusyWorkers) })
+	gauge("douyin_mq_worker_utilization", "Fraction of worker time spent handling messages since start.",
+		func(s messageQueue.Stats) float64 { return s.Utilization })
+	writeLatencyHistogram(&b, all)
+
+	c.Data(http.StatusOK, metricsContentType, []byte(b.String()))
+}
+
+func writeMetric(b *strings.Builder, name, typ, help string, all []msgQueue.MQStats, value func(messageQueue.Stats) float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, typ)
+	for _, mq := range all {
+		fmt.Fprintf(b, "%s{queue=%q} %s\n", name, mq.Name, formatFloat(value(mq.Stats)))
+	}
+}
+
+func writeLatencyHistogram(b *strings.Builder, all []msgQueue.MQStats) {
+	const name = "douyin_mq_handle_duration_seconds"
+	fmt.Fprintf(b, "# HELP %s Time spent in the message handler.\n", name)
+	fmt.Fprintf(b, "# TYPE %s histogram\n", name)
+	for _, mq := range all {
+		counts := mq.Stats.LatencyCounts
+		for i, bucket := range messageQueue.LatencyBuckets {
+			fmt.Fprintf(b, "%s_bucket{queue=%q,le=%q} %d\n", name, mq.Name, formatFloat(bucket.Seconds()), counts[i])
+		}
+		total := counts[len(counts)-1]
+		fmt.Fprintf(b, "%s_bucket{queue=%q,le=\"+Inf\"} %d\n", name, mq.Name, total)
+		fmt.Fprintf(b, "%s_sum{queue=%q} %s\n", name, mq.Name, formatFloat(mq.Stats.LatencySum.Seconds()))
+		fmt.Fprintf(b, "%s_count{queue=%q} %d\n", name, mq.Name, total)
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,118 @@
+package notification
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const SuccMarkRead = "操作成功"
+
+type QueryNotificationListDTO struct {
+	// 上一页最后一条通知的id，为空时从最新的通知开始
+	Cursor uint `json:"cursor"`
+	Limit  int  `json:"limit"`
+}
+
+const (
+	QueryNotificationListDTO_Cursor = "cursor"
+	QueryNotificationListDTO_Limit  = "limit"
+)
+
+const (
+	defaultNotificationLimit = 20
+	maxNotificationLimit     = 50
+)
+
+// QueryNotificationListHandler 按时间倒序分页查询登录用户的通知
+func QueryNotificationListHandler(c *gin.Context) {
+	var dto QueryNotificationListDTO
+	if !dto.getAndCheckDTO(c) {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	user := c.MustGet(app.UserKeyName).(app.User)
+	notifications, hasMore, err := services.QueryNotifications(user.ID, dto.Cursor, dto.Limit)
+	if err != nil {
+		logrus.Error("QueryNotificationListHandler: services.QueryNotifications error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	unread, err := services.CountUnreadNotifications(user.ID)
+	if err != nil {
+		logrus.Error("QueryNotificationListHandler: services.CountUnreadNotifications error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	actorIDMap := make(map[uint]struct{}, len(notifications))
+	for _, notification := range notifications {
+		actorIDMap[notification.ActorID] = struct{}{}
+	}
+	followedMap, err := services.QueryFollowedMapByUserIDMap(user.ID, actorIDMap)
+	if err != nil {
+		logrus.Error("QueryNotificationListHandler: services.QueryFollowedMapByUserIDMap error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	var res response.QueryNotificationListResponse
+	res.NotificationList = make([]response.Notification, len(notifications))
+	for k, notification := range notifications {
+		res.NotificationList[k].SetValue(notification, followedMap[notification.ActorID])
+	}
+	res.HasMore = hasMore
+	if hasMore {
+		res.NextCursor = strconv.FormatUint(uint64(notifications[len(notifications)-1].ID), 10)
+	}
+	res.UnreadCount = int(unread)
+	res.StatusCode = response.Success
+	c.JSON(http.StatusOK, res)
+}
+
+func (dto *QueryNotificationListDTO) getAndCheckDTO(c *gin.Context) bool {
+	if cursor := c.Query(QueryNotificationListDTO_Cursor); cursor != "" {
+		id, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return false
+		}
+		dto.Cursor = uint(id)
+	}
+	dto.Limit = defaultNotificationLimit
+	if limit := c.Query(QueryNotificationListDTO_Limit); limit != "" {
+		var err error
+		dto.Limit, err = strconv.Atoi(limit)
+		if err != nil || dto.Limit < 1 {
+			return false
+		}
+	}
+	if dto.Limit > maxNotificationLimit {
+		dto.Limit = maxNotificationLimit
+	}
+	return true
+}
+
+const PostNotificationReadDTO_NotificationID = "notification_id"
+
+// PostNotificationReadHandler 将登录用户的通知标记为已读，不传notification_id时标记全部通知
+func PostNotificationReadHandler(c *gin.Context) {
+	var notificationID uint64
+	if id := c.Query(PostNotificationReadDTO_NotificationID); id != "" {
+		var err error
+		notificationID, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			response.ResponseError(c, response.ErrInvalidParams)
+			return
+		}
+	}
+	user := c.MustGet(app.UserKeyName).(app.User)
+	if err := services.MarkNotificationsRead(user.ID, uint(notificationID)); err != nil {
+		logrus.Error("PostNotificationReadHandler: services.MarkNotificationsRead error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	response.ResponseSuccess(c, SuccMarkRead)
+}
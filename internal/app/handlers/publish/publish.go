@@ -12,8 +12,8 @@ import (
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/moderation"
 	"github.com/Doraemonkeys/douyin2/internal/pkg/storage"
-	"github.com/Doraemonkeys/douyin2/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -39,17 +39,14 @@ const (
 	PublishVedioDTO_Title = "title"
 )
 
-func titleCheck(title string) bool {
-	if utils.StrLen(title) > models.VideoTitleMaxRuneLength {
-		return false
+// titleCheck 审核视频标题，返回审核后的标题(敏感词可能被替换为*)
+func titleCheck(authorID uint, title string) (string, bool) {
+	res := services.ModerateTitle(authorID, title)
+	if res.Action == moderation.Reject {
+		logrus.Debug("title check failed, reason:", res.Reason)
+		return title, false
 	}
-	if len(title) > models.VideoTitleMaxByteLength {
-		return false
-	}
-	if len(title) == 0 {
-		return false
-	}
-	return true
+	return res.Text, true
 }
 
 func PublishVedioHandler(c *gin.Context) {
@@ -65,7 +62,9 @@ func PublishVedioHandler(c *gin.Context) {
 	if publishRequest.Title == "" {
 		response.ResponseError(c, ErrorVideoTitleEmpty)
 	}
-	if !titleCheck(publishRequest.Title) {
+	var titleOK bool
+	publishRequest.Title, titleOK = titleCheck(c.MustGet(app.UserKeyName).(app.User).ID, publishRequest.Title)
+	if !titleOK {
 		response.ResponseError(c, response.ErrInvalidParams)
 		return
 	}
@@ -201,6 +200,7 @@ func QueryPublishListHandler(c *gin.Context) {
 	// 返回视频列表
 	var res response.QueryVideoListResponse
 	res.SetValues(targetUserVideoPublishList, targetUser, likeVideoListMap, isFollowed)
+	services.SetVideoTitleSpans(res.VideoList)
 
 	res.StatusCode = response.Success
 	res.StatusMsg = response.QuerySuccessMsg
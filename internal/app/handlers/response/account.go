@@ -0,0 +1,21 @@
+package response
+
+// errors
+const (
+	ErrEmailNotSet     = "未设置邮箱"
+	ErrEmailVerified   = "邮箱已验证"
+	ErrMailTooFrequent = "邮件发送过于频繁，请稍后重试"
+	ErrMailSendFailed  = "邮件发送失败，请稍后重试"
+	ErrInvalidCode     = "验证码无效"
+	ErrCodeExpired     = "验证码已过期"
+	ErrCodeUsed        = "验证码已使用"
+)
+
+// success response
+const (
+	SuccVerifyEmail     = "邮箱验证成功"
+	SuccSendVerifyEmail = "验证邮件已发送"
+	// 不论邮箱是否存在都返回相同的信息，避免探测邮箱
+	SuccSendResetEmail = "如果该邮箱已验证，重置密码的邮件将发送到该邮箱"
+	SuccResetPassword  = "密码已重置"
+)
@@ -4,6 +4,15 @@ import "github.com/Doraemonkeys/douyin2/internal/app/models"
 
 const CommentResFormat = "2006-01-02 15:04:05"
 
+const (
+	// 评论未通过审核，后接原因
+	ErrCommentRejected = "评论未通过审核："
+	// 不是审核员
+	ErrNotModerator = "没有审核权限"
+	// 评论等待人工审核
+	MsgCommentHeld = "评论已提交，审核通过后公开"
+)
+
 type PostCommentResponse struct {
 	CommonResponse
 	Comment Comment `json:"comment"`
@@ -14,11 +23,30 @@ type Comment struct {
 	User       User   `json:"user"`
 	Content    string `json:"content"`
 	CreateDate string `json:"create_date"`
+	// 回复的评论id，顶层评论为0
+	ParentID int `json:"parent_id"`
+	// 评论等待人工审核，审核通过前不公开
+	PendingReview bool `json:"pending_review,omitempty"`
+}
+
+type QueryPendingCommentListResponse struct {
+	CommonResponse
+	CommentList []PendingComment `json:"comment_list"`
+	// 下一页的游标，没有更多评论时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// PendingComment 等待人工审核的评论
+type PendingComment struct {
+	Comment
+	VideoID int `json:"video_id"`
 }
 
 func (c *Comment) SetValue(comment models.CommentModel, user models.UserModel, followedMyself bool) {
 	c.ID = int(comment.ID)
 	c.Content = comment.Content
 	c.CreateDate = comment.CreatedAt.Format(CommentResFormat)
+	c.ParentID = int(comment.ParentID)
 	c.User.SetValue(user, followedMyself)
 }
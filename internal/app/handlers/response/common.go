@@ -24,6 +24,8 @@ type VideoList struct {
 	CommentCount  int    `json:"comment_count"`
 	IsFavorite    bool   `json:"is_favorite"`
 	Title         string `json:"title"`
+	// 标题中的@用户和#话题
+	Spans []Span `json:"spans,omitempty"`
 }
 
 // status code
@@ -32,6 +34,9 @@ const (
 	Success      = 0
 	Failed       = 500
 	TokenExpired = 401
+	ServerBusy   = 503
+	// 登录失败次数过多，暂时禁止登录
+	LoginLocked = 423
 )
 
 // errors
@@ -40,11 +45,17 @@ const (
 	ErrUserNotLogin   = "用户未登录"
 	ErrUserNotExists  = "用户不存在"
 	ErrUserExists     = "用户已存在"
+	ErrVideoNotExists = "视频不存在"
 	ErrUserPassword   = "密码错误"
 	ErrUserToken      = "用户token错误"
 	ErrUserTokenExp   = "用户token过期"
 	ErrInvalidParams  = "参数错误"
 	ErrDBEmpty        = "已经没有更多视频了"
+	ErrServerBusy     = "服务器繁忙，请稍后重试"
+	ErrLoginLocked    = "登录失败次数过多，请稍后重试"
+
+	ErrIdempotencyKeyReused = "幂等键已用于其他请求"
+	ErrRequestProcessing    = "请求正在处理中，请稍后重试"
 )
 
 // success response
@@ -67,6 +78,15 @@ func ResponseError(c *gin.Context, errMsg string) {
 	c.JSON(http.StatusOK, res)
 }
 
+// 服务器繁忙(如消息队列已满)，客户端应稍后重试
+func ResponseBusy(c *gin.Context) {
+	var res CommonResponse
+	res.StatusCode = ServerBusy
+	res.StatusMsg = ErrServerBusy
+	c.Header("Retry-After", "1")
+	c.JSON(http.StatusServiceUnavailable, res)
+}
+
 // 通用响应成功
 func ResponseSuccess(c *gin.Context, msg string) {
 	var res CommonResponse
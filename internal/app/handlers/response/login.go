@@ -4,4 +4,17 @@ type LoginResponse struct {
 	CommonResponse
 	UserID int    `json:"user_id"`
 	Token  string `json:"token"`
+	// 用于在token过期后换取新的token，只能使用一次
+	RefreshToken string `json:"refresh_token"`
+	// token的有效期(秒)
+	ExpiresIn int `json:"expires_in"`
+	// 登录被锁定时，距离可以再次登录的时间(秒)
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+type RefreshTokenResponse struct {
+	CommonResponse
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
 }
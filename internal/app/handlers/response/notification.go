@@ -0,0 +1,38 @@
+package response
+
+import (
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+)
+
+type QueryNotificationListResponse struct {
+	CommonResponse
+	NotificationList []Notification `json:"notification_list"`
+	// 下一页的游标，没有更多通知时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	// 未读通知数
+	UnreadCount int `json:"unread_count"`
+}
+
+type Notification struct {
+	ID   int    `json:"id"`
+	Type string `json:"type"`
+	// 触发通知的用户
+	Actor   User `json:"actor"`
+	VideoID int  `json:"video_id"`
+	// 在视频标题中被@时为0
+	CommentID  int    `json:"comment_id"`
+	IsRead     bool   `json:"is_read"`
+	CreateDate string `json:"create_date"`
+}
+
+// SetValue 设置通知信息，notification中需包含Actor信息
+func (n *Notification) SetValue(notification models.NotificationModel, followed bool) {
+	n.ID = int(notification.ID)
+	n.Type = notification.Type
+	n.Actor.SetValue(notification.Actor, followed)
+	n.VideoID = int(notification.VideoID)
+	n.CommentID = int(notification.CommentID)
+	n.IsRead = notification.IsRead
+	n.CreateDate = notification.CreatedAt.Format(CommentResFormat)
+}
@@ -4,6 +4,15 @@ package response
 const (
 	ErrInvalidUsername = "用户名不合法"
 	ErrInvalidPassword = "密码不合法"
+
+	ErrPasswordTooShort         = "密码太短"
+	ErrPasswordTooLong          = "密码太长"
+	ErrPasswordTooSimple        = "密码需要包含大写字母、小写字母、数字、符号中的多种字符"
+	ErrPasswordCommon           = "密码过于常见，请更换"
+	ErrPasswordContainsUsername = "密码不能包含用户名"
+
+	ErrInvalidEmail = "邮箱不合法"
+	ErrEmailExists  = "邮箱已被使用"
 )
 
 type RegisterResponse struct {
@@ -0,0 +1,34 @@
+package response
+
+import "github.com/Doraemonkeys/douyin2/internal/app/models"
+
+// Span 文本中的@用户或#话题片段
+type Span struct {
+	// mention或hashtag
+	Type string `json:"type"`
+	// 在文本中的字符(rune)下标，[Start, End)包含@或#前缀
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// 被@的用户id或话题id
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+// NewSpans 根据文本和其中的实体生成片段，下标超出文本的实体被忽略
+func NewSpans(text string, entities []models.ContentEntityModel) []Span {
+	runes := []rune(text)
+	var spans []Span
+	for _, e := range entities {
+		if e.Start < 0 || e.Start >= e.End || e.End > len(runes) {
+			continue
+		}
+		spans = append(spans, Span{
+			Type:  e.Type,
+			Start: e.Start,
+			End:   e.End,
+			ID:    int(e.RefID),
+			Text:  string(runes[e.Start:e.End]),
+		})
+	}
+	return spans
+}
@@ -19,6 +19,11 @@ type User struct {
 type QueryCommentListResponse struct {
 	CommonResponse
 	CommentList []CommentList `json:"comment_list"`
+	// 下一页的游标，没有更多评论时为空
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	// 视频作者关闭了评论，此时评论列表为空
+	CommentsDisabled bool `json:"comments_disabled"`
 }
 
 type CommentList struct {
@@ -27,6 +32,42 @@ type CommentList struct {
 	Content string `json:"content"`
 	// 评论发布日期，格式 2006-01-02 15:04
 	CreateDate string `json:"create_date"`
+	// 回复的评论id，顶层评论为0
+	ParentID int `json:"parent_id"`
+	// 所在评论楼的顶层评论id，顶层评论为0
+	RootID     int  `json:"root_id"`
+	ReplyCount int  `json:"reply_count"`
+	IsDeleted  bool `json:"is_deleted"`
+	LikeCount  int  `json:"like_count"`
+	// 查询者是否点赞了该评论
+	IsLiked bool `json:"is_liked"`
+	// 评论内容中的@用户和#话题
+	Spans []Span `json:"spans,omitempty"`
+	// 被视频作者置顶
+	IsPinned bool `json:"is_pinned"`
+}
+
+// SetCommentValue 设置评论信息(不含User)，已删除的评论返回占位内容
+func (c *CommentList) SetCommentValue(comment models.CommentModel) {
+	c.ID = int(comment.ID)
+	c.Content = comment.Content
+	c.CreateDate = comment.CreatedAt.Format(CommentResFormat)
+	c.ParentID = int(comment.ParentID)
+	c.RootID = int(comment.RootID)
+	c.ReplyCount = int(comment.ReplyCount)
+	c.IsDeleted = comment.IsDeleted
+	c.LikeCount = int(comment.LikeCount)
+	if comment.IsDeleted {
+		c.Content = models.DeletedCommentContent
+	}
+}
+
+type QueryCommentReplyListResponse struct {
+	CommonResponse
+	CommentList []CommentList `json:"comment_list"`
+	// 回复总数
+	Total   int  `json:"total"`
+	HasMore bool `json:"has_more"`
 }
 
 // 查询者是否关注传入的user
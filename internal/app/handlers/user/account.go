@@ -0,0 +1,79 @@
+package user
+
+import (
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// VerifyEmailDTO 验证邮箱的请求参数
+type VerifyEmailDTO struct {
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+// ForgotPasswordDTO 忘记密码的请求参数
+type ForgotPasswordDTO struct {
+	Email string `json:"email" form:"email" binding:"required"`
+}
+
+// ResetPasswordDTO 重置密码的请求参数
+type ResetPasswordDTO struct {
+	Code     string `json:"code" form:"code" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+// PostEmailVerifyHandler 使用邮件中的验证码验证邮箱
+func PostEmailVerifyHandler(c *gin.Context) {
+	var req VerifyEmailDTO
+	if err := app.BindBody(c, &req); err != nil {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	responseAccount(c, services.VerifyEmail(req.Code), response.SuccVerifyEmail)
+}
+
+// PostEmailResendHandler 重新向当前用户的邮箱发送验证邮件
+func PostEmailResendHandler(c *gin.Context) {
+	user := c.MustGet(app.UserKeyName).(app.User)
+	responseAccount(c, services.ResendVerificationEmail(user.ID), response.SuccSendVerifyEmail)
+}
+
+// PostPasswordForgotHandler 向已验证的邮箱发送重置密码的邮件
+func PostPasswordForgotHandler(c *gin.Context) {
+	var req ForgotPasswordDTO
+	if err := app.BindBody(c, &req); err != nil {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	responseAccount(c, services.RequestPasswordReset(req.Email), response.SuccSendResetEmail)
+}
+
+// PostPasswordResetHandler 使用邮件中的验证码重置密码
+func PostPasswordResetHandler(c *gin.Context) {
+	var req ResetPasswordDTO
+	if err := app.BindBody(c, &req); err != nil {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	responseAccount(c, services.ResetPassword(req.Code, req.Password), response.SuccResetPassword)
+}
+
+func responseAccount(c *gin.Context, err error, succMsg string) {
+	if err == nil {
+		response.ResponseSuccess(c, succMsg)
+		return
+	}
+	switch err.Error() {
+	case response.ErrInvalidCode, response.ErrCodeExpired, response.ErrCodeUsed,
+		response.ErrEmailNotSet, response.ErrEmailVerified, response.ErrInvalidEmail,
+		response.ErrMailTooFrequent, response.ErrMailSendFailed, response.ErrUserNotExists,
+		response.ErrInvalidPassword, response.ErrPasswordTooShort, response.ErrPasswordTooLong,
+		response.ErrPasswordTooSimple, response.ErrPasswordCommon, response.ErrPasswordContainsUsername:
+		response.ResponseError(c, err.Error())
+	default:
+		logrus.Error("account operation failed, err: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+	}
+}
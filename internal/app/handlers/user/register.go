@@ -3,51 +3,50 @@ package user
 import (
 	"net/http"
 
+	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 // RegisterUserDTO 注册用户的请求参数
 type RegisterUserDTO struct {
 	Username string `json:"username" form:"username" binding:"required"`
 	Password string `json:"password" form:"password" binding:"required"`
+	// 可选，填写后发送验证邮件
+	Email string `json:"email" form:"email"`
 }
 
 const (
 	RegisterUserDTO_Username = "username"
 	RegisterUserDTO_Password = "password"
+	RegisterUserDTO_Email    = "email"
 )
 
 func UserRegisterHandler(c *gin.Context) {
 	var registerRequest RegisterUserDTO
 	var res response.RegisterResponse
-	// if err := c.ShouldBindJSON(&registerRequest); err != nil {
-	// 	logrus.Debug("UserRegisterHandler error: ", err)
-	// 	res.CommonResponse.StatusCode = response.Failed
-	// 	res.CommonResponse.StatusMsg = response.ErrInvalidParams
-	// 	c.JSON(http.StatusOK, res)
-	// 	return
-	// }
-	var ok1, ok2 bool
-	registerRequest.Username, ok1 = c.GetQuery(RegisterUserDTO_Username)
-	registerRequest.Password, ok2 = c.GetQuery(RegisterUserDTO_Password)
-	if !ok1 || !ok2 {
+	if err := app.BindCredentials(c, &registerRequest, config.GetPasswordConfig().AllowQueryCredentials); err != nil {
+		logrus.Debug("UserRegisterHandler error: ", err)
 		res.CommonResponse.StatusCode = response.Failed
 		res.CommonResponse.StatusMsg = response.ErrInvalidParams
 		c.JSON(http.StatusOK, res)
 		return
 	}
 
-	res, err := services.CreateUser(registerRequest.Username, registerRequest.Password)
+	res, err := services.CreateUser(registerRequest.Username, registerRequest.Password, registerRequest.Email)
 	if err != nil {
 		res.CommonResponse.StatusCode = response.Failed
 		switch err.Error() {
 		case response.ErrUserExists:
 			res.CommonResponse.StatusMsg = response.ErrUserExists
-		case response.ErrInvalidPassword:
-			res.CommonResponse.StatusMsg = response.ErrInvalidPassword
+		case response.ErrInvalidPassword, response.ErrPasswordTooShort, response.ErrPasswordTooLong,
+			response.ErrPasswordTooSimple, response.ErrPasswordCommon, response.ErrPasswordContainsUsername:
+			res.CommonResponse.StatusMsg = err.Error()
+		case response.ErrInvalidEmail, response.ErrEmailExists:
+			res.CommonResponse.StatusMsg = err.Error()
 		case response.ErrInvalidUsername:
 			res.CommonResponse.StatusMsg = response.ErrInvalidUsername
 		default:
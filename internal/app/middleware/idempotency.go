@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idempotency"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	IdempotencyKeyParam  = "idempotency_key"
+	// 重放的响应会带上该响应头
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// 幂等键最大长度
+	idempotencyKeyMaxLen = 128
+)
+
+// bodyRecorder 记录写出的响应
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleWare 幂等中间件，需放在JWTMiddleWare之后。
+// 请求带有幂等键(Idempotency-Key请求头或idempotency_key参数)时，
+// 同一用户相同幂等键的重复请求直接返回第一次请求的响应，不会再次执行。
+// 只保存处理成功的响应，处理失败时客户端可以使用同一幂等键重试。
+func IdempotencyMiddleWare() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			key = c.Query(IdempotencyKeyParam)
+		}
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			response.ResponseError(c, response.ErrInvalidParams)
+			c.Abort()
+			return
+		}
+		user := c.MustGet(app.UserKeyName).(app.User)
+		// 幂等键按用户隔离
+		key = strconv.FormatUint(uint64(user.ID), 10) + ":" + key
+		fingerprint := requestFingerprint(c)
+
+		store, ttl := database.GetIdempotencyStore()
+		ok, err := store.SetNX(key, idempotency.Record{Fingerprint: fingerprint}, ttl)
+		if err != nil {
+			// 存储不可用时不影响正常请求
+			logrus.Error("IdempotencyMiddleWare: SetNX error: ", err)
+			c.Next()
+			return
+		}
+		if !ok {
+			replayIdempotentResponse(c, store, key, fingerprint)
+			return
+		}
+
+		// 处理过程中panic时删除处理中的记录，否则直到过期前重试都会返回ErrRequestProcessing
+		defer func() {
+			if r := recover(); r != nil {
+				deleteIdempotencyKey(store, key)
+				panic(r)
+			}
+		}()
+		c.Set(app.IdempotencyKeyName, key)
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if !isSuccessResponse(recorder.Status(), recorder.body.Bytes()) {
+			deleteIdempotencyKey(store, key)
+			return
+		}
+		rec := idempotency.Record{
+			Fingerprint: fingerprint,
+			Done:        true,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}
+		if err := store.Set(key, rec, ttl); err != nil {
+			logrus.Error("IdempotencyMiddleWare: Set error: ", err)
+		}
+	}
+}
+
+func deleteIdempotencyKey(store idempotency.Store, key string) {
+	if err := store.Delete(key); err != nil {
+		logrus.Error("IdempotencyMiddleWare: Delete error: ", err)
+	}
+}
+
+func replayIdempotentResponse(c *gin.Context, store idempotency.Store, key, fingerprint string) {
+	defer c.Abort()
+	rec, ok, err := store.Get(key)
+	if err != nil {
+		logrus.Error("IdempotencyMiddleWare: Get error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	if !ok {
+		// 第一次请求处理失败，记录已被删除
+		response.ResponseError(c, response.ErrRequestProcessing)
+		return
+	}
+	if rec.Fingerprint != fingerprint {
+		response.ResponseError(c, response.ErrIdempotencyKeyReused)
+		return
+	}
+	if !rec.Done {
+		response.ResponseError(c, response.ErrRequestProcessing)
+		return
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(rec.Status, rec.ContentType, rec.Body)
+}
+
+// requestFingerprint 由请求方法、路径和参数(不含token和幂等键)计算请求指纹，不会修改请求
+func requestFingerprint(c *gin.Context) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "?" + fingerprintValues(c.Request.URL.Query()).Encode()))
+	if err := c.Request.ParseForm(); err == nil && len(c.Request.PostForm) > 0 {
+		h.Write([]byte("\n" + fingerprintValues(c.Request.PostForm).Encode()))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprintValues 返回去掉token和幂等键后的参数副本
+func fingerprintValues(values url.Values) url.Values {
+	res := make(url.Values, len(values))
+	for k, v := range values {
+		if k == TokenParam || k == IdempotencyKeyParam {
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// isSuccessResponse 判断响应是否为处理成功
+func isSuccessResponse(status int, body []byte) bool {
+	if status != http.StatusOK {
+		return false
+	}
+	var res response.CommonResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return false
+	}
+	return res.StatusCode == response.Success
+}
@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	myjwt "github.com/Doraemonkeys/douyin2/pkg/jwt"
 	"github.com/Doraemonkeys/douyin2/utils"
 	"github.com/gin-gonic/gin"
@@ -18,22 +23,66 @@ import (
 
 var JwtAuth *myjwt.CryptJWT
 
+const (
+	AuthorizationHeader = "Authorization"
+	// Authorization头中token的前缀，不区分大小写
+	BearerPrefix = "Bearer "
+	// 查询参数和表单中的token字段
+	TokenParam = "token"
+)
+
 var once sync.Once
 
 func InitJwt() {
 	once.Do(func() {
-		jwtConfig := config.GetJwtConfig()
-		SigningKey, err := utils.HexStrToBytes(jwtConfig.SignKeyHex)
+		// 启动时检查legacy_token_cutoff的格式
+		services.GetLegacyTokenCutoff()
+		ring, err := newKeyRing(config.GetJwtKeysConfig())
 		if err != nil {
 			logrus.Panic("初始化jwt失败, error:" + err.Error())
 		}
-		var cryptoer *utils.CbcAESCrypt
-		cryptoer, err = utils.NewAESCrypt(jwtConfig.SecretHex)
+		JwtAuth = myjwt.NewJWTWithKeyRing(ring)
+		// 修改配置文件中的密钥环后无需重启
+		config.WatchJwtKeys(func(conf config.JwtKeysConfig) {
+			ring, err := newKeyRing(conf)
+			if err != nil {
+				logrus.Error("重新加载jwt密钥失败，继续使用原密钥, error: ", err)
+				return
+			}
+			JwtAuth.SetKeyRing(ring)
+			logrus.Info("已重新加载jwt密钥, 当前密钥: ", ring.CurrentID())
+		})
+	})
+}
+
+// newKeyRing 根据配置创建密钥环，未配置密钥环时使用jwt_sign_key_hex和jwt_secret_hex
+func newKeyRing(conf config.JwtKeysConfig) (*myjwt.KeyRing, error) {
+	if conf.AcceptCBC && services.GetLegacyTokenCutoff().IsZero() {
+		logrus.Warn("jwt_keys.accept_cbc已开启但未配置token.legacy_token_cutoff，AES-CBC加密的旧token仍会被拒绝")
+	}
+	if len(conf.Keys) == 0 {
+		jwtConfig := config.GetJwtConfig()
+		conf.Current = ""
+		conf.Keys = []config.JwtKeyConfig{{SignKeyHex: jwtConfig.SignKeyHex, SecretHex: jwtConfig.SecretHex}}
+	}
+	keys := make([]myjwt.Key, 0, len(conf.Keys))
+	for _, keyConf := range conf.Keys {
+		signingKey, err := utils.HexStrToBytes(keyConf.SignKeyHex)
 		if err != nil {
-			logrus.Panic("初始化jwt失败, error:" + err.Error())
+			return nil, err
 		}
-		JwtAuth = myjwt.NewJWT(SigningKey, cryptoer)
-	})
+		key := myjwt.Key{ID: keyConf.ID, SigningKey: signingKey}
+		if keyConf.SecretHex != "" {
+			// 使用AES-GCM加密，配置了accept_cbc时才解密AES-CBC加密的旧token
+			cryptoer, err := utils.NewVersionedAESCrypt(keyConf.SecretHex, conf.AcceptCBC)
+			if err != nil {
+				return nil, err
+			}
+			key.Cryptoer = cryptoer
+		}
+		keys = append(keys, key)
+	}
+	return myjwt.NewKeyRing(conf.Current, keys...)
 }
 
 // JWTMiddleWare 鉴权中间件，鉴权并设置user
@@ -41,20 +90,31 @@ func JWTMiddleWare(omitPaths ...string) gin.HandlerFunc {
 	InitJwt()
 
 	return func(c *gin.Context) {
-		tokenStr, ok := c.GetQuery("token")
-		//如果是忽略的路径，直接跳过
+		tokenStr := tokenFromRequest(c)
+		//如果是忽略的路径且没有携带token，直接跳过
 		for _, path := range omitPaths {
-			if c.FullPath() == path && !ok {
+			if c.FullPath() == path && tokenStr == "" {
 				logrus.Debug(path, "跳过鉴权")
 				c.Next()
 				return
 			}
 		}
-		if tokenStr == "" {
-			tokenStr = c.PostForm("token")
-		}
 		//验证token
-		CustomClaims, err := JwtAuth.ParseToken(tokenStr)
+		//refresh token只能用于换取新token，没有类型的旧token只在legacy_token_cutoff之前有效
+		CustomClaims, err := JwtAuth.ParseAccessToken(tokenStr, services.GetLegacyTokenCutoff())
+		//已退出登录的token
+		if err == nil {
+			revoked, rerr := isTokenRevoked(CustomClaims)
+			if rerr != nil {
+				logrus.Error("查询token吊销列表失败", rerr)
+				response.ResponseError(c, response.ErrServerInternal)
+				c.Abort()
+				return
+			}
+			if revoked {
+				err = jwt.ErrTokenInvalidId
+			}
+		}
 		//如果token过期，返回错误信息
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			c.JSON(http.StatusOK, response.CommonResponse{
@@ -85,10 +145,66 @@ func JWTMiddleWare(omitPaths ...string) gin.HandlerFunc {
 	}
 }
 
-func CreateToken(id uint, username string) (string, error) {
+// tokenFromRequest 依次从Authorization头、cookie、查询参数和表单中读取access token，
+// 没有携带token时返回空字符串
+func tokenFromRequest(c *gin.Context) string {
+	auth := c.GetHeader(AuthorizationHeader)
+	if len(auth) > len(BearerPrefix) && strings.EqualFold(auth[:len(BearerPrefix)], BearerPrefix) {
+		return strings.TrimSpace(auth[len(BearerPrefix):])
+	}
+	conf := config.GetTokenConfig()
+	if conf.CookieName != "" {
+		if tokenStr, err := c.Cookie(conf.CookieName); err == nil && tokenStr != "" {
+			return tokenStr
+		}
+	}
+	//查询参数中的token会被记录在访问日志中
+	if !conf.DisableQueryToken {
+		if tokenStr := c.Query(TokenParam); tokenStr != "" {
+			return tokenStr
+		}
+	}
+	return c.PostForm(TokenParam)
+}
+
+// CreateToken 签发指定类型的token，有效期为ttl，tokenVersion为用户当前的token版本
+func CreateToken(id uint, username string, tokenVersion uint, tokenType string, ttl time.Duration) (string, error) {
+	tokenID, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := myjwt.CustomClaims{
-		Username: username,
+		Username:     username,
+		TokenType:    tokenType,
+		TokenID:      tokenID,
+		TokenVersion: tokenVersion,
 	}
 	claims.ID = strconv.FormatUint(uint64(id), 10)
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
 	return JwtAuth.CreateToken(claims)
 }
+
+// CreateTokenPair 签发access token和refresh token，返回access token的有效期
+func CreateTokenPair(id uint, username string, tokenVersion uint) (accessToken string, refreshToken string, expiresIn time.Duration, err error) {
+	accessTTL, refreshTTL := services.GetTokenTTL()
+	accessToken, err = CreateToken(id, username, tokenVersion, myjwt.TokenTypeAccess, accessTTL)
+	if err != nil {
+		return "", "", 0, err
+	}
+	refreshToken, err = CreateToken(id, username, tokenVersion, myjwt.TokenTypeRefresh, refreshTTL)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return accessToken, refreshToken, accessTTL, nil
+}
+
+// 生成随机的token id
+func newTokenID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
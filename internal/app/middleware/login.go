@@ -3,41 +3,48 @@ package middleware
 import (
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
-	"github.com/Doraemonkeys/douyin2/utils"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/loginguard"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 // LoginRequestDto 登录请求
 type LoginRequestDto struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string `json:"username" form:"username" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
 }
 
 // UserLoginHandler 用户登录
 func UserLoginHandler(c *gin.Context) {
 	var loginRequest LoginRequestDto
 	var res response.LoginResponse
-	// if err := c.ShouldBindJSON(&loginRequest); err != nil {
-	// 	res.CommonResponse.StatusCode = response.Failed
-	// 	res.CommonResponse.StatusMsg = response.ErrInvalidParams
-	// 	c.JSON(http.StatusOK, res)
-	// 	return
-	// }
-	var ok1, ok2 bool
-	loginRequest.Username, ok1 = c.GetQuery("username")
-	loginRequest.Password, ok2 = c.GetQuery("password")
-	if !ok1 || !ok2 {
+	if err := app.BindCredentials(c, &loginRequest, config.GetPasswordConfig().AllowQueryCredentials); err != nil {
+		logrus.Debug("UserLoginHandler error: ", err)
 		res.CommonResponse.StatusCode = response.Failed
 		res.CommonResponse.StatusMsg = response.ErrInvalidParams
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	res, err := Login(loginRequest.Username, loginRequest.Password)
+	// 验证密码前先计入失败次数，并发的尝试不会在锁定前全部通过检查
+	attempt, retryAfter, err := database.GetLoginGuard().Begin(loginRequest.Username, c.ClientIP())
+	if errors.Is(err, loginguard.ErrLocked) {
+		auditLogin(c, loginRequest.Username, auditLoginLocked, nil)
+		responseLoginLocked(c, retryAfter)
+		return
+	}
+	// 存储不可用时不限制登录
+	if err != nil {
+		logrus.Error("记录登录尝试失败", err)
+	}
+	res, err = Login(loginRequest.Username, loginRequest.Password)
 	if err != nil {
 		res.CommonResponse.StatusCode = response.Failed
 		switch err.Error() {
@@ -46,13 +53,79 @@ func UserLoginHandler(c *gin.Context) {
 		case response.ErrUserPassword:
 			res.CommonResponse.StatusMsg = response.ErrUserPassword
 		default:
+			if attempt != nil {
+				if gerr := attempt.Cancel(); gerr != nil {
+					logrus.Error("撤销登录尝试失败", gerr)
+				}
+			}
 			res.CommonResponse.StatusMsg = response.ErrServerInternal
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		var result loginguard.Result
+		if attempt != nil {
+			// 用户不存在也计入失败次数，避免探测用户名
+			var gerr error
+			result, gerr = attempt.Fail()
+			if gerr != nil {
+				logrus.Error("记录登录失败次数失败", gerr)
+			}
+		}
+		auditLogin(c, loginRequest.Username, err.Error(), &result)
+		if result.LockedFor > 0 {
+			responseLoginLocked(c, result.LockedFor)
+			return
+		}
+		// 失败次数越多，响应越慢
+		if result.Delay > 0 {
+			select {
+			case <-time.After(result.Delay):
+			case <-c.Request.Context().Done():
+			}
 		}
 		c.JSON(http.StatusOK, res)
 		return
 	}
+	if attempt != nil {
+		if err := attempt.Succeed(); err != nil {
+			logrus.Error("清除登录失败次数失败", err)
+		}
+	}
 	res.CommonResponse.StatusCode = response.Success
 	app.ZeroCheck(res.UserID)
+	setTokenCookie(c, res.Token, time.Duration(res.ExpiresIn)*time.Second)
+	setRefreshTokenCookie(c, res.RefreshToken)
+	c.JSON(http.StatusOK, res)
+}
+
+// 登录被锁定时审计日志中的原因
+const auditLoginLocked = "登录已被锁定"
+
+// auditLogin 记录登录失败的审计日志，result为nil表示请求因锁定被拒绝
+func auditLogin(c *gin.Context, username string, reason string, result *loginguard.Result) {
+	fields := logrus.Fields{
+		"audit":      "login_failed",
+		"username":   username,
+		"ip":         c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+		"reason":     reason,
+	}
+	if result != nil {
+		fields["user_failures"] = result.UserFailures
+		fields["ip_failures"] = result.IPFailures
+		fields["locked_seconds"] = int(result.LockedFor.Seconds())
+	}
+	logrus.WithFields(fields).Warn("登录失败")
+}
+
+// responseLoginLocked 响应登录被锁定，retryAfter为剩余的锁定时间
+func responseLoginLocked(c *gin.Context, retryAfter time.Duration) {
+	var res response.LoginResponse
+	res.CommonResponse.StatusCode = response.LoginLocked
+	res.CommonResponse.StatusMsg = response.ErrLoginLocked
+	// 向上取整到秒
+	res.RetryAfter = int((retryAfter + time.Second - 1) / time.Second)
+	c.Header("Retry-After", strconv.Itoa(res.RetryAfter))
 	c.JSON(http.StatusOK, res)
 }
 
@@ -66,15 +139,18 @@ func Login(username string, password string) (response.LoginResponse, error) {
 		logrus.Error("QueryUserByUsername error: ", err)
 		return res, errors.New(response.ErrServerInternal)
 	}
-	if !utils.BcryptMatch(user.Password, password) {
+	// 密码正确时，使用旧算法或旧参数的hash会被重新计算
+	if !services.VerifyUserPassword(user, password) {
 		return res, errors.New(response.ErrUserPassword)
 	}
-	token, err := CreateToken(user.ID, user.Username)
+	token, refreshToken, expiresIn, err := CreateTokenPair(user.ID, user.Username, user.TokenVersion)
 	if err != nil {
-		logrus.Error("CreateToken error: ", err)
+		logrus.Error("CreateTokenPair error: ", err)
 		return res, errors.New(response.ErrServerInternal)
 	}
 	res.Token = token
+	res.RefreshToken = refreshToken
+	res.ExpiresIn = int(expiresIn.Seconds())
 	res.UserID = int(user.ID)
 	return res, nil
 }
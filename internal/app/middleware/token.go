@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	myjwt "github.com/Doraemonkeys/douyin2/pkg/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const RefreshTokenParam = "refresh_token"
+
+// refresh token的cookie只发送给刷新token和退出登录的接口
+const refreshTokenCookiePath = "/douyin/user/"
+
+const SuccLogout = "退出登录成功"
+
+// RefreshTokenDTO 请求体中的refresh token
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token"`
+}
+
+// setTokenCookie 配置了cookie名称时，将access token写入HTTP-only cookie
+func setTokenCookie(c *gin.Context, tokenStr string, ttl time.Duration) {
+	conf := config.GetTokenConfig()
+	if conf.CookieName == "" {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(conf.CookieName, tokenStr, int(ttl.Seconds()), "/", "", conf.CookieSecure, true)
+}
+
+// clearTokenCookie 删除保存access token的cookie
+func clearTokenCookie(c *gin.Context) {
+	conf := config.GetTokenConfig()
+	if conf.CookieName == "" {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(conf.CookieName, "", -1, "/", "", conf.CookieSecure, true)
+}
+
+// setRefreshTokenCookie 配置了cookie名称时，将refresh token写入HTTP-only cookie
+func setRefreshTokenCookie(c *gin.Context, tokenStr string) {
+	conf := config.GetTokenConfig()
+	if conf.RefreshCookieName == "" {
+		return
+	}
+	_, refreshTTL := services.GetTokenTTL()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(conf.RefreshCookieName, tokenStr, int(refreshTTL.Seconds()), refreshTokenCookiePath, "", conf.CookieSecure, true)
+}
+
+// clearRefreshTokenCookie 删除保存refresh token的cookie
+func clearRefreshTokenCookie(c *gin.Context) {
+	conf := config.GetTokenConfig()
+	if conf.RefreshCookieName == "" {
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(conf.RefreshCookieName, "", -1, refreshTokenCookiePath, "", conf.CookieSecure, true)
+}
+
+// refreshTokenFromRequest 依次从请求体(JSON或表单)和cookie中读取refresh token。
+// 不读取查询参数，避免refresh token出现在访问日志和浏览器历史中
+func refreshTokenFromRequest(c *gin.Context) string {
+	var dto RefreshTokenDTO
+	if err := app.BindBody(c, &dto); err == nil && dto.RefreshToken != "" {
+		return dto.RefreshToken
+	}
+	if name := config.GetTokenConfig().RefreshCookieName; name != "" {
+		if tokenStr, err := c.Cookie(name); err == nil {
+			return tokenStr
+		}
+	}
+	return ""
+}
+
+// isTokenRevoked token已退出登录，或用户在所有设备上退出登录、重置密码后签发时的版本已失效
+func isTokenRevoked(claims *myjwt.CustomClaims) (bool, error) {
+	// 旧版本签发的token没有token id，只能通过token版本吊销
+	if claims.TokenID != "" {
+		revoked, err := database.GetTokenRevocationList().IsRevoked(claims.TokenID)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	id, _ := strconv.ParseUint(claims.ID, 10, 64)
+	return services.IsTokenVersionRevoked(uint(id), claims.TokenVersion)
+}
+
+// revokeToken 吊销token直到其过期，token此前未被吊销时返回true
+func revokeToken(claims *myjwt.CustomClaims) (bool, error) {
+	// 吊销记录至少保留到token过期
+	ttl := time.Second
+	if claims.ExpiresAt != nil {
+		if remain := time.Until(claims.ExpiresAt.Time); remain > ttl {
+			ttl = remain
+		}
+	}
+	return database.GetTokenRevocationList().Revoke(claims.TokenID, ttl)
+}
+
+// RefreshTokenHandler 使用refresh token换取新的access token和refresh token。
+// 每个refresh token只能使用一次，重复使用时拒绝。
+func RefreshTokenHandler(c *gin.Context) {
+	InitJwt()
+	tokenStr := refreshTokenFromRequest(c)
+	if tokenStr == "" {
+		response.ResponseError(c, response.ErrInvalidParams)
+		return
+	}
+	claims, err := JwtAuth.ParseToken(tokenStr)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		c.JSON(http.StatusOK, response.CommonResponse{
+			StatusCode: response.TokenExpired,
+			StatusMsg:  response.ErrUserTokenExp,
+		})
+		return
+	}
+	if err != nil || claims.TokenType != myjwt.TokenTypeRefresh {
+		logrus.Debug("refresh token无效", err)
+		response.ResponseError(c, response.ErrUserToken)
+		return
+	}
+	id, _ := strconv.ParseUint(claims.ID, 10, 64)
+	// 用户已在所有设备上退出登录或重置了密码
+	revoked, err := services.IsTokenVersionRevoked(uint(id), claims.TokenVersion)
+	if err != nil {
+		logrus.Error("查询token吊销列表失败", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	if revoked {
+		response.ResponseError(c, response.ErrUserToken)
+		return
+	}
+	// 吊销旧的refresh token，并发的重复请求只有一个能成功
+	first, err := revokeToken(claims)
+	if err != nil {
+		logrus.Error("吊销refresh token失败", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	if !first {
+		logrus.Warn("refresh token被重复使用, user_id: ", claims.ID)
+		response.ResponseError(c, response.ErrUserToken)
+		return
+	}
+	var res response.RefreshTokenResponse
+	var expiresIn time.Duration
+	res.Token, res.RefreshToken, expiresIn, err = CreateTokenPair(uint(id), claims.Username, claims.TokenVersion)
+	if err != nil {
+		logrus.Error("CreateTokenPair error: ", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	res.ExpiresIn = int(expiresIn.Seconds())
+	res.StatusCode = response.Success
+	setTokenCookie(c, res.Token, expiresIn)
+	setRefreshTokenCookie(c, res.RefreshToken)
+	c.JSON(http.StatusOK, res)
+}
+
+// LogoutHandler 退出登录，吊销当前的access token，同时传入refresh_token时一并吊销。
+// 旧版本签发的token没有token id，无法单独吊销，使用旧token退出登录时在所有设备上退出登录
+func LogoutHandler(c *gin.Context) {
+	user := c.MustGet(app.UserKeyName).(app.User)
+	if user.Claims.TokenID == "" {
+		LogoutAllHandler(c)
+		return
+	}
+	if _, err := revokeToken(user.Claims); err != nil {
+		logrus.Error("吊销token失败", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	if tokenStr := refreshTokenFromRequest(c); tokenStr != "" {
+		claims, err := JwtAuth.ParseToken(tokenStr)
+		// 只能吊销自己的refresh token，已过期的无需吊销
+		if err == nil && claims.TokenType == myjwt.TokenTypeRefresh && claims.ID == user.Claims.ID {
+			if _, err := revokeToken(claims); err != nil {
+				logrus.Error("吊销refresh token失败", err)
+				response.ResponseError(c, response.ErrServerInternal)
+				return
+			}
+		}
+	}
+	clearTokenCookie(c)
+	clearRefreshTokenCookie(c)
+	response.ResponseSuccess(c, SuccLogout)
+}
+
+// LogoutAllHandler 在所有设备上退出登录，吊销用户之前签发的所有access token和refresh token
+func LogoutAllHandler(c *gin.Context) {
+	user := c.MustGet(app.UserKeyName).(app.User)
+	if err := services.RevokeUserTokens(user.ID); err != nil {
+		logrus.Error("吊销用户的所有token失败", err)
+		response.ResponseError(c, response.ErrServerInternal)
+		return
+	}
+	clearTokenCookie(c)
+	clearRefreshTokenCookie(c)
+	response.ResponseSuccess(c, SuccLogout)
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"sort"
 	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -9,10 +11,18 @@ import (
 const CommentModelTableName = "comment_models"
 
 const (
-	CommentModelTable_VideoID     = "video_id"
-	CommentModelTable_UserID      = "user_id"
-	CommentModelTable_Content     = "content"
-	CommentModelPreload_Commenter = "Commenter"
+	CommentModelTable_VideoID      = "video_id"
+	CommentModelTable_UserID       = "user_id"
+	CommentModelTable_Content      = "content"
+	CommentModelTable_ParentID     = "parent_id"
+	CommentModelTable_RootID       = "root_id"
+	CommentModelTable_ReplyCount   = "reply_count"
+	CommentModelTable_IsDeleted    = "is_deleted"
+	CommentModelTable_CreatedAt    = "created_at"
+	CommentModelTable_LikeCount    = "like_count"
+	CommentModelTable_ReviewStatus = "review_status"
+	CommentModelTable_IsHidden     = "is_hidden"
+	CommentModelPreload_Commenter  = "Commenter"
 )
 
 // 评论列表
@@ -21,20 +31,179 @@ type CommentModel struct {
 	VideoID uint
 	UserID  uint
 	Content string `gorm:"type:text"`
-	Video   VideoModel
+	// 回复的评论id，顶层评论为0
+	ParentID uint `gorm:"index"`
+	// 所在评论楼的顶层评论id，顶层评论为0
+	RootID uint `gorm:"index"`
+	// 直接回复数
+	ReplyCount uint
+	// 有回复的评论被删除时只清空内容，保留评论楼结构
+	IsDeleted bool
+	// 点赞数
+	LikeCount uint
+	// 审核状态，等待审核的评论不公开
+	ReviewStatus int `gorm:"index"`
+	// 被视频作者隐藏，隐藏的评论不公开但仍计入评论数
+	IsHidden bool
+	Video    VideoModel
 	// 使用前需确保里面有数据
 	Commenter UserModel `gorm:"foreignKey:UserID"`
 }
 
+// 评论审核状态
+const (
+	CommentReviewPassed  = 0
+	CommentReviewPending = 1
+)
+
+// 已删除评论的占位内容
+const DeletedCommentContent = "该评论已删除"
+
+// 评论排序方式
+type CommentOrder int
+
+const (
+	// 最新，按发布时间倒序
+	CommentOrderNewest CommentOrder = iota
+	// 最热，按点赞数倒序，点赞数相同时按发布时间倒序
+	CommentOrderHot
+)
+
+// CommentCursor 分页游标，为上一页最后一条评论的排序字段
+type CommentCursor struct {
+	LikeCount uint
+	CreatedAt time.Time
+	ID        uint
+}
+
+func NewCommentCursor(comment CommentModel) CommentCursor {
+	return CommentCursor{LikeCount: comment.LikeCount, CreatedAt: comment.CreatedAt, ID: comment.ID}
+}
+
+// Before 按order排序时，游标a是否排在b之前
+func (a CommentCursor) Before(b CommentCursor, order CommentOrder) bool {
+	if order == CommentOrderHot && a.LikeCount != b.LikeCount {
+		return a.LikeCount > b.LikeCount
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// CommentCacheModel 视频的顶层评论缓存，按发布时间倒序保存，并发安全
 type CommentCacheModel struct {
-	//map[commentID]CommentModel
-	CacheMap map[uint]CommentModel
-	MapLock  *sync.RWMutex
+	lock     sync.RWMutex
+	comments []CommentModel
+}
+
+// NewCommentCacheModel 创建评论缓存，comments不需要有序
+func NewCommentCacheModel(comments []CommentModel) *CommentCacheModel {
+	sorted := make([]CommentModel, len(comments))
+	copy(sorted, comments)
+	sort.Slice(sorted, func(i, j int) bool {
+		return NewCommentCursor(sorted[i]).Before(NewCommentCursor(sorted[j]), CommentOrderNewest)
+	})
+	return &CommentCacheModel{comments: sorted}
+}
+
+// 查找评论的位置(调用需要加锁)
+func (m *CommentCacheModel) index(commentID uint) int {
+	for i := range m.comments {
+		if m.comments[i].ID == commentID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Set 添加或更新评论
+func (m *CommentCacheModel) Set(comment CommentModel) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if i := m.index(comment.ID); i >= 0 {
+		m.comments = append(m.comments[:i], m.comments[i+1:]...)
+	}
+	cursor := NewCommentCursor(comment)
+	i := sort.Search(len(m.comments), func(i int) bool {
+		return cursor.Before(NewCommentCursor(m.comments[i]), CommentOrderNewest)
+	})
+	m.comments = append(m.comments, CommentModel{})
+	copy(m.comments[i+1:], m.comments[i:])
+	m.comments[i] = comment
 }
 
-func NewCommentCacheModel() CommentCacheModel {
-	return CommentCacheModel{
-		CacheMap: make(map[uint]CommentModel),
-		MapLock:  new(sync.RWMutex),
+func (m *CommentCacheModel) Get(commentID uint) (CommentModel, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if i := m.index(commentID); i >= 0 {
+		return m.comments[i], true
+	}
+	return CommentModel{}, false
+}
+
+// SetLikeCount 更新已缓存的评论的点赞数，评论不在缓存中时返回false
+func (m *CommentCacheModel) SetLikeCount(commentID uint, likeCount uint) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	i := m.index(commentID)
+	if i < 0 {
+		return false
+	}
+	m.comments[i].LikeCount = likeCount
+	return true
+}
+
+func (m *CommentCacheModel) Delete(commentID uint) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if i := m.index(commentID); i >= 0 {
+		m.comments = append(m.comments[:i], m.comments[i+1:]...)
+	}
+}
+
+func (m *CommentCacheModel) Len() int {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return len(m.comments)
+}
+
+// Page 按order排序，返回排在after之后的至多limit条评论，after为nil时从头开始。
+// id为exclude的评论(如置顶评论)不在结果中，为0时不排除。
+// hasMore表示之后是否还有评论。
+func (m *CommentCacheModel) Page(order CommentOrder, after *CommentCursor, limit int, exclude uint) (comments []CommentModel, hasMore bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	list := m.comments
+	// 需要修改时复制一份，不能修改缓存中的切片
+	copied := false
+	if i := m.index(exclude); exclude != 0 && i >= 0 {
+		list = make([]CommentModel, 0, len(m.comments)-1)
+		list = append(list, m.comments[:i]...)
+		list = append(list, m.comments[i+1:]...)
+		copied = true
+	}
+	if order != CommentOrderNewest {
+		if !copied {
+			list = make([]CommentModel, len(m.comments))
+			copy(list, m.comments)
+		}
+		// 已按发布时间倒序，稳定排序后点赞数相同的评论仍按发布时间倒序
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].LikeCount > list[j].LikeCount
+		})
+	}
+	start := 0
+	if after != nil {
+		start = sort.Search(len(list), func(i int) bool {
+			return after.Before(NewCommentCursor(list[i]), order)
+		})
+	}
+	end := start + limit
+	if end > len(list) {
+		end = len(list)
 	}
+	comments = make([]CommentModel, end-start)
+	copy(comments, list[start:end])
+	return comments, end < len(list)
 }
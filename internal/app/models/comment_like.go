@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	CommentLikeModelTableName       = "comment_like"
+	CommentLikeModelTable_UserID    = "user_id"
+	CommentLikeModelTable_CommentID = "comment_id"
+)
+
+// 评论点赞列表
+type CommentLikeModel struct {
+	UserID    uint `gorm:"primarykey"`
+	CommentID uint `gorm:"primarykey;index"`
+	CreatedAt time.Time
+}
+
+func (c *CommentLikeModel) TableName() string {
+	return CommentLikeModelTableName
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	HashtagModelTableName  = "hashtag"
+	HashtagModelTable_Name = "name"
+)
+
+// 话题
+type HashtagModel struct {
+	ID        uint   `gorm:"primarykey"`
+	Name      string `gorm:"size:200;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+func (h *HashtagModel) TableName() string {
+	return HashtagModelTableName
+}
+
+const (
+	ContentEntityModelTableName       = "content_entity"
+	ContentEntityModelTable_OwnerType = "owner_type"
+	ContentEntityModelTable_OwnerID   = "owner_id"
+	ContentEntityModelTable_Type      = "type"
+)
+
+// 实体所在文本的类型
+const (
+	EntityOwnerComment = "comment"
+	EntityOwnerVideo   = "video"
+)
+
+// 实体类型
+const (
+	EntityTypeMention = "mention"
+	EntityTypeHashtag = "hashtag"
+)
+
+// ContentEntityModel 评论内容或视频标题中的@用户和#话题
+type ContentEntityModel struct {
+	ID uint `gorm:"primarykey"`
+	// 所在文本的类型(comment或video)和id
+	OwnerType string `gorm:"size:16;index:idx_entity_owner"`
+	OwnerID   uint   `gorm:"index:idx_entity_owner"`
+	// mention或hashtag
+	Type string `gorm:"size:16"`
+	// 在文本中的字符(rune)下标，[Start, End)包含@或#前缀
+	Start int
+	End   int
+	// 被@的用户id或话题id
+	RefID     uint `gorm:"index"`
+	CreatedAt time.Time
+}
+
+func (e *ContentEntityModel) TableName() string {
+	return ContentEntityModelTableName
+}
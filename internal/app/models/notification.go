@@ -0,0 +1,40 @@
+package models
+
+import "gorm.io/gorm"
+
+const (
+	NotificationModelTableName     = "notification"
+	NotificationModelTable_UserID  = "user_id"
+	NotificationModelTable_IsRead  = "is_read"
+	NotificationModelPreload_Actor = "Actor"
+)
+
+// 通知类型
+const (
+	// 在评论或视频标题中被@
+	NotificationTypeMention = "mention"
+	// 等待人工审核的评论审核通过
+	NotificationTypeCommentApproved = "comment_approved"
+	// 等待人工审核的评论审核未通过，评论已被删除
+	NotificationTypeCommentRejected = "comment_rejected"
+)
+
+// 用户的通知收件箱
+type NotificationModel struct {
+	gorm.Model
+	// 接收通知的用户
+	UserID uint   `gorm:"index"`
+	Type   string `gorm:"size:16"`
+	// 触发通知的用户，审核结果通知为0
+	ActorID uint
+	VideoID uint
+	// 在视频标题中被@时为0
+	CommentID uint
+	IsRead    bool
+	// 使用前需确保里面有数据
+	Actor UserModel `gorm:"foreignKey:ActorID"`
+}
+
+func (n *NotificationModel) TableName() string {
+	return NotificationModelTableName
+}
@@ -1,28 +1,69 @@
 package models
 
 import (
+	"database/sql/driver"
+	"fmt"
+
 	"gorm.io/gorm"
 )
 
 const (
 	UserModelTableName              = "users_models"
 	UserModelTable_Username         = "username"
+	UserModelTable_Password         = "password"
+	UserModelTable_Email            = "email"
+	UserModelTable_EmailVerified    = "email_verified"
+	UserModelTable_TokenVersion     = "token_version"
 	UserModelTable_LikesSlice       = "Likes"
 	UserModelTable_FollowersSlice   = "Followers"
 	UserModelTable_FansSlice        = "Fans"
 	UserModelTable_CollectionsSlice = "Collections"
 	UserModelTable_FollowerCount    = "follower_count"
 	UserModelTable_FanCount         = "fan_count"
+	UserModelTable_CommentCount     = "comment_count"
 )
 
+// 邮箱唯一索引的名称，用于识别违反唯一索引的错误
+const UserModelIndex_Email = "idx_user_email"
+
 const DataBaseTimeFormat = "2006-01-02 15:04:05.000"
 
+// NullableString 空字符串在数据库中保存为NULL，唯一索引不限制多个空值
+type NullableString string
+
+func (s NullableString) Value() (driver.Value, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return string(s), nil
+}
+
+func (s *NullableString) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = NullableString(v)
+	case []byte:
+		*s = NullableString(v)
+	default:
+		return fmt.Errorf("models: cannot scan %T into NullableString", value)
+	}
+	return nil
+}
+
 // 用户
 type UserModel struct {
 	gorm.Model
 	Username string `gorm:"uniqueIndex;size:100"`
-	Password string `gorm:"size:100"`
-	Email    string `gorm:"size:100"`
+	// 密码的hash，包含算法和参数
+	Password string `gorm:"size:255"`
+	// 小写的邮箱地址，可以为空，为空时保存为NULL
+	Email NullableString `gorm:"uniqueIndex:idx_user_email;size:100"`
+	// 邮箱已通过验证，只能向已验证的邮箱发送重置密码的邮件
+	EmailVerified bool
+	// token版本，重置密码或在所有设备上退出登录时加1，签发时的版本较小的token全部失效
+	TokenVersion uint
 	//Phone    string `gorm:"uniqueIndex;size:50"`
 	//总关注数
 	FollowerCount uint `gorm:"type:int"`
@@ -53,9 +94,11 @@ func (u *UserModel) TableName() string {
 
 type UserCacheModel struct {
 	gorm.Model
-	Username string `gorm:"uniqueIndex;size:100"`
-	Password string `gorm:"size:100"`
-	Email    string `gorm:"size:100"`
+	Username      string `gorm:"uniqueIndex;size:100"`
+	Password      string `gorm:"size:100"`
+	Email         string `gorm:"size:100"`
+	EmailVerified bool
+	TokenVersion  uint
 	//Phone    string `gorm:"uniqueIndex;size:50"`
 	//总关注数
 	FollowerCount uint `gorm:"type:int"`
@@ -76,7 +119,9 @@ func (u *UserCacheModel) SetValue(user UserModel) {
 	u.DeletedAt = user.DeletedAt
 	u.Username = user.Username
 	u.Password = user.Password
-	u.Email = user.Email
+	u.Email = string(user.Email)
+	u.EmailVerified = user.EmailVerified
+	u.TokenVersion = user.TokenVersion
 	u.FollowerCount = user.FollowerCount
 	u.FanCount = user.FanCount
 	u.CommentCount = user.CommentCount
@@ -90,7 +135,9 @@ func (u *UserModel) SetValueFromCacheModel(user UserCacheModel) {
 	u.DeletedAt = user.DeletedAt
 	u.Username = user.Username
 	u.Password = user.Password
-	u.Email = user.Email
+	u.Email = NullableString(user.Email)
+	u.EmailVerified = user.EmailVerified
+	u.TokenVersion = user.TokenVersion
 	u.FollowerCount = user.FollowerCount
 	u.FanCount = user.FanCount
 	u.CommentCount = user.CommentCount
@@ -0,0 +1,25 @@
+package models
+
+import "testing"
+
+func TestNullableString(t *testing.T) {
+	if v, err := NullableString("").Value(); v != nil || err != nil {
+		t.Errorf("Value() empty = %v, %v, want nil", v, err)
+	}
+	if v, err := NullableString("a@example.com").Value(); v != "a@example.com" || err != nil {
+		t.Errorf("Value() = %v, %v", v, err)
+	}
+	s := NullableString("old")
+	if err := s.Scan(nil); err != nil || s != "" {
+		t.Errorf("Scan(nil) = %q, %v", s, err)
+	}
+	if err := s.Scan([]byte("a@example.com")); err != nil || s != "a@example.com" {
+		t.Errorf("Scan([]byte) = %q, %v", s, err)
+	}
+	if err := s.Scan("b@example.com"); err != nil || s != "b@example.com" {
+		t.Errorf("Scan(string) = %q, %v", s, err)
+	}
+	if err := s.Scan(1); err == nil {
+		t.Errorf("Scan(int) error = nil")
+	}
+}
@@ -10,6 +10,9 @@ const (
 	VideoModelTable_LikeCount        = "like_count"
 	VideoModelTable_CreatedAt        = "created_at"
 	VideoModelTable_AuthorID         = "author_id"
+	VideoModelTable_CommentCount     = "comment_count"
+	VideoModelTable_PinnedCommentID  = "pinned_comment_id"
+	VideoModelTable_CommentsDisabled = "comments_disabled"
 	VideoModelTable_LikesSlice       = "Likes"
 	VideoModelTable_CollectionsSlice = "Collections"
 )
@@ -30,9 +33,13 @@ type VideoModel struct {
 	AuthorID     uint
 	LikeCount    uint
 	CommentCount uint
-	Comments     []CommentModel `gorm:"foreignKey:VideoID"`
-	Likes        []UserModel    `gorm:"many2many:user_like;joinForeignKey:VideoID;joinReferences:UserID"`
-	Collections  []UserModel    `gorm:"many2many:user_collection;joinForeignKey:VideoID;joinReferences:UserID"`
+	// 作者置顶的评论id，没有置顶时为0
+	PinnedCommentID uint
+	// 作者关闭了评论
+	CommentsDisabled bool
+	Comments         []CommentModel `gorm:"foreignKey:VideoID"`
+	Likes            []UserModel    `gorm:"many2many:user_like;joinForeignKey:VideoID;joinReferences:UserID"`
+	Collections      []UserModel    `gorm:"many2many:user_collection;joinForeignKey:VideoID;joinReferences:UserID"`
 }
 
 func (v *VideoModel) TableName() string {
@@ -41,13 +48,15 @@ func (v *VideoModel) TableName() string {
 
 type VideoCacheModel struct {
 	gorm.Model
-	Title        string
-	StorageID    uint
-	URL          string
-	CoverURL     string
-	AuthorID     uint
-	LikeCount    uint
-	CommentCount uint
+	Title            string
+	StorageID        uint
+	URL              string
+	CoverURL         string
+	AuthorID         uint
+	LikeCount        uint
+	CommentCount     uint
+	PinnedCommentID  uint
+	CommentsDisabled bool
 	//Author       UserCacheModel
 }
 
@@ -63,6 +72,8 @@ func (v *VideoCacheModel) SetValue(other VideoModel) {
 	v.AuthorID = other.AuthorID
 	v.LikeCount = other.LikeCount
 	v.CommentCount = other.CommentCount
+	v.PinnedCommentID = other.PinnedCommentID
+	v.CommentsDisabled = other.CommentsDisabled
 }
 
 func (v *VideoModel) SetValueFromCacheModel(other VideoCacheModel) {
@@ -77,4 +88,6 @@ func (v *VideoModel) SetValueFromCacheModel(other VideoCacheModel) {
 	v.AuthorID = other.AuthorID
 	v.LikeCount = other.LikeCount
 	v.CommentCount = other.CommentCount
+	v.PinnedCommentID = other.PinnedCommentID
+	v.CommentsDisabled = other.CommentsDisabled
 }
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/mailer"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/signedcode"
+	"github.com/Doraemonkeys/douyin2/utils"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 验证码的用途
+const (
+	CodePurposeVerifyEmail   = "verify_email"
+	CodePurposeResetPassword = "reset_password"
+)
+
+const (
+	defaultVerifyCodeTTL = 24 * time.Hour
+	defaultResetCodeTTL  = 30 * time.Minute
+	defaultSendInterval  = time.Minute
+)
+
+// 邮箱地址的最大长度，与数据库字段一致
+const emailMaxLength = 100
+
+var accountMailer mailer.Mailer
+var codeSigner *signedcode.Signer
+var accountInitOnce sync.Once
+
+// InitAccount 根据配置初始化邮件发送和验证码签名
+func InitAccount() {
+	accountInitOnce.Do(func() {
+		mailConf := config.GetMailConfig()
+		switch mailConf.Driver {
+		case config.MailDriverSMTP:
+			from := mail.Address{Name: mailConf.FromName, Address: mailConf.From}
+			accountMailer = mailer.NewSMTPMailer(mailConf.Host, mailConf.Port, mailConf.Username, mailConf.Password, from)
+		case config.MailDriverLog, "":
+			accountMailer = mailer.LogMailer{}
+		default:
+			logrus.Panic("未知的邮件发送方式: ", mailConf.Driver)
+		}
+
+		var key []byte
+		if secretHex := config.GetAccountConfig().CodeSecretHex; secretHex != "" {
+			var err error
+			key, err = utils.HexStrToBytes(secretHex)
+			if err != nil {
+				logrus.Panic("验证码签名密钥不合法, error: ", err)
+			}
+		} else {
+			logrus.Warn("未配置验证码签名密钥，使用随机密钥，重启后未使用的验证码失效")
+			key = make([]byte, signedcode.MinKeyLength)
+			if _, err := rand.Read(key); err != nil {
+				logrus.Panic("生成验证码签名密钥失败, error: ", err)
+			}
+		}
+		signer, err := signedcode.NewSigner(key, database.GetUsedCodeList())
+		if err != nil {
+			logrus.Panic("初始化验证码签名失败, error: ", err)
+		}
+		codeSigner = signer
+	})
+}
+
+func getAccountDurations() (verifyTTL, resetTTL, sendInterval time.Duration) {
+	conf := config.GetAccountConfig()
+	verifyTTL = time.Duration(conf.VerifyCodeTTLSeconds) * time.Second
+	if verifyTTL <= 0 {
+		verifyTTL = defaultVerifyCodeTTL
+	}
+	resetTTL = time.Duration(conf.ResetCodeTTLSeconds) * time.Second
+	if resetTTL <= 0 {
+		resetTTL = defaultResetCodeTTL
+	}
+	sendInterval = time.Duration(conf.SendIntervalSeconds) * time.Second
+	if sendInterval <= 0 {
+		sendInterval = defaultSendInterval
+	}
+	return verifyTTL, resetTTL, sendInterval
+}
+
+// CheckEmail 检查邮箱是否合法，返回小写的邮箱地址
+func CheckEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(email)
+	// 只接受不带名称的地址
+	if err != nil || addr.Address != email || len(email) > emailMaxLength {
+		return "", errors.New(response.ErrInvalidEmail)
+	}
+	return strings.ToLower(email), nil
+}
+
+// QueryUserExistByEmail 查询邮箱是否已被使用
+func QueryUserExistByEmail(email string) (bool, error) {
+	var count int64
+	db := database.GetMysqlDB()
+	err := db.Model(&models.UserModel{}).Where(models.UserModelTable_Email+" = ?", email).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// throttleMail 限制向同一对象发送邮件的频率，key为发送对象
+func throttleMail(key string) error {
+	_, _, sendInterval := getAccountDurations()
+	first, err := database.GetMailThrottleList().Revoke(key, sendInterval)
+	if err != nil {
+		logrus.Error("query mail throttle failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	if !first {
+		return errors.New(response.ErrMailTooFrequent)
+	}
+	return nil
+}
+
+// mailBody 生成邮件正文，urlFormat不为空时附带链接
+func mailBody(username, action, code, urlFormat string, ttl time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s，您好：\n\n您正在%s，验证码为：\n\n%s\n\n", username, action, code)
+	if urlFormat != "" {
+		fmt.Fprintf(&b, "也可以打开以下链接完成操作：\n%s\n\n", fmt.Sprintf(urlFormat, code))
+	}
+	fmt.Fprintf(&b, "验证码%s内有效，只能使用一次。如果这不是您本人的操作，请忽略本邮件。\n", formatTTL(ttl))
+	return b.String()
+}
+
+// formatTTL 将有效期格式化为小时或分钟
+func formatTTL(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d小时", d/time.Hour)
+	}
+	return fmt.Sprintf("%d分钟", (d+time.Minute-1)/time.Minute)
+}
+
+// sendVerificationEmail 向用户的邮箱发送验证邮件
+func sendVerificationEmail(user models.UserModel) error {
+	InitAccount()
+	if err := throttleMail("user:" + fmt.Sprint(user.ID)); err != nil {
+		return err
+	}
+	verifyTTL, _, _ := getAccountDurations()
+	// 验证邮箱的验证码只绑定邮箱，在所有设备上退出登录后仍可使用
+	code, err := codeSigner.Issue(CodePurposeVerifyEmail, user.ID, string(user.Email), 0, verifyTTL)
+	if err != nil {
+		logrus.Error("issue verification code failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	msg := mailer.Message{
+		To:      []string{string(user.Email)},
+		Subject: "验证您的邮箱",
+		Body:    mailBody(user.Username, "验证邮箱", code, config.GetAccountConfig().VerifyURL, verifyTTL),
+	}
+	if err := accountMailer.Send(msg); err != nil {
+		logrus.Error("send verification email failed, user_id: ", user.ID, ", err: ", err)
+		return errors.New(response.ErrMailSendFailed)
+	}
+	return nil
+}
+
+// ResendVerificationEmail 重新发送验证邮件
+func ResendVerificationEmail(userID uint) error {
+	var user models.UserModel
+	db := database.GetMysqlDB()
+	if err := db.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(response.ErrUserNotExists)
+		}
+		logrus.Error("query user failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	if user.Email == "" {
+		return errors.New(response.ErrEmailNotSet)
+	}
+	if user.EmailVerified {
+		return errors.New(response.ErrEmailVerified)
+	}
+	return sendVerificationEmail(user)
+}
+
+// codeError 将验证码错误转换为响应信息
+func codeError(err error) error {
+	switch {
+	case errors.Is(err, signedcode.ErrCodeExpired):
+		return errors.New(response.ErrCodeExpired)
+	case errors.Is(err, signedcode.ErrCodeUsed):
+		return errors.New(response.ErrCodeUsed)
+	case errors.Is(err, signedcode.ErrInvalidCode):
+		return errors.New(response.ErrInvalidCode)
+	default:
+		logrus.Error("verify code failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+}
+
+// queryCodeUser 查询验证码对应的用户，签发后修改了邮箱的验证码无效
+func queryCodeUser(claims signedcode.Claims) (models.UserModel, error) {
+	var user models.UserModel
+	db := database.GetMysqlDB()
+	err := db.First(&user, claims.UserID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && string(user.Email) != claims.Email) {
+		return user, errors.New(response.ErrInvalidCode)
+	}
+	if err != nil {
+		logrus.Error("query user failed, err: ", err)
+		return user, errors.New(response.ErrServerInternal)
+	}
+	return user, nil
+}
+
+// VerifyEmail 使用验证码验证邮箱
+func VerifyEmail(code string) error {
+	InitAccount()
+	claims, err := codeSigner.Consume(code, CodePurposeVerifyEmail)
+	if err != nil {
+		return codeError(err)
+	}
+	user, err := queryCodeUser(claims)
+	if err != nil {
+		return err
+	}
+	db := database.GetMysqlDB()
+	err = db.Model(&user).Update(models.UserModelTable_EmailVerified, true).Error
+	if err != nil {
+		logrus.Error("update email verified failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	return nil
+}
+
+// RequestPasswordReset 向已验证的邮箱发送重置密码的邮件。
+// 邮箱不存在或未验证时同样返回nil，避免探测邮箱。
+func RequestPasswordReset(email string) error {
+	InitAccount()
+	email, err := CheckEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := throttleMail("email:" + email); err != nil {
+		return err
+	}
+	var user models.UserModel
+	db := database.GetMysqlDB()
+	err = db.Where(models.UserModelTable_Email+" = ? AND "+models.UserModelTable_EmailVerified+" = ?", email, true).
+		Limit(1).Find(&user).Error
+	if err != nil {
+		logrus.Error("query user by email failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	if user.ID == 0 {
+		return nil
+	}
+	_, resetTTL, _ := getAccountDurations()
+	// 绑定用户的token版本，重置密码后其余的重置验证码随之失效
+	code, err := codeSigner.Issue(CodePurposeResetPassword, user.ID, string(user.Email), user.TokenVersion, resetTTL)
+	if err != nil {
+		logrus.Error("issue reset code failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	msg := mailer.Message{
+		To:      []string{string(user.Email)},
+		Subject: "重置密码",
+		Body:    mailBody(user.Username, "重置密码", code, config.GetAccountConfig().ResetURL, resetTTL),
+	}
+	// 异步发送，响应时间与邮箱是否存在无关
+	go func() {
+		if err := accountMailer.Send(msg); err != nil {
+			logrus.Error("send reset email failed, user_id: ", user.ID, ", err: ", err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword 使用验证码重置密码，并使用户之前签发的所有token和重置验证码失效。
+// 重置失败时验证码仍可再次使用。
+func ResetPassword(code string, newPassword string) error {
+	InitAccount()
+	claims, err := codeSigner.Verify(code, CodePurposeResetPassword)
+	if err != nil {
+		return codeError(err)
+	}
+	user, err := queryCodeUser(claims)
+	if err != nil {
+		return err
+	}
+	if err := CheckPassword(newPassword, user.Username); err != nil {
+		return err
+	}
+	pwdHash, err := HashPassword(newPassword)
+	if err != nil {
+		logrus.Error("hash password failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	db := database.GetMysqlDB()
+	// 修改密码的同时使之前签发的所有token失效，被盗用的会话随之失效
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var locked models.UserModel
+		// 锁定用户，并发使用同一用户的重置验证码时只有一个能成功
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", models.UserModelTable_TokenVersion).Where("id = ?", user.ID).Find(&locked).Error
+		if err != nil {
+			return err
+		}
+		// 签发后token版本增加(已重置过密码或在所有设备上退出登录)的验证码无效
+		if locked.ID == 0 || locked.TokenVersion != claims.Version {
+			return errors.New(response.ErrInvalidCode)
+		}
+		if err := tx.Model(&user).Update(models.UserModelTable_Password, pwdHash).Error; err != nil {
+			return err
+		}
+		if err := revokeUserTokens(tx, user.ID); err != nil {
+			return err
+		}
+		// 最后使验证码失效，之前的步骤失败时验证码仍可使用
+		if _, err := codeSigner.Consume(code, CodePurposeResetPassword); err != nil {
+			return codeError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		switch err.Error() {
+		case response.ErrInvalidCode, response.ErrCodeUsed, response.ErrCodeExpired, response.ErrServerInternal:
+			return err
+		}
+		logrus.Error("update password failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	database.GetUserInfoCacher().Delete(user.ID)
+	// 重置密码后允许立即登录
+	if err := database.GetLoginGuard().Succeed(user.Username); err != nil {
+		logrus.Error("reset login failures failed, err: ", err)
+	}
+	return nil
+}
@@ -2,95 +2,499 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
 	"github.com/Doraemonkeys/douyin2/internal/database"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func CommentVideo(videoId uint, commenterID uint, commentText string) error {
+// CommentVideo 发表评论，commentID为预先分配的评论id，为0时由数据库生成。
+// held为true时评论等待人工审核，审核通过前不公开。
+func CommentVideo(commentID uint, videoId uint, commenterID uint, commentText string, held bool) error {
 	if videoId == 0 || commenterID == 0 {
 		logrus.Error("comment video failed, videoId or commenterID is 0, videoId: ", videoId, " commenterID: ", commenterID)
 	}
 	var comment models.CommentModel
+	comment.ID = commentID
 	comment.VideoID = videoId
 	comment.Content = commentText
 	comment.UserID = commenterID
 	comment.Commenter.ID = commenterID
-	err := database.GetMysqlDB().Create(&comment).Error
+	if held {
+		comment.ReviewStatus = models.CommentReviewPending
+	}
+	err := database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		if err := checkCommentsEnabledTx(tx, videoId); err != nil {
+			return err
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if _, err := saveEntitiesTx(tx, models.EntityOwnerComment, comment.ID, comment.Content); err != nil {
+			return err
+		}
+		if held {
+			return nil
+		}
+		_, err := publishCommentTx(tx, comment)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	AddVideoCommentToCache(videoId, comment)
+	if !held {
+		afterPublishComment(comment, nil)
+	}
 	return nil
 }
 
+// publishCommentTx 评论公开时更新回复的评论的回复数、视频和评论者的评论数，并通知被@的用户，
+// 返回更新后的回复的评论(顶层评论返回nil)
+func publishCommentTx(tx *gorm.DB, comment models.CommentModel) (*models.CommentModel, error) {
+	var parent *models.CommentModel
+	if comment.ParentID != 0 {
+		parent = new(models.CommentModel)
+		// 加锁读取，与并发的删除互斥
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", comment.ParentID).First(parent).Error
+		if err != nil {
+			return nil, err
+		}
+		err = tx.Model(parent).UpdateColumn(models.CommentModelTable_ReplyCount,
+			gorm.Expr(models.CommentModelTable_ReplyCount+" + 1")).Error
+		if err != nil {
+			return nil, err
+		}
+		parent.ReplyCount++
+	}
+	// 等待审核的评论在公开时才通知被@的用户
+	if err := notifyCommentMentionsTx(tx, comment); err != nil {
+		return nil, err
+	}
+	// 回复也计入评论数
+	return parent, updateCommentCounters(tx, comment.VideoID, comment.UserID, 1)
+}
+
+// afterPublishComment 评论公开后更新缓存，缓存中只有顶层评论
+func afterPublishComment(comment models.CommentModel, parent *models.CommentModel) {
+	updateCommentCountCache(comment.VideoID, comment.UserID, 1)
+	if comment.ParentID == 0 {
+		AddVideoCommentToCache(comment.VideoID, comment)
+	} else if parent != nil && parent.ParentID == 0 {
+		AddVideoCommentToCache(parent.VideoID, *parent)
+	}
+}
+
+// ReviewComment 人工审核等待审核的评论，通过时公开评论，不通过时删除评论，并通知评论者审核结果
+func ReviewComment(commentID uint, pass bool) error {
+	var comment models.CommentModel
+	var parent *models.CommentModel
+	err := database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ?", commentID).First(&comment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(ErrCommentNotExists)
+		}
+		if err != nil {
+			return err
+		}
+		if comment.ReviewStatus != models.CommentReviewPending {
+			return errors.New(ErrCommentNotPending)
+		}
+		notification := models.NotificationModel{
+			UserID:    comment.UserID,
+			Type:      models.NotificationTypeCommentRejected,
+			VideoID:   comment.VideoID,
+			CommentID: comment.ID,
+		}
+		if !pass {
+			if err := tx.Delete(&comment).Error; err != nil {
+				return err
+			}
+			return tx.Create(&notification).Error
+		}
+		err = tx.Model(&comment).UpdateColumn(models.CommentModelTable_ReviewStatus, models.CommentReviewPassed).Error
+		if err != nil {
+			return err
+		}
+		comment.ReviewStatus = models.CommentReviewPassed
+		parent, err = publishCommentTx(tx, comment)
+		if err != nil {
+			return err
+		}
+		notification.Type = models.NotificationTypeCommentApproved
+		return tx.Create(&notification).Error
+	})
+	if err != nil || !pass {
+		return err
+	}
+	afterPublishComment(comment, parent)
+	return nil
+}
+
+// QueryPendingComments 按提交顺序分页查询等待审核的评论，返回id大于afterID的至多limit条评论，
+// 返回的评论中包含Commenter信息
+func QueryPendingComments(afterID uint, limit int) ([]models.CommentModel, bool, error) {
+	var comments []models.CommentModel
+	// 多查一条判断是否还有更多
+	err := database.GetMysqlDB().Preload(models.CommentModelPreload_Commenter).
+		Where(models.CommentModelTable_ReviewStatus+" = ? AND id > ?", models.CommentReviewPending, afterID).
+		Order("id").Limit(limit + 1).Find(&comments).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(comments) > limit {
+		return comments[:limit], true, nil
+	}
+	return comments, false, nil
+}
+
+// updateCommentCounters 更新视频和评论者的评论数
+func updateCommentCounters(tx *gorm.DB, videoID, commenterID uint, delta int) error {
+	comment_count := models.VideoModelTable_CommentCount
+	err := tx.Model(&models.VideoModel{}).Where("id = ?", videoID).
+		Update(comment_count, gorm.Expr(comment_count+" + ?", delta)).Error
+	if err != nil {
+		return err
+	}
+	return updateUserCounter(tx, commenterID, models.UserModelTable_CommentCount, delta)
+}
+
+// updateCommentCountCache 更新缓存中视频和评论者的评论数(若已缓存)
+func updateCommentCountCache(videoID, commenterID uint, delta int) {
+	videoCacher := database.GetVideoInfoCacher()
+	if videoCache, exist := videoCacher.Get(videoID); exist {
+		videoCache.CommentCount = uint(int(videoCache.CommentCount) + delta)
+		videoCacher.Set(videoID, videoCache)
+	}
+	userCacher := database.GetUserInfoCacher()
+	if userCache, exist := userCacher.Get(commenterID); exist {
+		userCache.CommentCount = uint(int(userCache.CommentCount) + delta)
+		userCacher.Set(commenterID, userCache)
+	}
+}
+
+// AddVideoCommentToCache 添加或更新缓存中的评论(若视频的评论已缓存)，被隐藏的评论从缓存中移除
 func AddVideoCommentToCache(videoId uint, comment models.CommentModel) {
 	cacher := database.GetVideoCommentCacher()
 	commentChche, exist := cacher.Get(videoId)
-	if exist {
-		commentChche.MapLock.Lock()
-		commentChche.CacheMap[comment.ID] = comment
-		commentChche.MapLock.Unlock()
+	if !exist {
+		return
+	}
+	if comment.IsHidden {
+		commentChche.Delete(comment.ID)
 		return
 	}
+	commentChche.Set(comment)
 }
 
 func DeleteVideoCommentFromCache(videoId uint, commentId uint, commenterID uint) {
 	cacher := database.GetVideoCommentCacher()
 	commentChche, exist := cacher.Get(videoId)
 	if exist {
-		commentChche.MapLock.RLock()
-		comment, exist := commentChche.CacheMap[commentId]
-		commentChche.MapLock.RUnlock()
+		comment, exist := commentChche.Get(commentId)
 		if !exist || comment.UserID != commenterID {
 			logrus.Error("delete comment failed, comment not exists or not owner ", commentId, commenterID, " exist: ", exist, " comment: ", comment, "")
 			return
 		}
-		commentChche.MapLock.Lock()
-		delete(commentChche.CacheMap, commentId)
-		commentChche.MapLock.Unlock()
-		return
+		commentChche.Delete(commentId)
 	}
 }
 
-func DeleteComment(commentId uint, commenterID uint) error {
+// DeleteComment 删除评论，operatorID为评论者或视频作者。
+// 有回复的评论只清空内容并标记为已删除，保留评论楼结构；
+// 没有回复的评论直接删除，若其回复的评论也已删除且不再有回复，一并删除。
+// 评论在事务中加锁读取，与并发的回复互斥，按最新的回复数决定删除方式。
+func DeleteComment(commentId uint, operatorID uint) error {
 	var comment models.CommentModel
-	err := database.GetMysqlDB().Where("id = ?", commentId).First(&comment).Error
+	// 是否只标记为已删除
+	var softDeleted bool
+	// 被删除的评论及其上层受影响的评论
+	var deleted []models.CommentModel
+	var updatedRoot *models.CommentModel
+	err := database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		softDeleted = false
+		deleted = deleted[:0]
+		updatedRoot = nil
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", commentId).First(&comment).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New(ErrDeleteNotExists)
+		}
+		if err != nil {
+			return err
+		}
+		if comment.IsDeleted {
+			return errors.New(ErrDeleteNotExists)
+		}
+		if comment.UserID != operatorID {
+			isAuthor, err := isVideoAuthor(comment.VideoID, operatorID)
+			if err != nil {
+				return err
+			}
+			if !isAuthor {
+				return errors.New(ErrDeleteNotOwner)
+			}
+		}
+		if comment.ReviewStatus == models.CommentReviewPending {
+			// 未公开的评论没有计入评论数，也没有回复
+			return tx.Delete(&comment).Error
+		}
+		if comment.ReplyCount > 0 {
+			softDeleted = true
+			err := tx.Model(&comment).Updates(map[string]interface{}{
+				models.CommentModelTable_IsDeleted: true,
+				models.CommentModelTable_Content:   "",
+			}).Error
+			if err != nil {
+				return err
+			}
+			return updateCommentCounters(tx, comment.VideoID, comment.UserID, -1)
+		}
+		// 级联删除的评论已标记为删除，评论数在标记时已减去
+		if err := updateCommentCounters(tx, comment.VideoID, comment.UserID, -1); err != nil {
+			return err
+		}
+		cur := comment
+		for {
+			if err := tx.Delete(&cur).Error; err != nil {
+				return err
+			}
+			deleted = append(deleted, cur)
+			if cur.ParentID == 0 {
+				return nil
+			}
+			var parent models.CommentModel
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", cur.ParentID).First(&parent).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Model(&parent).UpdateColumn(models.CommentModelTable_ReplyCount,
+				gorm.Expr(models.CommentModelTable_ReplyCount+" - 1")).Error
+			if err != nil {
+				return err
+			}
+			parent.ReplyCount--
+			if !parent.IsDeleted || parent.ReplyCount > 0 {
+				if parent.ParentID == 0 {
+					updatedRoot = &parent
+				}
+				return nil
+			}
+			// 已删除的评论不再有回复，一并删除
+			cur = parent
+		}
+	})
 	if err != nil {
 		return err
 	}
-	if comment.ID == 0 {
-		return errors.New(ErrDeleteNotExists)
+	if comment.ParentID == 0 {
+		if err := unpinComment(comment.VideoID, comment.ID); err != nil {
+			return err
+		}
+	}
+	if comment.ReviewStatus == models.CommentReviewPending {
+		return nil
 	}
-	if comment.UserID != commenterID {
-		return errors.New(ErrDeleteNotOwner)
+	updateCommentCountCache(comment.VideoID, comment.UserID, -1)
+	if softDeleted {
+		if comment.ParentID == 0 {
+			comment.IsDeleted = true
+			comment.Content = ""
+			AddVideoCommentToCache(comment.VideoID, comment)
+		}
+		return nil
 	}
-	err = database.GetMysqlDB().Delete(&comment).Error
+	for _, c := range deleted {
+		if c.ParentID == 0 {
+			DeleteVideoCommentFromCache(c.VideoID, c.ID, c.UserID)
+		}
+	}
+	if updatedRoot != nil {
+		AddVideoCommentToCache(updatedRoot.VideoID, *updatedRoot)
+	}
+	return nil
+}
+
+// ReplyComment 回复评论，commentID为预先分配的评论id。
+// held为true时回复等待人工审核，审核通过前不公开。
+func ReplyComment(commentID uint, videoId uint, parentID uint, commenterID uint, commentText string, held bool) error {
+	var comment models.CommentModel
+	var parent *models.CommentModel
+	err := database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		var replied models.CommentModel
+		// 加锁读取，与并发的删除互斥，删除时能看到本次回复
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", parentID).First(&replied).Error
+		if err != nil {
+			return err
+		}
+		if replied.VideoID != videoId || replied.ReviewStatus != models.CommentReviewPassed {
+			return errors.New(ErrParam)
+		}
+		if replied.IsDeleted {
+			return errors.New(ErrReplyDeleted)
+		}
+		if replied.IsHidden {
+			return errors.New(ErrCommentNotExists)
+		}
+		if err := checkCommentsEnabledTx(tx, videoId); err != nil {
+			return err
+		}
+		comment.ID = commentID
+		comment.VideoID = videoId
+		comment.Content = commentText
+		comment.UserID = commenterID
+		comment.Commenter.ID = commenterID
+		comment.ParentID = parentID
+		comment.RootID = replied.RootID
+		if comment.RootID == 0 {
+			comment.RootID = replied.ID
+		}
+		if held {
+			comment.ReviewStatus = models.CommentReviewPending
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		if _, err := saveEntitiesTx(tx, models.EntityOwnerComment, comment.ID, comment.Content); err != nil {
+			return err
+		}
+		if held {
+			return nil
+		}
+		parent, err = publishCommentTx(tx, comment)
+		return err
+	})
 	if err != nil {
 		return err
 	}
-	DeleteVideoCommentFromCache(comment.VideoID, commentId, commenterID)
+	if !held {
+		afterPublishComment(comment, parent)
+	}
 	return nil
 }
 
+// QueryCommentReplies 按时间顺序分页查询评论的直接回复，返回回复列表和回复总数。
+// 被隐藏的评论没有公开的回复，视频关闭评论时返回ErrCommentsDisabled。
+func QueryCommentReplies(commentID uint, offset int, limit int) ([]models.CommentModel, int64, error) {
+	db := database.GetMysqlDB()
+	var replied models.CommentModel
+	err := db.Where("id = ?", commentID).Take(&replied).Error
+	if err == gorm.ErrRecordNotFound || (err == nil && replied.IsHidden) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	disabled, err := QueryCommentsDisabled(replied.VideoID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if disabled {
+		return nil, 0, errors.New(ErrCommentsDisabled)
+	}
+	var total int64
+	var replies []models.CommentModel
+	parent_id := models.CommentModelTable_ParentID
+	review_status := models.CommentModelTable_ReviewStatus
+	is_hidden := models.CommentModelTable_IsHidden
+	err = db.Model(&models.CommentModel{}).Where(parent_id+" = ? AND "+review_status+" = ? AND "+is_hidden+" = ?",
+		commentID, models.CommentReviewPassed, false).Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	err = db.Preload(models.CommentModelPreload_Commenter).
+		Where(parent_id+" = ? AND "+review_status+" = ? AND "+is_hidden+" = ?", commentID, models.CommentReviewPassed, false).
+		Order(models.CommentModelTable_CreatedAt + " asc, id asc").
+		Offset(offset).Limit(limit).Find(&replies).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return replies, total, nil
+}
+
 func QueryCommentListWithCommenterByVideoID(videoId uint) ([]models.CommentModel, error) {
 	db := database.GetMysqlDB()
 	var commentList []models.CommentModel
 	Commenter := models.CommentModelPreload_Commenter
 	video_id := models.CommentModelTable_VideoID
-	err := db.Preload(Commenter).Where(video_id+" = ?", videoId).Find(&commentList).Error
+	parent_id := models.CommentModelTable_ParentID
+	review_status := models.CommentModelTable_ReviewStatus
+	is_hidden := models.CommentModelTable_IsHidden
+	// 只查询已公开的顶层评论，回复通过QueryCommentReplies查询
+	err := db.Preload(Commenter).Where(video_id+" = ? AND "+parent_id+" = 0 AND "+review_status+" = ? AND "+is_hidden+" = ?",
+		videoId, models.CommentReviewPassed, false).Find(&commentList).Error
 	if err != nil {
 		logrus.Error("query comment list failed, err: ", err)
 		return commentList, err
 	}
 	logrus.Info("query comment list success, comment list: ", commentList)
 	// 存入缓存
-	cacher := database.GetVideoCommentCacher()
-	var commentCache = models.NewCommentCacheModel()
-	for _, comment := range commentList {
-		commentCache.CacheMap[comment.ID] = comment
-	}
-	cacher.Set(videoId, commentCache)
+	database.GetVideoCommentCacher().Set(videoId, models.NewCommentCacheModel(commentList))
 	return commentList, nil
 }
+
+// QueryCommentPage 按order排序分页查询视频的顶层评论，返回排在after之后的至多limit条评论。
+// 第一页(after为nil)额外返回作者置顶的评论pinned，置顶评论不出现在comments中。
+// 视频关闭评论时返回ErrCommentsDisabled。
+// 评论列表优先从缓存中获取，返回的评论中Commenter可能为空。
+func QueryCommentPage(videoId uint, order models.CommentOrder, after *models.CommentCursor, limit int) (
+	pinned *models.CommentModel, comments []models.CommentModel, hasMore bool, err error) {
+	video, err := GetVideoInfoByID(videoId)
+	if err != nil && err.Error() == response.ErrVideoNotExists {
+		return nil, nil, false, nil
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if video.CommentsDisabled {
+		return nil, nil, false, errors.New(ErrCommentsDisabled)
+	}
+	commentCache, exist := database.GetVideoCommentCacher().Get(videoId)
+	if !exist {
+		commentList, err := QueryCommentListWithCommenterByVideoID(videoId)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		commentCache = models.NewCommentCacheModel(commentList)
+	}
+	if after == nil && video.PinnedCommentID != 0 {
+		// 置顶的评论被删除或隐藏时已取消置顶，缓存中不存在时忽略
+		if comment, exist := commentCache.Get(video.PinnedCommentID); exist {
+			pinned = &comment
+		}
+	}
+	comments, hasMore = commentCache.Page(order, after, limit, video.PinnedCommentID)
+	return pinned, comments, hasMore, nil
+}
+
+// BackfillCommentCounts 根据评论表重新计算所有视频和用户的评论数，
+// 返回评论数被修正的视频数和用户数。已删除(含保留占位)的评论不计入。
+func BackfillCommentCounts() (videoNum int64, userNum int64, err error) {
+	db := database.GetMysqlDB()
+	countSQL := "SELECT COUNT(*) FROM " + models.CommentModelTableName + " c WHERE c.%s = t.id" +
+		" AND c.deleted_at IS NULL AND c." + models.CommentModelTable_IsDeleted + " = false" +
+		" AND c." + models.CommentModelTable_ReviewStatus + " = " + strconv.Itoa(models.CommentReviewPassed)
+	result := db.Exec("UPDATE " + models.VideoModelTableName + " t SET t." + models.VideoModelTable_CommentCount +
+		" = (" + fmt.Sprintf(countSQL, models.CommentModelTable_VideoID) + ")")
+	if result.Error != nil {
+		return 0, 0, result.Error
+	}
+	videoNum = result.RowsAffected
+	result = db.Exec("UPDATE " + models.UserModelTableName + " t SET t." + models.UserModelTable_CommentCount +
+		" = (" + fmt.Sprintf(countSQL, models.CommentModelTable_UserID) + ")")
+	if result.Error != nil {
+		return videoNum, 0, result.Error
+	}
+	userNum = result.RowsAffected
+	// 缓存中的评论数可能已过期(单独运行回填命令时缓存未初始化)
+	if cacher := database.GetVideoInfoCacher(); cacher != nil {
+		cacher.ClearAll()
+	}
+	if cacher := database.GetUserInfoCacher(); cacher != nil {
+		cacher.ClearAll()
+	}
+	return videoNum, userNum, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"gorm.io/gorm"
+)
+
+// LikeComment 点赞评论
+func LikeComment(userID, commentID uint) error {
+	return updateCommentLike(userID, commentID, true)
+}
+
+// UnlikeComment 取消点赞评论
+func UnlikeComment(userID, commentID uint) error {
+	return updateCommentLike(userID, commentID, false)
+}
+
+func updateCommentLike(userID, commentID uint, isLike bool) error {
+	var comment models.CommentModel
+	err := database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", commentID).First(&comment).Error; err != nil {
+			return err
+		}
+		// 未公开的评论不能点赞，取消点赞不受影响
+		if isLike && !isCommentPublic(comment) {
+			return errors.New(ErrCommentNotPublic)
+		}
+		like := models.CommentLikeModel{UserID: userID, CommentID: commentID}
+		delta := 1
+		if isLike {
+			if err := tx.Create(&like).Error; err != nil {
+				if strings.HasPrefix(err.Error(), MysqlDuplicatePrefix) {
+					return errors.New(ErrDuplicate)
+				}
+				return err
+			}
+		} else {
+			result := tx.Delete(&like)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New(ErrDeleteNotExists)
+			}
+			delta = -1
+		}
+		like_count := models.CommentModelTable_LikeCount
+		comment.LikeCount = uint(int(comment.LikeCount) + delta)
+		return tx.Model(&comment).UpdateColumn(like_count, gorm.Expr(like_count+" + ?", delta)).Error
+	})
+	if err != nil {
+		return err
+	}
+	// 缓存中只有公开的顶层评论，只更新已缓存的评论，不会把未公开的评论加入缓存
+	if comment.ParentID == 0 {
+		if commentCache, exist := database.GetVideoCommentCacher().Get(comment.VideoID); exist {
+			commentCache.SetLikeCount(comment.ID, comment.LikeCount)
+		}
+	}
+	return nil
+}
+
+// isCommentPublic 评论是否对所有人可见
+func isCommentPublic(comment models.CommentModel) bool {
+	return comment.ReviewStatus == models.CommentReviewPassed && !comment.IsHidden && !comment.IsDeleted
+}
+
+// QueryCommentLikedMap 查询用户点赞了哪些评论，返回map[commentID]是否点赞
+func QueryCommentLikedMap(userID uint, commentIDs []uint) (map[uint]bool, error) {
+	liked := make(map[uint]bool, len(commentIDs))
+	if userID == 0 || len(commentIDs) == 0 {
+		return liked, nil
+	}
+	var likedIDs []uint
+	err := database.GetMysqlDB().Model(&models.CommentLikeModel{}).
+		Where(models.CommentLikeModelTable_UserID+" = ? AND "+models.CommentLikeModelTable_CommentID+" IN ?", userID, commentIDs).
+		Pluck(models.CommentLikeModelTable_CommentID, &likedIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	return liked, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"gorm.io/gorm"
+)
+
+const (
+	// 不是视频作者
+	ErrNotVideoAuthor = "只有视频作者可以管理评论"
+	// 视频作者关闭了评论
+	ErrCommentsDisabled = "该视频已关闭评论"
+	// 评论不存在或已删除
+	ErrCommentNotExists = "评论不存在"
+	// 审核的评论不是等待审核的状态
+	ErrCommentNotPending = "评论不需要审核"
+	// 只能置顶公开的顶层评论
+	ErrCannotPin = "只能置顶公开的顶层评论"
+)
+
+// GetVideoInfoByID 从Cache中或MySQL中查询视频信息，不含作者信息。
+// 视频不存在时返回ErrVideoNotExists。
+func GetVideoInfoByID(videoID uint) (models.VideoModel, error) {
+	var video models.VideoModel
+	cacher := database.GetVideoInfoCacher()
+	if videoCache, exist := cacher.Get(videoID); exist {
+		video.SetValueFromCacheModel(videoCache)
+		return video, nil
+	}
+	video, err := QueryVideoInfoByID(videoID)
+	if err == gorm.ErrRecordNotFound {
+		return video, errors.New(response.ErrVideoNotExists)
+	}
+	if err != nil {
+		return video, err
+	}
+	var videoCache models.VideoCacheModel
+	videoCache.SetValue(video)
+	cacher.Set(videoID, videoCache)
+	return video, nil
+}
+
+// QueryCommentsDisabled 查询视频作者是否关闭了评论
+func QueryCommentsDisabled(videoID uint) (bool, error) {
+	video, err := GetVideoInfoByID(videoID)
+	if err != nil {
+		return false, err
+	}
+	return video.CommentsDisabled, nil
+}
+
+// checkCommentsEnabledTx 发表评论前检查视频是否关闭了评论
+func checkCommentsEnabledTx(tx *gorm.DB, videoID uint) error {
+	var video models.VideoModel
+	err := tx.Select("id", models.VideoModelTable_CommentsDisabled).Where("id = ?", videoID).Take(&video).Error
+	if err == gorm.ErrRecordNotFound {
+		return errors.New(response.ErrVideoNotExists)
+	}
+	if err != nil {
+		return err
+	}
+	if video.CommentsDisabled {
+		return errors.New(ErrCommentsDisabled)
+	}
+	return nil
+}
+
+// isVideoAuthor operatorID是否是视频作者
+func isVideoAuthor(videoID uint, operatorID uint) (bool, error) {
+	video, err := GetVideoInfoByID(videoID)
+	if err != nil {
+		return false, err
+	}
+	return video.AuthorID == operatorID, nil
+}
+
+// updateVideoCommentSetting 更新视频的评论设置，并同步到缓存(若已缓存)
+func updateVideoCommentSetting(videoID uint, column string, value interface{}, update func(*models.VideoCacheModel)) error {
+	err := database.GetMysqlDB().Model(&models.VideoModel{}).Where("id = ?", videoID).UpdateColumn(column, value).Error
+	if err != nil {
+		return err
+	}
+	cacher := database.GetVideoInfoCacher()
+	if videoCache, exist := cacher.Get(videoID); exist {
+		update(&videoCache)
+		cacher.Set(videoID, videoCache)
+	}
+	return nil
+}
+
+// unpinComment 评论被删除或隐藏时取消置顶(若已置顶)
+func unpinComment(videoID uint, commentID uint) error {
+	video, err := GetVideoInfoByID(videoID)
+	if err != nil || video.PinnedCommentID != commentID {
+		return err
+	}
+	return updateVideoCommentSetting(videoID, models.VideoModelTable_PinnedCommentID, 0, func(v *models.VideoCacheModel) {
+		v.PinnedCommentID = 0
+	})
+}
+
+// PinComment 视频作者置顶评论，commentID为0时取消置顶。每个视频最多置顶一条评论，
+// 置顶新的评论会替换原来的置顶评论。
+func PinComment(videoID uint, commentID uint, operatorID uint) error {
+	isAuthor, err := isVideoAuthor(videoID, operatorID)
+	if err != nil {
+		return err
+	}
+	if !isAuthor {
+		return errors.New(ErrNotVideoAuthor)
+	}
+	if commentID != 0 {
+		var comment models.CommentModel
+		err := database.GetMysqlDB().Where("id = ?", commentID).Take(&comment).Error
+		if err == gorm.ErrRecordNotFound {
+			return errors.New(ErrCommentNotExists)
+		}
+		if err != nil {
+			return err
+		}
+		if comment.VideoID != videoID || comment.ParentID != 0 || comment.IsDeleted || comment.IsHidden ||
+			comment.ReviewStatus != models.CommentReviewPassed {
+			return errors.New(ErrCannotPin)
+		}
+	}
+	return updateVideoCommentSetting(videoID, models.VideoModelTable_PinnedCommentID, commentID, func(v *models.VideoCacheModel) {
+		v.PinnedCommentID = commentID
+	})
+}
+
+// SetCommentsDisabled 视频作者关闭或开启评论。关闭评论后不能发表新评论，评论列表为空。
+func SetCommentsDisabled(videoID uint, operatorID uint, disabled bool) error {
+	isAuthor, err := isVideoAuthor(videoID, operatorID)
+	if err != nil {
+		return err
+	}
+	if !isAuthor {
+		return errors.New(ErrNotVideoAuthor)
+	}
+	return updateVideoCommentSetting(videoID, models.VideoModelTable_CommentsDisabled, disabled, func(v *models.VideoCacheModel) {
+		v.CommentsDisabled = disabled
+	})
+}
+
+// HideComment 视频作者隐藏或取消隐藏评论。
+// 隐藏的评论及其回复不出现在列表中，但仍计入评论数，被隐藏的置顶评论会取消置顶。
+func HideComment(commentID uint, operatorID uint, hidden bool) error {
+	var comment models.CommentModel
+	err := database.GetMysqlDB().Where("id = ?", commentID).Take(&comment).Error
+	if err == gorm.ErrRecordNotFound || (err == nil && comment.IsDeleted) {
+		return errors.New(ErrCommentNotExists)
+	}
+	if err != nil {
+		return err
+	}
+	isAuthor, err := isVideoAuthor(comment.VideoID, operatorID)
+	if err != nil {
+		return err
+	}
+	if !isAuthor {
+		return errors.New(ErrNotVideoAuthor)
+	}
+	if comment.IsHidden == hidden {
+		return nil
+	}
+	err = database.GetMysqlDB().Model(&comment).UpdateColumn(models.CommentModelTable_IsHidden, hidden).Error
+	if err != nil {
+		return err
+	}
+	comment.IsHidden = hidden
+	if hidden {
+		if err := unpinComment(comment.VideoID, comment.ID); err != nil {
+			return err
+		}
+	}
+	// 缓存中只有公开的顶层评论
+	if comment.ParentID != 0 || comment.ReviewStatus != models.CommentReviewPassed {
+		return nil
+	}
+	if hidden {
+		removeVideoCommentFromCache(comment.VideoID, comment.ID)
+	} else {
+		AddVideoCommentToCache(comment.VideoID, comment)
+	}
+	return nil
+}
+
+// removeVideoCommentFromCache 从缓存中移除评论(若视频的评论已缓存)
+func removeVideoCommentFromCache(videoID uint, commentID uint) {
+	if commentCache, exist := database.GetVideoCommentCacher().Get(videoID); exist {
+		commentCache.Delete(commentID)
+	}
+}
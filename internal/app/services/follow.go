@@ -101,6 +101,130 @@ func UnfollowUser(userID, toUserID uint) error {
 	return nil
 }
 
+// FollowAction 一次关注或取消关注操作
+type FollowAction struct {
+	UserID   uint
+	ToUserID uint
+	// true-关注，false-取消关注
+	IsFollow bool
+}
+
+type followPair struct {
+	UserID   uint
+	ToUserID uint
+}
+
+// BatchFollowUser 批量关注/取消关注，actions需按发生的顺序排列。
+// 同一用户对同一用户的多次操作只保留最后一次，再与数据库中的关注记录比较，
+// 关注数和粉丝数按用户聚合后在同一个事务中更新。
+func BatchFollowUser(actions []FollowAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	// 1. 相互抵消，保留每个(用户,对方用户)的最终状态
+	finalState := make(map[followPair]bool, len(actions))
+	pairs := make([][]interface{}, 0, len(actions))
+	for _, action := range actions {
+		pair := followPair{UserID: action.UserID, ToUserID: action.ToUserID}
+		if _, exist := finalState[pair]; !exist {
+			pairs = append(pairs, []interface{}{action.UserID, action.ToUserID})
+		}
+		finalState[pair] = action.IsFollow
+	}
+
+	db := database.GetMysqlDB()
+	user_id := models.UserFollowerModelTable_UserID
+	follower_id := models.UserFollowerModelTable_FollowerID
+	// 2. 查询已存在的关注记录
+	var existFollows []models.UserFollowerModel
+	err := db.Where("("+user_id+", "+follower_id+") IN ?", pairs).Find(&existFollows).Error
+	if err != nil {
+		return err
+	}
+	followed := make(map[followPair]bool, len(existFollows))
+	for _, follow := range existFollows {
+		followed[followPair{UserID: follow.UserID, ToUserID: follow.FollowerID}] = true
+	}
+	var toCreate []models.UserFollowerModel
+	var toDelete [][]interface{}
+	followerCountDelta := make(map[uint]int)
+	fanCountDelta := make(map[uint]int)
+	for pair, isFollow := range finalState {
+		if isFollow == followed[pair] {
+			continue
+		}
+		if isFollow {
+			toCreate = append(toCreate, models.UserFollowerModel{UserID: pair.UserID, FollowerID: pair.ToUserID})
+			followerCountDelta[pair.UserID]++
+			fanCountDelta[pair.ToUserID]++
+		} else {
+			toDelete = append(toDelete, []interface{}{pair.UserID, pair.ToUserID})
+			followerCountDelta[pair.UserID]--
+			fanCountDelta[pair.ToUserID]--
+		}
+	}
+	if len(toCreate) == 0 && len(toDelete) == 0 {
+		return nil
+	}
+
+	// 3. 在一个事务中写入关注记录、关注数和粉丝数
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(toCreate) > 0 {
+			if err := tx.Create(&toCreate).Error; err != nil {
+				return err
+			}
+		}
+		if len(toDelete) > 0 {
+			err := tx.Where("("+user_id+", "+follower_id+") IN ?", toDelete).Delete(&models.UserFollowerModel{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		follower_count := models.UserModelTable_FollowerCount
+		for userID, delta := range followerCountDelta {
+			if err := updateUserCounter(tx, userID, follower_count, delta); err != nil {
+				return err
+			}
+		}
+		fan_count := models.UserModelTable_FanCount
+		for userID, delta := range fanCountDelta {
+			if err := updateUserCounter(tx, userID, fan_count, delta); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 4. 更新缓存
+	userCacher := database.GetUserInfoCacher()
+	for userID, delta := range followerCountDelta {
+		if userCache, exist := userCacher.Get(userID); exist {
+			userCache.FollowerCount = uint(int(userCache.FollowerCount) + delta)
+			userCacher.Set(userID, userCache)
+		}
+	}
+	for userID, delta := range fanCountDelta {
+		if userCache, exist := userCacher.Get(userID); exist {
+			userCache.FanCount = uint(int(userCache.FanCount) + delta)
+			userCacher.Set(userID, userCache)
+		}
+	}
+	return nil
+}
+
+// updateUserCounter 在事务中给用户的计数字段加上delta
+func updateUserCounter(tx *gorm.DB, userID uint, column string, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	var user models.UserModel
+	user.ID = userID
+	return tx.Model(&user).Update(column, gorm.Expr(column+" + ?", delta)).Error
+}
+
 func QueryFollowUserListByUserID(userID uint) ([]models.UserModel, error) {
 	db := database.GetMysqlDB()
 	var user models.UserModel
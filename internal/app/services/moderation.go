@@ -0,0 +1,88 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/moderation"
+	"github.com/sirupsen/logrus"
+)
+
+// 评论默认最大字符数
+const defaultCommentMaxRunes = 500
+
+var commentModerator *moderation.Chain
+var titleModerator *moderation.Chain
+var moderationInitOnce sync.Once
+
+// InitModeration 根据配置初始化评论和视频标题的审核规则
+func InitModeration() {
+	moderationInitOnce.Do(func() {
+		conf := config.GetModerationConfig()
+		words := moderation.NewSensitiveWordFilter()
+		if conf.SensitiveWordsFile != "" {
+			if err := loadSensitiveWords(words, conf.SensitiveWordsFile); err != nil {
+				logrus.Error("加载敏感词词典失败, error: ", err)
+			}
+		}
+
+		maxRunes := conf.CommentMaxRunes
+		if maxRunes <= 0 {
+			maxRunes = defaultCommentMaxRunes
+		}
+		commentFilters := []moderation.Filter{
+			moderation.LengthFilter{MaxRunes: maxRunes},
+			words,
+			moderation.LinkFilter{MaxLinks: conf.CommentMaxLinks},
+		}
+		if conf.RateWindowSeconds > 0 {
+			window := time.Duration(conf.RateWindowSeconds) * time.Second
+			commentFilters = append(commentFilters,
+				moderation.NewRateFilter(window, conf.RateMaxCount, conf.RateMaxDuplicate, moderation.Hold))
+		}
+		commentModerator = moderation.NewChain(commentFilters...)
+
+		titleModerator = moderation.NewChain(
+			moderation.LengthFilter{MaxRunes: models.VideoTitleMaxRuneLength, MaxBytes: models.VideoTitleMaxByteLength},
+			words,
+		)
+	})
+}
+
+func loadSensitiveWords(words *moderation.SensitiveWordFilter, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return words.LoadWords(f)
+}
+
+// ModerateComment 审核评论内容
+func ModerateComment(commenterID uint, text string) moderation.Result {
+	InitModeration()
+	return commentModerator.Check(moderation.Content{UserID: commenterID, Text: text})
+}
+
+// IsModerator 用户是否可以人工审核评论
+func IsModerator(userID uint) bool {
+	for _, id := range config.GetModerationConfig().ModeratorIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ModerateTitle 审核视频标题，标题没有人工审核，Hold视为Reject
+func ModerateTitle(authorID uint, title string) moderation.Result {
+	InitModeration()
+	res := titleModerator.Check(moderation.Content{UserID: authorID, Text: title})
+	if res.Action == moderation.Hold {
+		res.Action = moderation.Reject
+	}
+	return res
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+)
+
+// QueryNotifications 按时间倒序分页查询用户的通知，返回id小于beforeID的至多limit条通知，
+// beforeID为0时从最新的通知开始。返回的通知中包含Actor信息。
+func QueryNotifications(userID uint, beforeID uint, limit int) ([]models.NotificationModel, bool, error) {
+	db := database.GetMysqlDB().Preload(models.NotificationModelPreload_Actor).
+		Where(models.NotificationModelTable_UserID+" = ?", userID)
+	if beforeID != 0 {
+		db = db.Where("id < ?", beforeID)
+	}
+	var notifications []models.NotificationModel
+	// 多查一条判断是否还有更多
+	err := db.Order("id desc").Limit(limit + 1).Find(&notifications).Error
+	if err != nil {
+		return nil, false, err
+	}
+	if len(notifications) > limit {
+		return notifications[:limit], true, nil
+	}
+	return notifications, false, nil
+}
+
+// CountUnreadNotifications 查询用户的未读通知数
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := database.GetMysqlDB().Model(&models.NotificationModel{}).
+		Where(models.NotificationModelTable_UserID+" = ? AND "+models.NotificationModelTable_IsRead+" = ?", userID, false).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkNotificationsRead 将用户的通知标记为已读，notificationID为0时标记全部通知
+func MarkNotificationsRead(userID uint, notificationID uint) error {
+	db := database.GetMysqlDB().Model(&models.NotificationModel{}).
+		Where(models.NotificationModelTable_UserID+" = ? AND "+models.NotificationModelTable_IsRead+" = ?", userID, false)
+	if notificationID != 0 {
+		db = db.Where("id = ?", notificationID)
+	}
+	return db.Update(models.NotificationModelTable_IsRead, true).Error
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/utils"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultPasswordMinLength      = 8
+	defaultPasswordMaxLength      = 64
+	defaultPasswordMinCharClasses = 2
+)
+
+var passwordHasher utils.PasswordHasher
+var passwordPolicy *utils.PasswordPolicy
+var passwordInitOnce sync.Once
+
+// InitPassword 根据配置初始化密码hash算法和密码策略
+func InitPassword() {
+	passwordInitOnce.Do(func() {
+		conf := config.GetPasswordConfig()
+		switch conf.Algorithm {
+		case config.PasswordAlgorithmArgon2id:
+			passwordHasher = utils.NewArgon2idHasher(utils.Argon2idParams{
+				Time:    conf.Argon2Time,
+				Memory:  conf.Argon2MemoryKiB,
+				Threads: conf.Argon2Threads,
+			})
+		case config.PasswordAlgorithmBcrypt, "":
+			hasher, err := utils.NewBcryptHasher(conf.BcryptCost)
+			if err != nil {
+				logrus.Panic("初始化密码hash失败, error: ", err)
+			}
+			passwordHasher = hasher
+		default:
+			logrus.Panic("未知的密码hash算法: ", conf.Algorithm)
+		}
+
+		minLength, maxLength, minCharClasses := conf.MinLength, conf.MaxLength, conf.MinCharClasses
+		if minLength <= 0 {
+			minLength = defaultPasswordMinLength
+		}
+		if maxLength <= 0 {
+			maxLength = defaultPasswordMaxLength
+		}
+		if minCharClasses <= 0 {
+			minCharClasses = defaultPasswordMinCharClasses
+		}
+		passwordPolicy = utils.NewPasswordPolicy(minLength, maxLength, minCharClasses)
+		if conf.BlocklistFile != "" {
+			if err := loadPasswordBlocklist(conf.BlocklistFile); err != nil {
+				logrus.Error("加载常见密码黑名单失败, error: ", err)
+			}
+		}
+	})
+}
+
+func loadPasswordBlocklist(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return passwordPolicy.LoadBlocklist(f)
+}
+
+// CheckPassword 检查密码是否符合密码策略
+func CheckPassword(password string, username string) error {
+	InitPassword()
+	switch err := passwordPolicy.Check(password, username); err {
+	case nil:
+		return nil
+	case utils.ErrPasswordTooShort:
+		return errors.New(response.ErrPasswordTooShort)
+	case utils.ErrPasswordTooLong:
+		return errors.New(response.ErrPasswordTooLong)
+	case utils.ErrPasswordTooSimple:
+		return errors.New(response.ErrPasswordTooSimple)
+	case utils.ErrPasswordCommon:
+		return errors.New(response.ErrPasswordCommon)
+	case utils.ErrPasswordContainsUsername:
+		return errors.New(response.ErrPasswordContainsUsername)
+	default:
+		return errors.New(response.ErrInvalidPassword)
+	}
+}
+
+// HashPassword 使用配置的算法计算密码的hash
+func HashPassword(password string) (string, error) {
+	InitPassword()
+	return passwordHasher.Hash(password)
+}
+
+// VerifyUserPassword 比对用户的密码。
+// 密码正确且hash的算法或参数与当前配置不同时，重新计算hash并保存，失败时只记录日志。
+func VerifyUserPassword(user models.UserModel, password string) bool {
+	InitPassword()
+	ok, err := utils.VerifyPassword(user.Password, password)
+	if err != nil {
+		logrus.Error("verify password failed, user_id: ", user.ID, ", err: ", err)
+		return false
+	}
+	if !ok || !passwordHasher.NeedsRehash(user.Password) {
+		return ok
+	}
+	newHash, err := passwordHasher.Hash(password)
+	if err != nil {
+		logrus.Error("rehash password failed, err: ", err)
+		return true
+	}
+	// 只在密码未被修改时更新，缓存中的密码不用于登录，无需更新
+	db := database.GetMysqlDB()
+	err = db.Model(&models.UserModel{}).
+		Where("id = ? AND "+models.UserModelTable_Password+" = ?", user.ID, user.Password).
+		Update(models.UserModelTable_Password, newHash).Error
+	if err != nil {
+		logrus.Error("save rehashed password failed, err: ", err)
+	}
+	return true
+}
@@ -11,40 +11,68 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// CheckPassword 检查密码是否符合要求
-func CheckPassword(password string) bool {
-	if len(password) < 6 || len(password) > 32 {
-		return false
-	}
-	return true
-}
-
 // CheckUsername 检查用户名是否符合要求
 func CheckUsername(username string) bool {
 	return !strings.Contains(username, " ") && len(username) >= 2 && len(username) <= 32
 }
 
-func CreateUser(username string, rawPassword string) (response.RegisterResponse, error) {
+// CreateUser 创建用户，email可以为空，不为空时向其发送验证邮件
+func CreateUser(username string, rawPassword string, email string) (response.RegisterResponse, error) {
 	var res response.RegisterResponse
 	if !CheckUsername(username) {
 		return res, errors.New(response.ErrInvalidUsername)
 	}
-	if !CheckPassword(rawPassword) {
-		return res, errors.New(response.ErrInvalidPassword)
+	if err := CheckPassword(rawPassword, username); err != nil {
+		return res, err
 	}
 	if QueryUserExistByUsername(username) {
 		return res, errors.New(response.ErrUserExists)
 	}
-	pwdHash := utils.BcryptHash(rawPassword)
+	if email != "" {
+		var err error
+		if email, err = CheckEmail(email); err != nil {
+			return res, err
+		}
+		exist, err := QueryUserExistByEmail(email)
+		if err != nil {
+			logrus.Error("query user by email failed, err: ", err)
+			return res, errors.New(response.ErrServerInternal)
+		}
+		if exist {
+			return res, errors.New(response.ErrEmailExists)
+		}
+	}
+	pwdHash, err := HashPassword(rawPassword)
+	if err != nil {
+		logrus.Error("hash password failed, err: ", err)
+		return res, errors.New(response.ErrServerInternal)
+	}
 	user := models.UserModel{
 		Username: username,
 		Password: pwdHash,
+		Email:    models.NullableString(email),
 	}
 	id, err := createUser(user)
+	// 之前的检查与插入之间可能有并发的注册，以唯一索引为准
+	if utils.IsMysqlDuplicateKey(err, models.UserModelIndex_Email) {
+		return res, errors.New(response.ErrEmailExists)
+	}
 	if err != nil {
+		if strings.HasPrefix(err.Error(), MysqlDuplicatePrefix) {
+			return res, errors.New(response.ErrUserExists)
+		}
 		logrus.Error("create user failed, err: ", err)
 		return res, errors.New(response.ErrServerInternal)
 	}
+	if email != "" {
+		user.ID = id
+		// 发送失败不影响注册，用户可以重新发送
+		go func() {
+			if err := sendVerificationEmail(user); err != nil {
+				logrus.Warn("send verification email after register failed, user_id: ", id, ", err: ", err)
+			}
+		}()
+	}
 	res.CommonResponse.StatusCode = response.Success
 	res.UserID = int(id)
 	return res, nil
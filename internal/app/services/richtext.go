@@ -0,0 +1,227 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/richtext"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 话题不区分大小写
+func normalizeHashtag(name string) string {
+	return strings.ToLower(name)
+}
+
+// saveEntitiesTx 解析text中的@用户和#话题，保存为ownerType/ownerID的实体。
+// 不存在的用户名被忽略，不存在的话题会被创建。
+func saveEntitiesTx(tx *gorm.DB, ownerType string, ownerID uint, text string) ([]models.ContentEntityModel, error) {
+	tokens := richtext.Parse(text)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	var usernames, hashtags []string
+	for _, token := range tokens {
+		if token.Type == richtext.Mention {
+			usernames = append(usernames, token.Value)
+		} else {
+			hashtags = append(hashtags, normalizeHashtag(token.Value))
+		}
+	}
+	userIDs, err := resolveMentionsTx(tx, usernames)
+	if err != nil {
+		return nil, err
+	}
+	hashtagIDs, err := resolveHashtagsTx(tx, hashtags)
+	if err != nil {
+		return nil, err
+	}
+	entities := make([]models.ContentEntityModel, 0, len(tokens))
+	for _, token := range tokens {
+		entity := models.ContentEntityModel{
+			OwnerType: ownerType,
+			OwnerID:   ownerID,
+			Start:     token.Start,
+			End:       token.End,
+		}
+		if token.Type == richtext.Mention {
+			id, ok := userIDs[token.Value]
+			if !ok {
+				id, ok = userIDs[strings.ToLower(token.Value)]
+			}
+			if !ok {
+				continue
+			}
+			entity.Type = models.EntityTypeMention
+			entity.RefID = id
+		} else {
+			entity.Type = models.EntityTypeHashtag
+			entity.RefID = hashtagIDs[normalizeHashtag(token.Value)]
+		}
+		entities = append(entities, entity)
+	}
+	if len(entities) == 0 {
+		return nil, nil
+	}
+	return entities, tx.Create(&entities).Error
+}
+
+// resolveMentionsTx 查询用户名对应的用户id，返回的map同时以原用户名和小写用户名为键
+func resolveMentionsTx(tx *gorm.DB, usernames []string) (map[string]uint, error) {
+	ids := make(map[string]uint)
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+	var users []models.UserModel
+	err := tx.Select("id", models.UserModelTable_Username).
+		Where(models.UserModelTable_Username+" IN ?", usernames).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		ids[user.Username] = user.ID
+		if _, ok := ids[strings.ToLower(user.Username)]; !ok {
+			ids[strings.ToLower(user.Username)] = user.ID
+		}
+	}
+	return ids, nil
+}
+
+// resolveHashtagsTx 查询话题对应的话题id，不存在的话题会被创建
+func resolveHashtagsTx(tx *gorm.DB, names []string) (map[string]uint, error) {
+	ids := make(map[string]uint)
+	if len(names) == 0 {
+		return ids, nil
+	}
+	name := models.HashtagModelTable_Name
+	var hashtags []models.HashtagModel
+	if err := tx.Where(name+" IN ?", names).Find(&hashtags).Error; err != nil {
+		return nil, err
+	}
+	for _, hashtag := range hashtags {
+		ids[hashtag.Name] = hashtag.ID
+	}
+	var missing []models.HashtagModel
+	var missingNames []string
+	for _, n := range names {
+		if _, ok := ids[n]; ok {
+			continue
+		}
+		ids[n] = 0
+		missing = append(missing, models.HashtagModel{Name: n})
+		missingNames = append(missingNames, n)
+	}
+	if len(missing) == 0 {
+		return ids, nil
+	}
+	// 其他请求可能同时创建了相同的话题
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+	hashtags = hashtags[:0]
+	if err := tx.Where(name+" IN ?", missingNames).Find(&hashtags).Error; err != nil {
+		return nil, err
+	}
+	for _, hashtag := range hashtags {
+		ids[hashtag.Name] = hashtag.ID
+	}
+	return ids, nil
+}
+
+// notifyMentionsTx 通知实体中被@的用户，actorID为发布者，不通知自己
+func notifyMentionsTx(tx *gorm.DB, entities []models.ContentEntityModel, actorID, videoID, commentID uint) error {
+	var notifications []models.NotificationModel
+	notified := make(map[uint]bool)
+	for _, entity := range entities {
+		if entity.Type != models.EntityTypeMention || entity.RefID == actorID || notified[entity.RefID] {
+			continue
+		}
+		notified[entity.RefID] = true
+		notifications = append(notifications, models.NotificationModel{
+			UserID:    entity.RefID,
+			Type:      models.NotificationTypeMention,
+			ActorID:   actorID,
+			VideoID:   videoID,
+			CommentID: commentID,
+		})
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	return tx.Create(&notifications).Error
+}
+
+// notifyCommentMentionsTx 评论公开时通知评论中被@的用户
+func notifyCommentMentionsTx(tx *gorm.DB, comment models.CommentModel) error {
+	var entities []models.ContentEntityModel
+	err := tx.Where(models.ContentEntityModelTable_OwnerType+" = ? AND "+models.ContentEntityModelTable_OwnerID+" = ? AND "+
+		models.ContentEntityModelTable_Type+" = ?", models.EntityOwnerComment, comment.ID, models.EntityTypeMention).
+		Find(&entities).Error
+	if err != nil {
+		return err
+	}
+	return notifyMentionsTx(tx, entities, comment.UserID, comment.VideoID, comment.ID)
+}
+
+// QueryContentEntities 查询文本中的实体，返回ownerID到按位置排序的实体列表
+func QueryContentEntities(ownerType string, ownerIDs []uint) (map[uint][]models.ContentEntityModel, error) {
+	entityMap := make(map[uint][]models.ContentEntityModel)
+	if len(ownerIDs) == 0 {
+		return entityMap, nil
+	}
+	var entities []models.ContentEntityModel
+	err := database.GetMysqlDB().Where(models.ContentEntityModelTable_OwnerType+" = ? AND "+
+		models.ContentEntityModelTable_OwnerID+" IN ?", ownerType, ownerIDs).Find(&entities).Error
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entities, func(i, j int) bool {
+		return entities[i].Start < entities[j].Start
+	})
+	for _, entity := range entities {
+		entityMap[entity.OwnerID] = append(entityMap[entity.OwnerID], entity)
+	}
+	return entityMap, nil
+}
+
+// SetCommentSpans 设置评论列表中的@用户和#话题片段，已删除的评论没有片段
+func SetCommentSpans(commentList []response.CommentList) error {
+	commentIDs := make([]uint, 0, len(commentList))
+	for _, comment := range commentList {
+		if !comment.IsDeleted {
+			commentIDs = append(commentIDs, uint(comment.ID))
+		}
+	}
+	entityMap, err := QueryContentEntities(models.EntityOwnerComment, commentIDs)
+	if err != nil {
+		return err
+	}
+	for k, comment := range commentList {
+		if !comment.IsDeleted {
+			commentList[k].Spans = response.NewSpans(comment.Content, entityMap[uint(comment.ID)])
+		}
+	}
+	return nil
+}
+
+// SetVideoTitleSpans 设置视频列表中标题的@用户和#话题片段。
+// 片段只是标题的补充信息，查询失败时只记录日志，视频列表仍正常返回。
+func SetVideoTitleSpans(videoList []response.VideoList) {
+	videoIDs := make([]uint, len(videoList))
+	for k, video := range videoList {
+		videoIDs[k] = uint(video.ID)
+	}
+	entityMap, err := QueryContentEntities(models.EntityOwnerVideo, videoIDs)
+	if err != nil {
+		logrus.Error("set video title spans failed, err:", err)
+		return
+	}
+	for k, video := range videoList {
+		videoList[k].Spans = response.NewSpans(video.Title, entityMap[uint(video.ID)])
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// access token默认有效期
+	defaultAccessTokenTTL = 2 * time.Hour
+	// refresh token默认有效期
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// GetTokenTTL 获取access token和refresh token的有效期，未配置时使用默认值
+func GetTokenTTL() (accessTTL time.Duration, refreshTTL time.Duration) {
+	conf := config.GetTokenConfig()
+	accessTTL = time.Duration(conf.AccessTTLSeconds) * time.Second
+	if accessTTL <= 0 {
+		accessTTL = defaultAccessTokenTTL
+	}
+	refreshTTL = time.Duration(conf.RefreshTTLSeconds) * time.Second
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTokenTTL
+	}
+	return accessTTL, refreshTTL
+}
+
+var (
+	legacyTokenCutoffOnce sync.Once
+	legacyTokenCutoff     time.Time
+)
+
+// GetLegacyTokenCutoff 获取没有token类型的旧token失效的时间，未配置时为零值，即不接受旧token
+func GetLegacyTokenCutoff() time.Time {
+	legacyTokenCutoffOnce.Do(func() {
+		cutoff := config.GetTokenConfig().LegacyTokenCutoff
+		if cutoff == "" {
+			return
+		}
+		var err error
+		legacyTokenCutoff, err = time.Parse(time.RFC3339, cutoff)
+		if err != nil {
+			logrus.Panic("token.legacy_token_cutoff格式错误, error:" + err.Error())
+		}
+	})
+	return legacyTokenCutoff
+}
+
+// tokenVersionKey 用户某个token版本在吊销列表中的key
+func tokenVersionKey(userID uint, version uint) string {
+	return "user:" + strconv.FormatUint(uint64(userID), 10) + ":ver:" + strconv.FormatUint(uint64(version), 10)
+}
+
+// IsTokenVersionRevoked 用户在version版本签发的token是否已全部失效
+func IsTokenVersionRevoked(userID uint, version uint) (bool, error) {
+	return database.GetTokenRevocationList().IsRevoked(tokenVersionKey(userID, version))
+}
+
+// RevokeUserTokens 使用户之前签发的所有token失效。
+// 用户的token版本加1，旧版本记录在吊销列表中直到旧版本签发的token全部过期，
+// 鉴权时只需查询吊销列表，无需查询MySQL。
+func RevokeUserTokens(userID uint) error {
+	db := database.GetMysqlDB()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return revokeUserTokens(tx, userID)
+	})
+	if err != nil {
+		if err.Error() == response.ErrUserNotExists {
+			return err
+		}
+		logrus.Error("revoke user tokens failed, err: ", err)
+		return errors.New(response.ErrServerInternal)
+	}
+	// 缓存中的用户信息包含token版本
+	database.GetUserInfoCacher().Delete(userID)
+	return nil
+}
+
+// revokeUserTokens 在事务tx中增加用户的token版本并吊销旧版本，不更新缓存
+func revokeUserTokens(tx *gorm.DB, userID uint) error {
+	var user models.UserModel
+	// 锁定用户，并发增加版本时每个旧版本都会被吊销
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", models.UserModelTable_TokenVersion).Where("id = ?", userID).Find(&user).Error
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		return errors.New(response.ErrUserNotExists)
+	}
+	err = tx.Model(&user).UpdateColumn(models.UserModelTable_TokenVersion, user.TokenVersion+1).Error
+	if err != nil {
+		return err
+	}
+	// 在提交前吊销旧版本，吊销失败时版本不变，重试时仍吊销同一版本
+	_, ttl := GetTokenTTL()
+	// 版本0包含没有有效期的旧token，吊销记录需保留到旧token不再被接受
+	if user.TokenVersion == 0 {
+		if remain := time.Until(GetLegacyTokenCutoff()); remain > ttl {
+			ttl = remain
+		}
+	}
+	_, err = database.GetTokenRevocationList().Revoke(tokenVersionKey(userID, user.TokenVersion), ttl)
+	return err
+}
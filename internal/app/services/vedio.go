@@ -9,6 +9,7 @@ import (
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
 	"github.com/Doraemonkeys/douyin2/internal/pkg/cache"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idgen"
 	"github.com/Doraemonkeys/douyin2/utils"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -32,6 +33,10 @@ const (
 	// 数据库为空
 	ErrDBEmpty        = "数据库为空"
 	ErrDeleteNotOwner = "删除的不是自己的记录"
+	// 回复已删除的评论
+	ErrReplyDeleted = "不能回复已删除的评论"
+	// 点赞未公开(等待审核、被隐藏或已删除)的评论
+	ErrCommentNotPublic = "评论未公开"
 )
 
 const (
@@ -166,12 +171,24 @@ func GetVideoAndAuthorListFeedByLastTime(lastTime int64, limit int) ([]models.Vi
 }
 
 func CreateVedio(video *models.VideoModel) error {
-	db := database.GetMysqlDB()
-	err := db.Create(video).Error
-	if err != nil {
-		return err
+	if video.ID == 0 {
+		id, err := idgen.NextID()
+		if err != nil {
+			return err
+		}
+		video.ID = id
 	}
-	return nil
+	// 保存标题中的@用户和#话题，并通知被@的用户
+	return database.GetMysqlDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(video).Error; err != nil {
+			return err
+		}
+		entities, err := saveEntitiesTx(tx, models.EntityOwnerVideo, video.ID, video.Title)
+		if err != nil {
+			return err
+		}
+		return notifyMentionsTx(tx, entities, video.AuthorID, video.ID, 0)
+	})
 }
 
 func QueryPublishListByAuthorID(userID uint) ([]models.VideoModel, error) {
@@ -325,6 +342,112 @@ func DislikeVideo(userID, videoID uint) error {
 	return nil
 }
 
+// FavoriteAction 一次点赞或取消点赞操作
+type FavoriteAction struct {
+	UserID  uint
+	VideoID uint
+	// true-点赞，false-取消点赞
+	IsLike bool
+}
+
+type userVideoPair struct {
+	UserID  uint
+	VideoID uint
+}
+
+// BatchLikeVideo 批量点赞/取消点赞，actions需按发生的顺序排列。
+// 同一用户对同一视频的多次操作只保留最后一次(点赞后又取消点赞相互抵消)，
+// 再与数据库中的点赞记录比较，只写入真正发生变化的记录，
+// 视频的点赞数按视频聚合后在同一个事务中更新。
+func BatchLikeVideo(actions []FavoriteAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	// 1. 相互抵消，保留每个(用户,视频)的最终状态
+	finalState := make(map[userVideoPair]bool, len(actions))
+	pairs := make([][]interface{}, 0, len(actions))
+	for _, action := range actions {
+		pair := userVideoPair{UserID: action.UserID, VideoID: action.VideoID}
+		if _, exist := finalState[pair]; !exist {
+			pairs = append(pairs, []interface{}{action.UserID, action.VideoID})
+		}
+		finalState[pair] = action.IsLike
+	}
+
+	db := database.GetMysqlDB()
+	user_id := models.UserLikeModelTable_UserID
+	video_id := models.UserLikeModelTable_VideoID
+	// 2. 查询已存在的点赞记录
+	var existLikes []models.UserLikeModel
+	err := db.Where("("+user_id+", "+video_id+") IN ?", pairs).Find(&existLikes).Error
+	if err != nil {
+		return err
+	}
+	liked := make(map[userVideoPair]bool, len(existLikes))
+	for _, like := range existLikes {
+		liked[userVideoPair{UserID: like.UserID, VideoID: like.VideoID}] = true
+	}
+	var toCreate []models.UserLikeModel
+	var toDelete [][]interface{}
+	var changed []FavoriteAction
+	likeCountDelta := make(map[uint]int)
+	for pair, isLike := range finalState {
+		if isLike == liked[pair] {
+			continue
+		}
+		if isLike {
+			toCreate = append(toCreate, models.UserLikeModel{UserID: pair.UserID, VideoID: pair.VideoID})
+			likeCountDelta[pair.VideoID]++
+		} else {
+			toDelete = append(toDelete, []interface{}{pair.UserID, pair.VideoID})
+			likeCountDelta[pair.VideoID]--
+		}
+		changed = append(changed, FavoriteAction{UserID: pair.UserID, VideoID: pair.VideoID, IsLike: isLike})
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	// 3. 在一个事务中写入点赞记录和点赞数
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(toCreate) > 0 {
+			if err := tx.Create(&toCreate).Error; err != nil {
+				return err
+			}
+		}
+		if len(toDelete) > 0 {
+			err := tx.Where("("+user_id+", "+video_id+") IN ?", toDelete).Delete(&models.UserLikeModel{}).Error
+			if err != nil {
+				return err
+			}
+		}
+		like_count := models.VideoModelTable_LikeCount
+		for videoID, delta := range likeCountDelta {
+			if delta == 0 {
+				continue
+			}
+			err := tx.Model(&models.VideoModel{}).Where("id = ?", videoID).
+				Update(like_count, gorm.Expr(like_count+" + ?", delta)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// 4. 更新缓存
+	for _, action := range changed {
+		err := UpdateUserLikeCache(action.UserID, action.VideoID, action.IsLike)
+		if err != nil {
+			logrus.Error("update user like cache failed, err: ", err)
+		}
+	}
+	return nil
+}
+
 func QueryVideoListByVideoIDList(videoIDList []uint) ([]models.VideoModel, error) {
 	var videos []models.VideoModel
 	db := database.GetMysqlDB()
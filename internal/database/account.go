@@ -0,0 +1,37 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/revocation"
+)
+
+var usedCodeList revocation.List
+var mailThrottleList revocation.List
+var accountListsInitOnce sync.Once
+
+// InitAccountLists 初始化已使用的验证码列表和邮件发送间隔记录，存储与token吊销列表相同
+func InitAccountLists() {
+	accountListsInitOnce.Do(func() {
+		if config.GetTokenConfig().RevocationStore == config.TokenRevocationStoreRedis {
+			usedCodeList = revocation.NewRedisList(GetRedisClient(), "douyin2:code:used:")
+			mailThrottleList = revocation.NewRedisList(GetRedisClient(), "douyin2:mail:sent:")
+			return
+		}
+		usedCodeList = revocation.NewMemoryList()
+		mailThrottleList = revocation.NewMemoryList()
+	})
+}
+
+// GetUsedCodeList 获取已使用的验证码列表
+func GetUsedCodeList() revocation.List {
+	InitAccountLists()
+	return usedCodeList
+}
+
+// GetMailThrottleList 获取最近发送过邮件的用户和邮箱，记录在发送间隔后过期
+func GetMailThrottleList() revocation.List {
+	InitAccountLists()
+	return mailThrottleList
+}
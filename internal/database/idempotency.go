@@ -0,0 +1,37 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idempotency"
+)
+
+// 幂等键默认保存时间
+const defaultIdempotencyTTL = 24 * time.Hour
+
+var idempotencyStore idempotency.Store
+var idempotencyTTL time.Duration
+var idempotencyStoreInitOnce sync.Once
+
+func InitIdempotencyStore() {
+	idempotencyStoreInitOnce.Do(func() {
+		conf := config.GetIdempotencyConfig()
+		idempotencyTTL = time.Duration(conf.TTLSeconds) * time.Second
+		if idempotencyTTL <= 0 {
+			idempotencyTTL = defaultIdempotencyTTL
+		}
+		if conf.Store == config.IdempotencyStoreRedis {
+			idempotencyStore = idempotency.NewRedisStore(GetRedisClient(), "douyin2:idempotency:")
+			return
+		}
+		idempotencyStore = idempotency.NewMemoryStore()
+	})
+}
+
+// GetIdempotencyStore 获取幂等键存储及记录的保存时间
+func GetIdempotencyStore() (idempotency.Store, time.Duration) {
+	InitIdempotencyStore()
+	return idempotencyStore, idempotencyTTL
+}
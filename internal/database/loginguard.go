@@ -0,0 +1,37 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/loginguard"
+)
+
+var loginGuard *loginguard.Guard
+var loginGuardInitOnce sync.Once
+
+func InitLoginGuard() {
+	loginGuardInitOnce.Do(func() {
+		conf := config.GetLoginGuardConfig()
+		var store loginguard.Store = loginguard.NewMemoryStore()
+		if conf.Store == config.LoginGuardStoreRedis {
+			store = loginguard.NewRedisStore(GetRedisClient(), "douyin2:login:")
+		}
+		loginGuard = loginguard.New(store, loginguard.Config{
+			Window:            time.Duration(conf.WindowSeconds) * time.Second,
+			DelayAfter:        conf.DelayAfter,
+			BaseDelay:         time.Duration(conf.BaseDelayMillis) * time.Millisecond,
+			MaxDelay:          time.Duration(conf.MaxDelayMillis) * time.Millisecond,
+			UserLockThreshold: conf.UserLockThreshold,
+			IPLockThreshold:   conf.IPLockThreshold,
+			LockDuration:      time.Duration(conf.LockSeconds) * time.Second,
+		})
+	})
+}
+
+// GetLoginGuard 获取登录失败限制
+func GetLoginGuard() *loginguard.Guard {
+	InitLoginGuard()
+	return loginGuard
+}
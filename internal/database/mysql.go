@@ -5,6 +5,7 @@ import (
 
 	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app/models"
+	"github.com/sirupsen/logrus"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -16,6 +17,18 @@ func GetMysqlDB() *gorm.DB {
 	return db
 }
 
+// CloseMysqlDB 关闭MySQL连接池
+func CloseMysqlDB() error {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 func init() {
 	connectMysql()
 }
@@ -52,6 +65,7 @@ func mirateTable() {
 	db.SetupJoinTable(&models.VideoModel{}, models.VideoModelTable_LikesSlice, &models.UserLikeModel{})
 	db.SetupJoinTable(&models.VideoModel{}, models.VideoModelTable_CollectionsSlice, &models.UserCollectionModel{})
 
+	migrateUserEmail()
 	db.AutoMigrate(
 		&models.UserModel{},
 		&models.VideoModel{},
@@ -59,5 +73,28 @@ func mirateTable() {
 		&models.UserFollowerModel{},
 		&models.UserLikeModel{},
 		&models.UserCollectionModel{},
+		&models.CommentLikeModel{},
+		&models.HashtagModel{},
+		&models.ContentEntityModel{},
+		&models.NotificationModel{},
 	)
 }
+
+// migrateUserEmail 邮箱由普通索引改为唯一索引前，将空邮箱改为NULL并删除原来的普通索引
+func migrateUserEmail() {
+	// 原来的普通索引
+	const oldIndex = "idx_users_models_email"
+	migrator := db.Migrator()
+	if !migrator.HasIndex(&models.UserModel{}, oldIndex) {
+		return
+	}
+	err := db.Exec("UPDATE " + models.UserModelTableName + " SET " + models.UserModelTable_Email + " = NULL WHERE " +
+		models.UserModelTable_Email + " = ''").Error
+	if err != nil {
+		logrus.Error("迁移用户邮箱失败, error:", err)
+		return
+	}
+	if err := migrator.DropIndex(&models.UserModel{}, oldIndex); err != nil {
+		logrus.Error("删除用户邮箱的普通索引失败, error:", err)
+	}
+}
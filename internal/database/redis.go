@@ -1 +1,33 @@
 package database
+
+import (
+	"sync"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/redis/go-redis/v9"
+)
+
+var redisClient *redis.Client
+var redisInitOnce sync.Once
+
+// GetRedisClient 获取Redis客户端，首次调用时根据配置创建。
+// 只有用到Redis的功能(如Redis Stream消息队列)才会调用。
+func GetRedisClient() *redis.Client {
+	redisInitOnce.Do(func() {
+		redisConf := config.GetRedisConfig()
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     redisConf.Addr,
+			Password: redisConf.Password,
+			DB:       redisConf.DB,
+		})
+	})
+	return redisClient
+}
+
+// CloseRedisClient 关闭Redis客户端(如果已创建)
+func CloseRedisClient() error {
+	if redisClient == nil {
+		return nil
+	}
+	return redisClient.Close()
+}
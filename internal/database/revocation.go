@@ -0,0 +1,27 @@
+package database
+
+import (
+	"sync"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/revocation"
+)
+
+var tokenRevocationList revocation.List
+var tokenRevocationListInitOnce sync.Once
+
+func InitTokenRevocationList() {
+	tokenRevocationListInitOnce.Do(func() {
+		if config.GetTokenConfig().RevocationStore == config.TokenRevocationStoreRedis {
+			tokenRevocationList = revocation.NewRedisList(GetRedisClient(), "douyin2:token:revoked:")
+			return
+		}
+		tokenRevocationList = revocation.NewMemoryList()
+	})
+}
+
+// GetTokenRevocationList 获取已吊销的token列表
+func GetTokenRevocationList() revocation.List {
+	InitTokenRevocationList()
+	return tokenRevocationList
+}
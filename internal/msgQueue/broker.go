@@ -0,0 +1,160 @@
+package msgQueue
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+	"github.com/sirupsen/logrus"
+)
+
+// 所有服务副本共用的消费组
+const redisStreamGroup = "douyin2"
+
+// newMQ 根据配置创建消息队列，未配置时使用进程内的SimpleMQ。
+// partitionKey 不为nil时，key相同的消息按顺序处理；opts 仅对SimpleMQ生效。
+// Redis Stream不支持分区、批量处理和队列长度限制，配置了这些选项时启动失败，
+// 需要顺序处理的消息队列使用Redis Stream时必须开启unordered确认不再保证顺序。
+func newMQ[T any](conf config.MQItemConfig, defaultWorkerNum int, defaultStream string,
+	msgHandler func(T) error, partitionKey func(T) string, opts ...messageQueue.SimpleMQOption[T]) messageQueue.MQ[T] {
+	workerNum := conf.WorkerNum
+	if workerNum <= 0 {
+		workerNum = defaultWorkerNum
+	}
+	msgHandler = permanentErrHandler(msgHandler)
+	switch conf.Broker {
+	case "", config.MQBrokerSimple:
+		if partitionKey != nil {
+			opts = append(opts, messageQueue.WithPartitionKey(partitionKey))
+		}
+		if conf.MaxLen > 0 {
+			opts = append(opts, messageQueue.WithMaxLen[T](conf.MaxLen, overflowPolicy(conf.OverflowPolicy)))
+		}
+		opts = append(opts, messageQueue.WithErrHandler(msgHandler))
+		return messageQueue.NewSimpleMQ(workerNum, nil, opts...)
+	case config.MQBrokerRedis:
+		stream := conf.Stream
+		if stream == "" {
+			stream = defaultStream
+		}
+		if err := checkRedisMQConfig(conf, partitionKey != nil); err != nil {
+			logrus.Panic("Redis Stream消息队列配置错误, stream:", stream, " error:", err)
+		}
+		mqConf := config.GetMQConfig()
+		opts := messageQueue.RedisStreamOptions{
+			Stream:        stream,
+			Group:         redisStreamGroup,
+			Consumer:      consumerName(mqConf.Consumer),
+			ClaimMinIdle:  time.Duration(mqConf.ClaimMinIdleSeconds) * time.Second,
+			MaxDeliveries: int64(mqConf.MaxDeliveries),
+		}
+		mq, err := messageQueue.NewRedisStreamMQ[T](database.GetRedisClient(), opts,
+			messageQueue.JSONCodec[T]{}, workerNum, msgHandler)
+		if err != nil {
+			logrus.Panic("初始化Redis Stream消息队列失败, stream:", stream, " error:", err)
+		}
+		return mq
+	default:
+		logrus.Panic("不支持的消息代理: ", conf.Broker)
+	}
+	return nil
+}
+
+// checkRedisMQConfig 检查Redis Stream不支持的配置，避免配置被静默忽略
+func checkRedisMQConfig(conf config.MQItemConfig, partitioned bool) error {
+	if conf.BatchSize > 1 {
+		return errors.New("不支持batch_size")
+	}
+	if conf.MaxLen > 0 || conf.OverflowPolicy != "" {
+		return errors.New("不支持max_len和overflow_policy")
+	}
+	if partitioned && !conf.Unordered {
+		return errors.New("不保证同一key的消息按顺序处理，确认后需配置unordered")
+	}
+	return nil
+}
+
+// 重试也不会成功的业务错误
+var permanentErrs = map[string]bool{
+	ErrParam:                     true,
+	services.ErrDuplicate:        true,
+	services.ErrDeleteNotExists:  true,
+	services.ErrDeleteNotOwner:   true,
+	services.ErrReplyDeleted:     true,
+	services.ErrCommentNotPublic: true,
+	services.ErrCommentNotExists: true,
+	services.ErrCommentsDisabled: true,
+	response.ErrUserNotExists:    true,
+	response.ErrVideoNotExists:   true,
+}
+
+// isPermanentErr 错误是否为重试也不会成功的业务错误
+func isPermanentErr(err error) bool {
+	return err != nil && permanentErrs[err.Error()]
+}
+
+// permanentErrHandler 消息处理返回重试也不会成功的业务错误时只记录日志，视为处理完成，
+// Redis Stream不会重新投递该消息
+func permanentErrHandler[T any](handler func(T) error) func(T) error {
+	return func(msg T) error {
+		err := handler(msg)
+		if isPermanentErr(err) {
+			logrus.Warn("消息无法处理，不再重试：", err, " msg:", msg)
+			return nil
+		}
+		return err
+	}
+}
+
+// consumerName 消费者名称，同一消费组内需唯一。
+// 名称在重启后保持不变，重启前未确认的消息才能被及时认领，因此不包含进程ID。
+func consumerName(name string) string {
+	if name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		logrus.Panic("获取主机名失败，请配置mq.consumer, error:", err)
+	}
+	return hostname
+}
+
+// batchOption 配置了BatchSize时返回批量模式的选项，否则返回nil
+func batchOption[T any](conf config.MQItemConfig, batchHandler func([]T) error) messageQueue.SimpleMQOption[T] {
+	if conf.BatchSize <= 1 {
+		return nil
+	}
+	interval := time.Duration(conf.BatchIntervalMs) * time.Millisecond
+	return messageQueue.WithBatchHandler(conf.BatchSize, interval, batchHandler)
+}
+
+func overflowPolicy(policy string) messageQueue.OverflowPolicy {
+	switch policy {
+	case "", config.MQOverflowBlock:
+		return messageQueue.OverflowBlock
+	case config.MQOverflowReject:
+		return messageQueue.OverflowReject
+	case config.MQOverflowDropOldest:
+		return messageQueue.OverflowDropOldest
+	default:
+		logrus.Panic("不支持的消息队列溢出策略: ", policy)
+	}
+	return messageQueue.OverflowBlock
+}
+
+// 队列已满时请求默认最多等待的时间
+const defaultPushTimeout = time.Second
+
+// PushTimeout 队列已满(block策略)时请求最多等待的时间
+func PushTimeout() time.Duration {
+	timeout := time.Duration(config.GetMQConfig().PushTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		return defaultPushTimeout
+	}
+	return timeout
+}
@@ -0,0 +1,26 @@
+package msgQueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CloseAllMQ 依次关闭点赞、评论、关注、评论点赞消息队列，
+// 所有队列共用ctx的截止时间，返回遇到的所有错误
+func CloseAllMQ(ctx context.Context) error {
+	var errs []error
+	if err := CloseFavoriteMQ(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close favorite mq: %w", err))
+	}
+	if err := CloseCommentMQ(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close comment mq: %w", err))
+	}
+	if err := CloseFollowMQ(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close follow mq: %w", err))
+	}
+	if err := CloseCommentLikeMQ(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("close comment like mq: %w", err))
+	}
+	return errors.Join(errs...)
+}
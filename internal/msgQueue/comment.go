@@ -1,8 +1,13 @@
 package msgQueue
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"sync"
 
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/response"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
 	"github.com/sirupsen/logrus"
@@ -13,18 +18,29 @@ type CommentMsg struct {
 	ActionType string `json:"action_type"`
 	//用户填写的评论内容，在action_type=1的时候使用
 	CommentText string `json:"comment_text"`
-	//要删除的评论id，在action_type=2的时候使用
+	//action_type=1时为预先分配的新评论id，action_type=2时为要删除的评论id
 	CommentId uint `json:"comment_id"`
 	// 评论者或者删除者的id
 	CommenterID uint `json:"commenter_id"`
+	//回复的评论id，在action_type=3的时候使用
+	ParentID uint `json:"parent_id,omitempty"`
+	// 评论已在入队前审核，CommentText为审核后的内容，Held为true时等待人工审核
+	Held bool `json:"held,omitempty"`
+	// 请求的幂等键，用于消息去重
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (m CommentMsg) dedupKey() string {
+	return m.IdempotencyKey
 }
 
 const (
 	ActionTypeComment = "1"
 	ActionTypeDelete  = "2"
+	ActionTypeReply   = "3"
 )
 
-var commentMQ *messageQueue.SimpleMQ[CommentMsg]
+var commentMQ messageQueue.MQ[CommentMsg]
 var commentMQInitOnce sync.Once
 
 func GetCommentMQ() messageQueue.MQ[CommentMsg] {
@@ -35,29 +51,67 @@ const commentWorkerNum int = 10
 
 func InitCommentMQ() {
 	commentMQInitOnce.Do(func() {
-		commentMQ = messageQueue.NewSimpleMQ(commentWorkerNum, CommentMsgHandler)
+		commentMQ = newMQ(config.GetMQConfig().Comment, commentWorkerNum, "douyin2:mq:comment", dedupHandler(CommentMsgHandler),
+			commentMsgKey)
 	})
 }
 
-func CommentMsgHandler(msg CommentMsg) {
+// CloseCommentMQ 关闭评论消息队列，等待队列中的消息处理完毕
+func CloseCommentMQ(ctx context.Context) error {
+	if commentMQ == nil {
+		return nil
+	}
+	return commentMQ.Close(ctx)
+}
+
+// 同一用户的发表评论/删除评论按顺序处理，避免删除先于发表执行
+func commentMsgKey(msg CommentMsg) string {
+	return strconv.FormatUint(uint64(msg.CommenterID), 10)
+}
+
+func CommentMsgHandler(msg CommentMsg) error {
 	if msg.ActionType == ActionTypeComment {
 		// 发表评论
 		//logrus.Debug("发表评论：", "video_id:", msg.VideoID, "commenter_id:", msg.CommenterID, "comment_text:", msg.CommentText)
-		err := services.CommentVideo(msg.VideoID, msg.CommenterID, msg.CommentText)
+		err := services.CommentVideo(msg.CommentId, msg.VideoID, msg.CommenterID, msg.CommentText, msg.Held)
+		if isCommentDropped(err) {
+			logrus.Info("评论被丢弃：", err, " comment_id:", msg.CommentId, " video_id:", msg.VideoID)
+			return nil
+		}
 		if err != nil {
 			logrus.Error("发表评论失败：", err)
+			return err
+		}
+	} else if msg.ActionType == ActionTypeReply {
+		// 回复评论
+		err := services.ReplyComment(msg.CommentId, msg.VideoID, msg.ParentID, msg.CommenterID, msg.CommentText, msg.Held)
+		if isCommentDropped(err) {
+			logrus.Info("回复被丢弃：", err, " comment_id:", msg.CommentId, " parent_id:", msg.ParentID)
+			return nil
+		}
+		if err != nil {
+			logrus.Error("回复评论失败：", err)
+			return err
 		}
 	} else if msg.ActionType == ActionTypeDelete {
 		if msg.CommentId == 0 {
-			return
+			return nil
 		}
 		// 删除评论
 		//logrus.Debug("删除评论：", "comment_id:", msg.CommentId, "commenter_id:", msg.CommenterID)
 		err := services.DeleteComment(msg.CommentId, msg.CommenterID)
 		if err != nil {
 			logrus.Error("删除评论失败：", err)
+			return err
 		}
 	} else {
 		logrus.Error("不合法的参数：", msg)
+		return errors.New(ErrParam)
 	}
+	return nil
+}
+
+// isCommentDropped 评论因视频关闭评论或视频不存在而无法发表，重试也不会成功
+func isCommentDropped(err error) bool {
+	return err != nil && (err.Error() == services.ErrCommentsDisabled || err.Error() == response.ErrVideoNotExists)
 }
@@ -0,0 +1,80 @@
+package msgQueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/app/services"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+	"github.com/sirupsen/logrus"
+)
+
+type CommentLikeMsg struct {
+	CommentID uint `json:"comment_id"`
+	UserID    uint `json:"user_id"`
+	// 1-点赞，2-取消点赞
+	ActionType int `json:"action_type"`
+	// 请求的幂等键，用于消息去重
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (m CommentLikeMsg) dedupKey() string {
+	return m.IdempotencyKey
+}
+
+const commentLikeWorkerNum int = 10
+
+var commentLikeMQ messageQueue.MQ[CommentLikeMsg]
+var commentLikeMQInitOnce sync.Once
+
+// GetCommentLikeMQ
+// 获取评论点赞消息队列
+func GetCommentLikeMQ() messageQueue.MQ[CommentLikeMsg] {
+	return commentLikeMQ
+}
+
+// 评论点赞消息队列
+func InitCommentLikeMQ() {
+	commentLikeMQInitOnce.Do(func() {
+		commentLikeMQ = newMQ(config.GetMQConfig().CommentLike, commentLikeWorkerNum, "douyin2:mq:comment_like",
+			dedupHandler(CommentLikeMsgHandler), commentLikeMsgKey)
+	})
+}
+
+// CloseCommentLikeMQ 关闭评论点赞消息队列，等待队列中的消息处理完毕
+func CloseCommentLikeMQ(ctx context.Context) error {
+	if commentLikeMQ == nil {
+		return nil
+	}
+	return commentLikeMQ.Close(ctx)
+}
+
+// 同一用户对同一评论的点赞/取消点赞按顺序处理
+func commentLikeMsgKey(msg CommentLikeMsg) string {
+	return fmt.Sprintf("%d:%d", msg.UserID, msg.CommentID)
+}
+
+func CommentLikeMsgHandler(msg CommentLikeMsg) error {
+	if msg.ActionType == 1 {
+		// 点赞评论
+		err := services.LikeComment(msg.UserID, msg.CommentID)
+		if err != nil {
+			logrus.Error("点赞评论失败：", err)
+			return err
+		}
+	} else if msg.ActionType == 2 {
+		// 取消点赞评论
+		err := services.UnlikeComment(msg.UserID, msg.CommentID)
+		if err != nil {
+			logrus.Error("取消点赞评论失败：", err)
+			return err
+		}
+	} else {
+		logrus.Error("不合法的参数：", msg)
+		return errors.New(ErrParam)
+	}
+	return nil
+}
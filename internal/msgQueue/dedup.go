@@ -0,0 +1,86 @@
+package msgQueue
+
+import (
+	"github.com/Doraemonkeys/douyin2/internal/database"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idempotency"
+	"github.com/sirupsen/logrus"
+)
+
+// dedupMsg 带有幂等键的消息
+type dedupMsg interface {
+	// 消息的幂等键，为空时不去重
+	dedupKey() string
+}
+
+const dedupKeyPrefix = "mq:"
+
+// isProcessed 判断幂等键对应的消息是否已处理，存储出错时视为未处理
+func isProcessed(key string) bool {
+	store, _ := database.GetIdempotencyStore()
+	rec, ok, err := store.Get(dedupKeyPrefix + key)
+	if err != nil {
+		logrus.Error("查询消息幂等键失败：", err)
+		return false
+	}
+	return ok && rec.Done
+}
+
+func markProcessed(key string) {
+	store, ttl := database.GetIdempotencyStore()
+	err := store.Set(dedupKeyPrefix+key, idempotency.Record{Done: true}, ttl)
+	if err != nil {
+		logrus.Error("保存消息幂等键失败：", err)
+	}
+}
+
+// dedupHandler 跳过已处理过的消息(客户端重试、Redis Stream重新投递等)，处理成功后记录幂等键
+func dedupHandler[T dedupMsg](handler func(T) error) func(T) error {
+	return func(msg T) error {
+		key := msg.dedupKey()
+		if key == "" {
+			return handler(msg)
+		}
+		if isProcessed(key) {
+			logrus.Debug("跳过重复的消息：", key)
+			return nil
+		}
+		if err := handler(msg); err != nil {
+			return err
+		}
+		markProcessed(key)
+		return nil
+	}
+}
+
+// dedupBatchHandler 批量模式下的dedupHandler，同一批中的重复消息只保留第一条
+func dedupBatchHandler[T dedupMsg](handler func([]T) error) func([]T) error {
+	return func(msgs []T) error {
+		var keys []string
+		seen := make(map[string]struct{})
+		filtered := msgs[:0:0]
+		for _, msg := range msgs {
+			key := msg.dedupKey()
+			if key == "" {
+				filtered = append(filtered, msg)
+				continue
+			}
+			if _, ok := seen[key]; ok || isProcessed(key) {
+				logrus.Debug("跳过重复的消息：", key)
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+			filtered = append(filtered, msg)
+		}
+		if len(filtered) == 0 {
+			return nil
+		}
+		if err := handler(filtered); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			markProcessed(key)
+		}
+		return nil
+	}
+}
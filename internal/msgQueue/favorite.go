@@ -1,8 +1,12 @@
 package msgQueue
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
 	"github.com/sirupsen/logrus"
@@ -18,11 +22,17 @@ type FavoriteMSg struct {
 	UserID  uint `json:"user_id"`
 	// 1-点赞，2-取消点赞
 	ActionType int `json:"action_type"`
+	// 请求的幂等键，用于消息去重
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (m FavoriteMSg) dedupKey() string {
+	return m.IdempotencyKey
 }
 
 const favoriteWorkerNum int = 10
 
-var favoriteMQ *messageQueue.SimpleMQ[FavoriteMSg]
+var favoriteMQ messageQueue.MQ[FavoriteMSg]
 var favoriteMQInitOnce sync.Once
 
 // GetFavoriteMQ
@@ -34,24 +44,64 @@ func GetFavoriteMQ() messageQueue.MQ[FavoriteMSg] {
 // 点赞消息队列
 func InitFavoriteMQ() {
 	favoriteMQInitOnce.Do(func() {
-		favoriteMQ = messageQueue.NewSimpleMQ(favoriteWorkerNum, FavoriteMsgHandler)
+		conf := config.GetMQConfig().Favorite
+		favoriteMQ = newMQ(conf, favoriteWorkerNum, "douyin2:mq:favorite", dedupHandler(FavoriteMsgHandler), favoriteMsgKey,
+			batchOption(conf, dedupBatchHandler(FavoriteBatchMsgHandler)))
 	})
 }
 
-func FavoriteMsgHandler(msg FavoriteMSg) {
+// CloseFavoriteMQ 关闭点赞消息队列，等待队列中的消息处理完毕
+func CloseFavoriteMQ(ctx context.Context) error {
+	if favoriteMQ == nil {
+		return nil
+	}
+	return favoriteMQ.Close(ctx)
+}
+
+// 同一用户对同一视频的点赞/取消点赞按顺序处理
+func favoriteMsgKey(msg FavoriteMSg) string {
+	return fmt.Sprintf("%d:%d", msg.UserID, msg.VideoID)
+}
+
+func FavoriteMsgHandler(msg FavoriteMSg) error {
 	if msg.ActionType == 1 {
 		// 点赞
 		err := services.LikeVideo(msg.UserID, msg.VideoID)
 		if err != nil {
 			logrus.Error("点赞失败：", err)
+			return err
 		}
 	} else if msg.ActionType == 2 {
 		// 取消点赞
 		err := services.DislikeVideo(msg.UserID, msg.VideoID)
 		if err != nil {
 			logrus.Error("取消点赞失败：", err)
+			return err
 		}
 	} else {
 		logrus.Error("不合法的参数：", msg)
+		return errors.New(ErrParam)
+	}
+	return nil
+}
+
+// FavoriteBatchMsgHandler 批量处理点赞消息，同一用户对同一视频的点赞和取消点赞会相互抵消
+func FavoriteBatchMsgHandler(msgs []FavoriteMSg) error {
+	actions := make([]services.FavoriteAction, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.ActionType != 1 && msg.ActionType != 2 {
+			logrus.Error("不合法的参数：", msg)
+			continue
+		}
+		actions = append(actions, services.FavoriteAction{
+			UserID:  msg.UserID,
+			VideoID: msg.VideoID,
+			IsLike:  msg.ActionType == 1,
+		})
+	}
+	err := services.BatchLikeVideo(actions)
+	if err != nil {
+		logrus.Error("批量点赞失败：", err)
 	}
+	return err
 }
@@ -1,8 +1,12 @@
 package msgQueue
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 
+	"github.com/Doraemonkeys/douyin2/config"
 	"github.com/Doraemonkeys/douyin2/internal/app/services"
 	"github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
 	"github.com/sirupsen/logrus"
@@ -15,7 +19,7 @@ const (
 
 const followWorkerNum int = 10
 
-var followMQ *messageQueue.SimpleMQ[FollowMsg]
+var followMQ messageQueue.MQ[FollowMsg]
 var followMQInitOnce sync.Once
 
 type FollowMsg struct {
@@ -24,6 +28,12 @@ type FollowMsg struct {
 	//1-关注，2-取消关注
 	ActionType string `json:"action_type"`
 	UserID     uint   `json:"user_id"`
+	// 请求的幂等键，用于消息去重
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+}
+
+func (m FollowMsg) dedupKey() string {
+	return m.IdempotencyKey
 }
 
 func GetFollowMQ() messageQueue.MQ[FollowMsg] {
@@ -32,24 +42,64 @@ func GetFollowMQ() messageQueue.MQ[FollowMsg] {
 
 func InitFollowMQ() {
 	followMQInitOnce.Do(func() {
-		followMQ = messageQueue.NewSimpleMQ(followWorkerNum, FollowMsgHandler)
+		conf := config.GetMQConfig().Follow
+		followMQ = newMQ(conf, followWorkerNum, "douyin2:mq:follow", dedupHandler(FollowMsgHandler), followMsgKey,
+			batchOption(conf, dedupBatchHandler(FollowBatchMsgHandler)))
 	})
 }
 
-func FollowMsgHandler(msg FollowMsg) {
+// CloseFollowMQ 关闭关注消息队列，等待队列中的消息处理完毕
+func CloseFollowMQ(ctx context.Context) error {
+	if followMQ == nil {
+		return nil
+	}
+	return followMQ.Close(ctx)
+}
+
+// 同一用户对同一用户的关注/取消关注按顺序处理
+func followMsgKey(msg FollowMsg) string {
+	return fmt.Sprintf("%d:%d", msg.UserID, msg.ToUserID)
+}
+
+func FollowMsgHandler(msg FollowMsg) error {
 	if msg.ActionType == ActionType_Follow {
 		// 关注
 		err := services.FollowUser(msg.UserID, msg.ToUserID)
 		if err != nil {
 			logrus.Error("关注失败：", err)
+			return err
 		}
 	} else if msg.ActionType == ActionType_Unfollow {
 		// 取消关注
 		err := services.UnfollowUser(msg.UserID, msg.ToUserID)
 		if err != nil {
 			logrus.Error("取消关注失败：", err)
+			return err
 		}
 	} else {
 		logrus.Error("不合法的参数：", msg)
+		return errors.New(ErrParam)
+	}
+	return nil
+}
+
+// FollowBatchMsgHandler 批量处理关注消息，同一用户对同一用户的关注和取消关注会相互抵消
+func FollowBatchMsgHandler(msgs []FollowMsg) error {
+	actions := make([]services.FollowAction, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.ActionType != ActionType_Follow && msg.ActionType != ActionType_Unfollow {
+			logrus.Error("不合法的参数：", msg)
+			continue
+		}
+		actions = append(actions, services.FollowAction{
+			UserID:   msg.UserID,
+			ToUserID: msg.ToUserID,
+			IsFollow: msg.ActionType == ActionType_Follow,
+		})
+	}
+	err := services.BatchFollowUser(actions)
+	if err != nil {
+		logrus.Error("批量关注失败：", err)
 	}
+	return err
 }
@@ -0,0 +1,27 @@
+package msgQueue
+
+import "github.com/Doraemonkeys/douyin2/internal/pkg/messageQueue"
+
+// MQStats 带队列名称的运行状态
+type MQStats struct {
+	Name  string
+	Stats messageQueue.Stats
+}
+
+// GetAllMQStats 获取点赞、评论、关注、评论点赞消息队列的运行状态，未初始化的队列不返回
+func GetAllMQStats() []MQStats {
+	var all []MQStats
+	if favoriteMQ != nil {
+		all = append(all, MQStats{Name: "favorite", Stats: favoriteMQ.Stats()})
+	}
+	if commentMQ != nil {
+		all = append(all, MQStats{Name: "comment", Stats: commentMQ.Stats()})
+	}
+	if followMQ != nil {
+		all = append(all, MQStats{Name: "follow", Stats: followMQ.Stats()})
+	}
+	if commentLikeMQ != nil {
+		all = append(all, MQStats{Name: "comment_like", Stats: commentLikeMQ.Stats()})
+	}
+	return all
+}
@@ -0,0 +1,172 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Record 幂等键对应的记录
+type Record struct {
+	// 请求指纹，相同的幂等键只能用于相同的请求
+	Fingerprint string `json:"fingerprint"`
+	// 请求是否已处理完毕，未完成时Status和Body为空
+	Done        bool   `json:"done"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Store 保存幂等键及其记录，记录在ttl后过期
+type Store interface {
+	// SetNX key不存在时保存记录并返回true，已存在时返回false
+	SetNX(key string, rec Record, ttl time.Duration) (bool, error)
+	// Get 获取记录，不存在时返回false
+	Get(key string) (Record, bool, error)
+	// Set 保存(覆盖)记录
+	Set(key string, rec Record, ttl time.Duration) error
+	// Delete 删除记录
+	Delete(key string) error
+}
+
+type memoryItem struct {
+	rec      Record
+	expireAt time.Time
+}
+
+// 过期记录的清理间隔
+const memorySweepInterval = time.Minute
+
+// MemoryStore 进程内的Store，只在单个服务副本内有效
+type MemoryStore struct {
+	lock      sync.Mutex
+	items     map[string]memoryItem
+	lastSweep time.Time
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		items:     make(map[string]memoryItem),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// 获取未过期的记录(调用需要加锁)
+func (s *MemoryStore) get(key string) (Record, bool) {
+	item, ok := s.items[key]
+	if !ok {
+		return Record{}, false
+	}
+	if !s.now().Before(item.expireAt) {
+		delete(s.items, key)
+		return Record{}, false
+	}
+	return item.rec, true
+}
+
+// 保存记录，并定期清理过期记录(调用需要加锁)
+func (s *MemoryStore) set(key string, rec Record, ttl time.Duration) {
+	now := s.now()
+	s.items[key] = memoryItem{rec: rec, expireAt: now.Add(ttl)}
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, item := range s.items {
+		if !now.Before(item.expireAt) {
+			delete(s.items, k)
+		}
+	}
+}
+
+func (s *MemoryStore) SetNX(key string, rec Record, ttl time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+	s.set(key, rec, ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Get(key string) (Record, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	rec, ok := s.get(key)
+	return rec, ok, nil
+}
+
+func (s *MemoryStore) Set(key string, rec Record, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.set(key, rec, ttl)
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+// Len 返回记录数(可能包含尚未清理的过期记录)
+func (s *MemoryStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.items)
+}
+
+// RedisStore 基于Redis的Store，多个服务副本共享
+type RedisStore struct {
+	client redis.UniversalClient
+	// key前缀
+	prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) SetNX(key string, rec Record, ttl time.Duration) (bool, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return false, err
+	}
+	return s.client.SetNX(context.Background(), s.prefix+key, data, ttl).Result()
+}
+
+func (s *RedisStore) Get(key string) (Record, bool, error) {
+	var rec Record
+	data, err := s.client.Get(context.Background(), s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return rec, false, nil
+	}
+	if err != nil {
+		return rec, false, err
+	}
+	err = json.Unmarshal(data, &rec)
+	if err != nil {
+		return rec, false, err
+	}
+	return rec, true, nil
+}
+
+func (s *RedisStore) Set(key string, rec Record, ttl time.Duration) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(context.Background(), s.prefix+key, data, ttl).Err()
+}
+
+func (s *RedisStore) Delete(key string) error {
+	return s.client.Del(context.Background(), s.prefix+key).Err()
+}
@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testStore(t *testing.T, store Store, expire func(time.Duration)) {
+	ttl := time.Minute
+	ok, err := store.SetNX("k", Record{Fingerprint: "f1"}, ttl)
+	if err != nil || !ok {
+		t.Fatalf("SetNX() = %v, %v, want true", ok, err)
+	}
+	ok, err = store.SetNX("k", Record{Fingerprint: "f2"}, ttl)
+	if err != nil || ok {
+		t.Fatalf("second SetNX() = %v, %v, want false", ok, err)
+	}
+	rec, ok, err := store.Get("k")
+	if err != nil || !ok || rec.Fingerprint != "f1" || rec.Done {
+		t.Fatalf("Get() = %+v, %v, %v", rec, ok, err)
+	}
+
+	done := Record{Fingerprint: "f1", Done: true, Status: 200, ContentType: "application/json", Body: []byte(`{"a":1}`)}
+	if err := store.Set("k", done, ttl); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	rec, ok, _ = store.Get("k")
+	if !ok || !rec.Done || rec.Status != 200 || string(rec.Body) != `{"a":1}` {
+		t.Errorf("Get() after Set = %+v, %v", rec, ok)
+	}
+
+	if err := store.Delete("k"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, ok, _ := store.Get("k"); ok {
+		t.Errorf("Get() after Delete found record")
+	}
+
+	store.SetNX("k2", Record{}, ttl)
+	expire(ttl)
+	if _, ok, _ := store.Get("k2"); ok {
+		t.Errorf("Get() after ttl found record")
+	}
+	if ok, _ := store.SetNX("k2", Record{}, ttl); !ok {
+		t.Errorf("SetNX() after ttl = false, want true")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	var offset time.Duration
+	store.now = func() time.Time { return time.Now().Add(offset) }
+	testStore(t, store, func(d time.Duration) { offset += d })
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := NewMemoryStore()
+	var offset time.Duration
+	store.now = func() time.Time { return time.Now().Add(offset) }
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(key, Record{}, time.Second)
+	}
+	offset = memorySweepInterval + time.Second
+	store.Set("d", Record{}, time.Hour)
+	if n := store.Len(); n != 1 {
+		t.Errorf("Len() after sweep = %v, want 1", n)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	store := NewRedisStore(client, "test:")
+	testStore(t, store, s.FastForward)
+	if !s.Exists("test:k2") {
+		t.Errorf("key prefix not applied")
+	}
+}
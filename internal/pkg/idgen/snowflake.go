@@ -0,0 +1,138 @@
+package idgen
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Snowflake ID 结构(共53位):
+//
+//	41位毫秒时间戳(相对于Epoch) | 5位机器ID | 7位序列号
+//
+// ID不超过2^53-1，JSON中的数字在JavaScript客户端中不会丢失精度。
+// 每个机器每毫秒最多生成128个ID。
+// 多个服务副本需配置不同的机器ID，才能保证生成的ID全局唯一。
+const (
+	timestampBits = 41
+	workerIDBits  = 5
+	sequenceBits  = 7
+
+	MaxWorkerID  = 1<<workerIDBits - 1
+	maxSequence  = 1<<sequenceBits - 1
+	maxTimestamp = 1<<timestampBits - 1
+
+	// 生成的ID的最大值
+	MaxID = 1<<(timestampBits+workerIDBits+sequenceBits) - 1
+
+	workerIDShift  = sequenceBits
+	timestampShift = sequenceBits + workerIDBits
+)
+
+// Epoch 2023-01-01 00:00:00 UTC
+var Epoch = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// 默认可容忍的时钟回拨时间
+const DefaultMaxBackward = 10 * time.Millisecond
+
+const (
+	ErrInvalidWorkerID = "invalid worker id"
+	ErrClockBackwards  = "clock moved backwards"
+	ErrTimeOverflow    = "timestamp exceeds the id range"
+)
+
+// Snowflake 生成趋势递增的唯一ID，并发安全
+type Snowflake struct {
+	lock     sync.Mutex
+	workerID int64
+	// 可容忍的时钟回拨时间，回拨不超过该值时等待时钟追上，超过时返回错误
+	maxBackward time.Duration
+	// 上一次生成ID的时间戳(毫秒，相对于Epoch)
+	lastTime int64
+	sequence int64
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+// NewSnowflake 创建ID生成器，maxBackward<=0时使用DefaultMaxBackward
+func NewSnowflake(workerID int64, maxBackward time.Duration) (*Snowflake, error) {
+	if workerID < 0 || workerID > MaxWorkerID {
+		return nil, errors.New(ErrInvalidWorkerID)
+	}
+	if maxBackward <= 0 {
+		maxBackward = DefaultMaxBackward
+	}
+	return &Snowflake{workerID: workerID, maxBackward: maxBackward, now: time.Now}, nil
+}
+
+func (s *Snowflake) timestamp() int64 {
+	return s.now().Sub(Epoch).Milliseconds()
+}
+
+// NextID 生成一个新的ID，时钟回拨超过可容忍的时间时返回错误
+func (s *Snowflake) NextID() (uint, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.timestamp()
+	if now < s.lastTime {
+		backward := time.Duration(s.lastTime-now) * time.Millisecond
+		if backward > s.maxBackward {
+			return 0, errors.New(ErrClockBackwards)
+		}
+		// 回拨较小，等待时钟追上
+		time.Sleep(backward)
+		now = s.timestamp()
+		if now < s.lastTime {
+			return 0, errors.New(ErrClockBackwards)
+		}
+	}
+	if now == s.lastTime {
+		s.sequence = (s.sequence + 1) & maxSequence
+		if s.sequence == 0 {
+			// 当前毫秒的序列号用完，等待下一毫秒
+			for now <= s.lastTime {
+				time.Sleep(time.Millisecond / 10)
+				now = s.timestamp()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	if now > maxTimestamp {
+		return 0, errors.New(ErrTimeOverflow)
+	}
+	s.lastTime = now
+	return uint(now<<timestampShift | s.workerID<<workerIDShift | s.sequence), nil
+}
+
+// Parse 解析ID，返回生成时间、机器ID和序列号
+func Parse(id uint) (t time.Time, workerID int64, sequence int64) {
+	v := int64(id)
+	t = Epoch.Add(time.Duration(v>>timestampShift) * time.Millisecond)
+	workerID = (v >> workerIDShift) & MaxWorkerID
+	sequence = v & maxSequence
+	return
+}
+
+var defaultSnowflake *Snowflake
+var defaultSnowflakeOnce sync.Once
+
+// Init 初始化默认的ID生成器，只有第一次调用有效
+func Init(workerID int64, maxBackward time.Duration) error {
+	var err error
+	defaultSnowflakeOnce.Do(func() {
+		defaultSnowflake, err = NewSnowflake(workerID, maxBackward)
+	})
+	return err
+}
+
+// NextID 使用默认的ID生成器生成一个新的ID，未初始化时使用机器ID 0
+func NextID() (uint, error) {
+	defaultSnowflakeOnce.Do(func() {
+		defaultSnowflake, _ = NewSnowflake(0, 0)
+	})
+	if defaultSnowflake == nil {
+		return 0, errors.New(ErrInvalidWorkerID)
+	}
+	return defaultSnowflake.NextID()
+}
@@ -0,0 +1,176 @@
+package idgen
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewSnowflake_InvalidWorkerID(t *testing.T) {
+	for _, workerID := range []int64{-1, MaxWorkerID + 1} {
+		if _, err := NewSnowflake(workerID, 0); err == nil || err.Error() != ErrInvalidWorkerID {
+			t.Errorf("NewSnowflake(%v) error = %v, want %v", workerID, err, ErrInvalidWorkerID)
+		}
+	}
+	if _, err := NewSnowflake(MaxWorkerID, 0); err != nil {
+		t.Errorf("NewSnowflake(%v) error = %v", MaxWorkerID, err)
+	}
+}
+
+func TestSnowflake_Unique(t *testing.T) {
+	s, _ := NewSnowflake(7, 0)
+	const goroutineNum = 8
+	const idNum = 20000
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	ids := make(map[uint]struct{}, goroutineNum*idNum)
+	for i := 0; i < goroutineNum; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]uint, 0, idNum)
+			for j := 0; j < idNum; j++ {
+				id, err := s.NextID()
+				if err != nil {
+					t.Errorf("NextID() error = %v", err)
+					return
+				}
+				local = append(local, id)
+			}
+			lock.Lock()
+			for _, id := range local {
+				ids[id] = struct{}{}
+			}
+			lock.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(ids) != goroutineNum*idNum {
+		t.Errorf("got %v distinct ids, want %v", len(ids), goroutineNum*idNum)
+	}
+}
+
+func TestSnowflake_Increasing(t *testing.T) {
+	s, _ := NewSnowflake(1, 0)
+	var last uint
+	for i := 0; i < 10000; i++ {
+		id, err := s.NextID()
+		if err != nil {
+			t.Fatalf("NextID() error = %v", err)
+		}
+		if id <= last {
+			t.Fatalf("NextID() = %v, not greater than previous %v", id, last)
+		}
+		last = id
+	}
+}
+
+func TestSnowflake_Parse(t *testing.T) {
+	s, _ := NewSnowflake(23, 0)
+	before := time.Now().Add(-time.Millisecond)
+	id, _ := s.NextID()
+	ts, workerID, sequence := Parse(id)
+	if workerID != 23 {
+		t.Errorf("Parse() workerID = %v, want 23", workerID)
+	}
+	if sequence != 0 {
+		t.Errorf("Parse() sequence = %v, want 0", sequence)
+	}
+	if ts.Before(before) || ts.After(time.Now()) {
+		t.Errorf("Parse() time = %v, want around now", ts)
+	}
+}
+
+func TestSnowflake_SequenceOverflow(t *testing.T) {
+	s, _ := NewSnowflake(1, 0)
+	base := time.Now()
+	calls := 0
+	// 前maxSequence+2次调用返回同一毫秒，之后前进1毫秒
+	s.now = func() time.Time {
+		calls++
+		if calls <= maxSequence+2 {
+			return base
+		}
+		return base.Add(time.Millisecond)
+	}
+	var last uint
+	for i := 0; i <= maxSequence+1; i++ {
+		id, err := s.NextID()
+		if err != nil {
+			t.Fatalf("NextID() error = %v", err)
+		}
+		if id <= last {
+			t.Fatalf("NextID() = %v, not greater than previous %v", id, last)
+		}
+		last = id
+	}
+	ts, _, sequence := Parse(last)
+	if sequence != 0 || ts.Sub(Epoch) != base.Add(time.Millisecond).Sub(Epoch).Truncate(time.Millisecond) {
+		t.Errorf("Parse() = %v, %v, want next millisecond with sequence 0", ts, sequence)
+	}
+}
+
+func TestSnowflake_ClockBackwards(t *testing.T) {
+	s, _ := NewSnowflake(1, 20*time.Millisecond)
+	base := time.Now()
+	s.now = func() time.Time { return base }
+	first, err := s.NextID()
+	if err != nil {
+		t.Fatalf("NextID() error = %v", err)
+	}
+
+	// 小幅回拨，等待后时钟追上
+	calls := 0
+	s.now = func() time.Time {
+		calls++
+		if calls == 1 {
+			return base.Add(-5 * time.Millisecond)
+		}
+		return base.Add(time.Millisecond)
+	}
+	second, err := s.NextID()
+	if err != nil {
+		t.Fatalf("NextID() after small backwards error = %v", err)
+	}
+	if second <= first {
+		t.Errorf("NextID() = %v, not greater than previous %v", second, first)
+	}
+
+	// 大幅回拨，返回错误
+	s.now = func() time.Time { return base.Add(-time.Second) }
+	if _, err := s.NextID(); err == nil || err.Error() != ErrClockBackwards {
+		t.Errorf("NextID() after large backwards error = %v, want %v", err, ErrClockBackwards)
+	}
+}
+
+func TestSnowflake_Range(t *testing.T) {
+	// JavaScript中可以精确表示的最大整数
+	const maxSafeInteger = 1<<53 - 1
+	if MaxID > maxSafeInteger {
+		t.Fatalf("MaxID = %v, exceeds %v", uint64(MaxID), uint64(maxSafeInteger))
+	}
+	s, _ := NewSnowflake(MaxWorkerID, 0)
+	// 时间戳用完前的最后一毫秒，序列号用完
+	last := Epoch.Add(maxTimestamp * time.Millisecond)
+	s.now = func() time.Time { return last }
+	for i := 0; i <= maxSequence; i++ {
+		id, err := s.NextID()
+		if err != nil {
+			t.Fatalf("NextID() error = %v", err)
+		}
+		if id > maxSafeInteger {
+			t.Fatalf("NextID() = %v, exceeds %v", id, uint64(maxSafeInteger))
+		}
+	}
+	s.now = func() time.Time { return last.Add(time.Millisecond) }
+	if _, err := s.NextID(); err == nil || err.Error() != ErrTimeOverflow {
+		t.Errorf("NextID() after time overflow error = %v, want %v", err, ErrTimeOverflow)
+	}
+
+	// 当前时间生成的ID
+	s, _ = NewSnowflake(MaxWorkerID, 0)
+	id, err := s.NextID()
+	if err != nil || id > maxSafeInteger {
+		t.Errorf("NextID() = %v, %v, want at most %v", id, err, uint64(maxSafeInteger))
+	}
+}
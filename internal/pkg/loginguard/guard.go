@@ -0,0 +1,239 @@
+// Package loginguard 限制登录尝试，防止暴力破解密码。
+//
+// 按用户名和客户端IP分别统计滑动窗口内的失败次数：
+// 同一用户名失败次数超过阈值后每次失败的响应延迟逐渐增加，
+// 用户名或IP失败次数达到锁定阈值后，在锁定期间拒绝其登录。
+package loginguard
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrLocked = errors.New("loginguard: too many failed attempts, temporarily locked")
+
+const (
+	userKeyPrefix = "user:"
+	ipKeyPrefix   = "ip:"
+)
+
+// Config 为0的字段使用默认值
+type Config struct {
+	// 统计失败次数的滑动窗口
+	Window time.Duration
+	// 同一用户名失败次数超过DelayAfter后开始延迟响应
+	DelayAfter int
+	// 第一次延迟的时间，之后每次失败翻倍
+	BaseDelay time.Duration
+	// 延迟的上限
+	MaxDelay time.Duration
+	// 窗口内同一用户名失败UserLockThreshold次后锁定
+	UserLockThreshold int
+	// 窗口内同一IP失败IPLockThreshold次后锁定，IP可能被多个用户共用，应大于UserLockThreshold
+	IPLockThreshold int
+	// 锁定时长
+	LockDuration time.Duration
+}
+
+// DefaultConfig 默认配置
+func DefaultConfig() Config {
+	return Config{
+		Window:            15 * time.Minute,
+		DelayAfter:        3,
+		BaseDelay:         500 * time.Millisecond,
+		MaxDelay:          4 * time.Second,
+		UserLockThreshold: 10,
+		IPLockThreshold:   50,
+		LockDuration:      15 * time.Minute,
+	}
+}
+
+func (c Config) withDefaults() Config {
+	def := DefaultConfig()
+	if c.Window <= 0 {
+		c.Window = def.Window
+	}
+	if c.DelayAfter <= 0 {
+		c.DelayAfter = def.DelayAfter
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = def.BaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = def.MaxDelay
+	}
+	if c.UserLockThreshold <= 0 {
+		c.UserLockThreshold = def.UserLockThreshold
+	}
+	if c.IPLockThreshold <= 0 {
+		c.IPLockThreshold = def.IPLockThreshold
+	}
+	if c.LockDuration <= 0 {
+		c.LockDuration = def.LockDuration
+	}
+	return c
+}
+
+// Result 一次登录失败的处理结果
+type Result struct {
+	// 窗口内同一用户名的失败次数(包含本次)
+	UserFailures int
+	// 窗口内同一IP的失败次数(包含本次)
+	IPFailures int
+	// 返回响应前应等待的时间
+	Delay time.Duration
+	// 本次失败导致锁定时为锁定时长，否则为0
+	LockedFor time.Duration
+}
+
+type Guard struct {
+	store Store
+	conf  Config
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+func New(store Store, conf Config) *Guard {
+	return &Guard{store: store, conf: conf.withDefaults(), now: time.Now}
+}
+
+// Check 尝试登录前调用，用户名或IP被锁定时返回ErrLocked和剩余的锁定时间
+func (g *Guard) Check(username, ip string) (time.Duration, error) {
+	now := g.now()
+	var remain time.Duration
+	for _, key := range []string{userKeyPrefix + username, ipKeyPrefix + ip} {
+		until, err := g.store.LockedUntil(key, now)
+		if err != nil {
+			return 0, err
+		}
+		if d := until.Sub(now); d > remain {
+			remain = d
+		}
+	}
+	if remain > 0 {
+		return remain, ErrLocked
+	}
+	return 0, nil
+}
+
+// Attempt 一次已预先计为失败的登录尝试
+type Attempt struct {
+	g        *Guard
+	username string
+	ip       string
+	at       time.Time
+	res      Result
+}
+
+// Begin 验证密码前调用，先将本次尝试计为一次失败，并发的尝试不会在锁定前全部通过检查。
+// 用户名或IP已被锁定，或计入本次尝试后超过锁定阈值时，返回ErrLocked和剩余的锁定时间。
+// 登录成功时调用Attempt.Succeed撤销本次计数，失败时调用Attempt.Fail，无法验证密码时调用Attempt.Cancel。
+func (g *Guard) Begin(username, ip string) (*Attempt, time.Duration, error) {
+	if remain, err := g.Check(username, ip); err != nil {
+		return nil, remain, err
+	}
+	a, err := g.record(username, ip)
+	if err != nil {
+		return nil, 0, err
+	}
+	// 之前的并发尝试已达到阈值，不再验证密码
+	if a.res.UserFailures > g.conf.UserLockThreshold || a.res.IPFailures > g.conf.IPLockThreshold {
+		if _, err := a.lock(); err != nil {
+			return nil, 0, err
+		}
+		return nil, g.conf.LockDuration, ErrLocked
+	}
+	return a, 0, nil
+}
+
+// Fail 密码错误或用户名不存在时调用，失败次数达到阈值时锁定
+func (a *Attempt) Fail() (Result, error) {
+	res := a.res
+	var err error
+	res.LockedFor, err = a.lock()
+	if err != nil {
+		return res, err
+	}
+	res.Delay = a.g.delay(res.UserFailures)
+	return res, nil
+}
+
+// Succeed 登录成功时调用，撤销本次尝试对IP的计数并清除该用户名的失败记录
+func (a *Attempt) Succeed() error {
+	if err := a.g.store.RemoveFailure(ipKeyPrefix+a.ip, a.at); err != nil {
+		return err
+	}
+	return a.g.Succeed(a.username)
+}
+
+// Cancel 无法验证密码(如服务内部错误)时调用，撤销本次尝试的计数
+func (a *Attempt) Cancel() error {
+	if err := a.g.store.RemoveFailure(userKeyPrefix+a.username, a.at); err != nil {
+		return err
+	}
+	return a.g.store.RemoveFailure(ipKeyPrefix+a.ip, a.at)
+}
+
+// lock 失败次数达到阈值时锁定用户名或IP，返回锁定时长
+func (a *Attempt) lock() (time.Duration, error) {
+	var lockedFor time.Duration
+	now := a.g.now()
+	if a.res.UserFailures >= a.g.conf.UserLockThreshold {
+		if err := a.g.store.Lock(userKeyPrefix+a.username, now.Add(a.g.conf.LockDuration)); err != nil {
+			return 0, err
+		}
+		lockedFor = a.g.conf.LockDuration
+	}
+	if a.res.IPFailures >= a.g.conf.IPLockThreshold {
+		if err := a.g.store.Lock(ipKeyPrefix+a.ip, now.Add(a.g.conf.LockDuration)); err != nil {
+			return 0, err
+		}
+		lockedFor = a.g.conf.LockDuration
+	}
+	return lockedFor, nil
+}
+
+// Fail 记录一次登录失败，用户名不存在时也应调用
+func (g *Guard) Fail(username, ip string) (Result, error) {
+	a, err := g.record(username, ip)
+	if err != nil {
+		return Result{}, err
+	}
+	return a.Fail()
+}
+
+// record 记录用户名和IP的一次失败
+func (g *Guard) record(username, ip string) (*Attempt, error) {
+	var err error
+	a := &Attempt{g: g, username: username, ip: ip, at: g.now()}
+	a.res.UserFailures, err = g.store.AddFailure(userKeyPrefix+username, a.at, g.conf.Window)
+	if err != nil {
+		return nil, err
+	}
+	a.res.IPFailures, err = g.store.AddFailure(ipKeyPrefix+ip, a.at, g.conf.Window)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Succeed 登录成功后清除该用户名的失败记录。
+// IP的失败记录不清除，否则攻击者可以用自己的账号重置计数。
+func (g *Guard) Succeed(username string) error {
+	return g.store.Reset(userKeyPrefix + username)
+}
+
+// delay 失败failures次后的响应延迟
+func (g *Guard) delay(failures int) time.Duration {
+	if failures <= g.conf.DelayAfter {
+		return 0
+	}
+	d := g.conf.BaseDelay
+	for i := g.conf.DelayAfter + 1; i < failures && d < g.conf.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.conf.MaxDelay {
+		d = g.conf.MaxDelay
+	}
+	return d
+}
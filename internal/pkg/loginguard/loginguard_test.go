@@ -0,0 +1,250 @@
+package loginguard
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testStore(t *testing.T, store Store) {
+	now := time.Now()
+	window := time.Minute
+	for i := 1; i <= 3; i++ {
+		n, err := store.AddFailure("a", now.Add(time.Duration(i)*time.Second), window)
+		if err != nil || n != i {
+			t.Fatalf("AddFailure() = %d, %v, want %d", n, err, i)
+		}
+	}
+	// 同一时刻的失败分别计数
+	store.AddFailure("b", now, window)
+	if n, _ := store.AddFailure("b", now, window); n != 2 {
+		t.Errorf("AddFailure() at same time = %d, want 2", n)
+	}
+	// 前两次失败已在窗口之外
+	if n, _ := store.AddFailure("a", now.Add(window+2*time.Second), window); n != 2 {
+		t.Errorf("AddFailure() after window = %d, want 2", n)
+	}
+
+	// 撤销一次失败
+	at := now.Add(window + 3*time.Second)
+	store.AddFailure("a", at, window)
+	if err := store.RemoveFailure("a", at); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := store.AddFailure("a", at, window); n != 2 {
+		t.Errorf("AddFailure() after RemoveFailure = %d, want 2", n)
+	}
+
+	if until, err := store.LockedUntil("a", now); err != nil || !until.IsZero() {
+		t.Fatalf("LockedUntil() = %v, %v, want zero", until, err)
+	}
+	lockUntil := now.Add(time.Minute)
+	if err := store.Lock("a", lockUntil); err != nil {
+		t.Fatal(err)
+	}
+	if until, _ := store.LockedUntil("a", now); !until.Equal(lockUntil) {
+		t.Errorf("LockedUntil() = %v, want %v", until, lockUntil)
+	}
+	if until, _ := store.LockedUntil("a", lockUntil); !until.IsZero() {
+		t.Errorf("LockedUntil() after lock = %v, want zero", until)
+	}
+	if until, _ := store.LockedUntil("b", now); !until.IsZero() {
+		t.Errorf("LockedUntil(b) = %v, want zero", until)
+	}
+
+	if err := store.Reset("a"); err != nil {
+		t.Fatal(err)
+	}
+	if until, _ := store.LockedUntil("a", now); !until.IsZero() {
+		t.Errorf("LockedUntil() after Reset = %v, want zero", until)
+	}
+	if n, _ := store.AddFailure("a", now, window); n != 1 {
+		t.Errorf("AddFailure() after Reset = %d, want 1", n)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStore_Sweep(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.AddFailure("a", now, time.Second)
+	store.AddFailure("b", now, time.Second)
+	store.Lock("b", now.Add(time.Hour))
+	store.AddFailure("c", now.Add(2*memorySweepInterval), time.Second)
+	// a已过期，b仍被锁定
+	if n := store.Len(); n != 2 {
+		t.Errorf("Len() after sweep = %d, want 2", n)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	testStore(t, NewRedisStore(client, "test:login:"))
+	if !mr.Exists("test:login:fail:a") {
+		t.Errorf("key without prefix")
+	}
+}
+
+func newTestGuard(conf Config) (*Guard, *time.Time) {
+	g := New(NewMemoryStore(), conf)
+	now := time.Now()
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestGuard_Delay(t *testing.T) {
+	g, _ := newTestGuard(Config{DelayAfter: 2, BaseDelay: time.Second, MaxDelay: 5 * time.Second, UserLockThreshold: 100})
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		res, err := g.Fail("alice", "1.1.1.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Delay != w || res.UserFailures != i+1 {
+			t.Errorf("failure %d: Delay = %v, UserFailures = %d, want %v, %d", i+1, res.Delay, res.UserFailures, w, i+1)
+		}
+	}
+}
+
+func TestGuard_UserLock(t *testing.T) {
+	g, now := newTestGuard(Config{UserLockThreshold: 3, LockDuration: time.Minute})
+	for i := 0; i < 2; i++ {
+		if res, _ := g.Fail("alice", "1.1.1.1"); res.LockedFor != 0 {
+			t.Fatalf("failure %d locked", i+1)
+		}
+	}
+	if _, err := g.Check("alice", "1.1.1.1"); err != nil {
+		t.Fatalf("Check() before lock = %v", err)
+	}
+	if res, _ := g.Fail("alice", "1.1.1.1"); res.LockedFor != time.Minute {
+		t.Fatalf("LockedFor = %v, want 1m", res.LockedFor)
+	}
+	// 换IP也无法登录该用户
+	remain, err := g.Check("alice", "2.2.2.2")
+	if !errors.Is(err, ErrLocked) || remain != time.Minute {
+		t.Errorf("Check() = %v, %v, want 1m, ErrLocked", remain, err)
+	}
+	// 其他用户不受影响
+	if _, err := g.Check("bob", "1.1.1.1"); err != nil {
+		t.Errorf("Check(bob) = %v, want nil", err)
+	}
+	*now = now.Add(time.Minute)
+	if _, err := g.Check("alice", "1.1.1.1"); err != nil {
+		t.Errorf("Check() after lock = %v, want nil", err)
+	}
+}
+
+func TestGuard_IPLock(t *testing.T) {
+	g, _ := newTestGuard(Config{UserLockThreshold: 10, IPLockThreshold: 3, LockDuration: time.Minute})
+	// 同一IP尝试不同的用户名
+	for _, name := range []string{"a", "b", "c"} {
+		g.Fail(name, "1.1.1.1")
+	}
+	if _, err := g.Check("d", "1.1.1.1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Check() = %v, want ErrLocked", err)
+	}
+	if _, err := g.Check("d", "2.2.2.2"); err != nil {
+		t.Errorf("Check() from other ip = %v, want nil", err)
+	}
+}
+
+func TestGuard_Succeed(t *testing.T) {
+	g, _ := newTestGuard(Config{DelayAfter: 1, UserLockThreshold: 10, IPLockThreshold: 10})
+	g.Fail("alice", "1.1.1.1")
+	g.Fail("alice", "1.1.1.1")
+	if err := g.Succeed("alice"); err != nil {
+		t.Fatal(err)
+	}
+	res, _ := g.Fail("alice", "1.1.1.1")
+	if res.UserFailures != 1 || res.Delay != 0 {
+		t.Errorf("after Succeed: UserFailures = %d, Delay = %v, want 1, 0", res.UserFailures, res.Delay)
+	}
+	// IP的失败记录保留
+	if res.IPFailures != 3 {
+		t.Errorf("IPFailures = %d, want 3", res.IPFailures)
+	}
+}
+
+func TestGuard_Window(t *testing.T) {
+	g, now := newTestGuard(Config{Window: time.Minute, UserLockThreshold: 3})
+	g.Fail("alice", "1.1.1.1")
+	g.Fail("alice", "1.1.1.1")
+	*now = now.Add(time.Minute)
+	if res, _ := g.Fail("alice", "1.1.1.1"); res.UserFailures != 1 || res.LockedFor != 0 {
+		t.Errorf("UserFailures = %d, LockedFor = %v, want 1, 0", res.UserFailures, res.LockedFor)
+	}
+}
+
+func TestGuard_BeginConcurrent(t *testing.T) {
+	g, _ := newTestGuard(Config{UserLockThreshold: 3, LockDuration: time.Minute})
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := g.Begin("alice", "1.1.1.1"); err == nil {
+				lock.Lock()
+				allowed++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// 并发的尝试在验证密码前计数，只有阈值内的尝试可以验证密码
+	if allowed != 3 {
+		t.Errorf("allowed = %d, want 3", allowed)
+	}
+	if _, err := g.Check("alice", "1.1.1.1"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Check() = %v, want ErrLocked", err)
+	}
+}
+
+func TestGuard_BeginFail(t *testing.T) {
+	g, _ := newTestGuard(Config{UserLockThreshold: 2, LockDuration: time.Minute})
+	a, _, err := g.Begin("alice", "1.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := a.Fail(); res.UserFailures != 1 || res.LockedFor != 0 {
+		t.Errorf("UserFailures = %d, LockedFor = %v, want 1, 0", res.UserFailures, res.LockedFor)
+	}
+	a, _, _ = g.Begin("alice", "1.1.1.1")
+	if res, _ := a.Fail(); res.LockedFor != time.Minute {
+		t.Errorf("LockedFor = %v, want 1m", res.LockedFor)
+	}
+	if _, remain, err := g.Begin("alice", "1.1.1.1"); !errors.Is(err, ErrLocked) || remain != time.Minute {
+		t.Errorf("Begin() = %v, %v, want 1m, ErrLocked", remain, err)
+	}
+}
+
+func TestGuard_BeginSucceed(t *testing.T) {
+	g, _ := newTestGuard(Config{UserLockThreshold: 10, IPLockThreshold: 10})
+	g.Fail("alice", "1.1.1.1")
+	a, _, err := g.Begin("alice", "1.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Succeed(); err != nil {
+		t.Fatal(err)
+	}
+	a, _, _ = g.Begin("bob", "1.1.1.1")
+	if err := a.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	// 成功和撤销的尝试不计入失败次数，之前的失败保留
+	res, _ := g.Fail("alice", "1.1.1.1")
+	if res.UserFailures != 1 || res.IPFailures != 2 {
+		t.Errorf("UserFailures = %d, IPFailures = %d, want 1, 2", res.UserFailures, res.IPFailures)
+	}
+}
@@ -0,0 +1,208 @@
+package loginguard
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store 保存登录失败记录和锁定状态
+type Store interface {
+	// AddFailure 记录key在now的一次失败，返回(now-window, now]内的失败次数(包含本次)
+	AddFailure(key string, now time.Time, window time.Duration) (int, error)
+	// RemoveFailure 撤销key在at记录的一次失败
+	RemoveFailure(key string, at time.Time) error
+	// Lock 禁止key在until之前登录
+	Lock(key string, until time.Time) error
+	// LockedUntil 返回key的锁定截止时间，未锁定时返回零值
+	LockedUntil(key string, now time.Time) (time.Time, error)
+	// Reset 清除key的失败记录和锁定状态
+	Reset(key string) error
+}
+
+// 过期记录的清理间隔
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	// 窗口内的失败时间，按时间升序
+	failures    []time.Time
+	window      time.Duration
+	lockedUntil time.Time
+}
+
+// 移除窗口之外的失败记录
+func (e *memoryEntry) trim(now time.Time) {
+	i := 0
+	for i < len(e.failures) && !e.failures[i].After(now.Add(-e.window)) {
+		i++
+	}
+	e.failures = e.failures[i:]
+}
+
+// MemoryStore 进程内的存储，只在单个服务副本内有效
+type MemoryStore struct {
+	lock      sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStore) AddFailure(key string, now time.Time, window time.Duration) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sweep(now)
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	entry.window = window
+	entry.failures = append(entry.failures, now)
+	entry.trim(now)
+	return len(entry.failures), nil
+}
+
+func (s *MemoryStore) RemoveFailure(key string, at time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	for i := len(entry.failures) - 1; i >= 0; i-- {
+		if entry.failures[i].Equal(at) {
+			entry.failures = append(entry.failures[:i], entry.failures[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Lock(key string, until time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+	entry.lockedUntil = until
+	return nil
+}
+
+func (s *MemoryStore) LockedUntil(key string, now time.Time) (time.Time, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || !entry.lockedUntil.After(now) {
+		return time.Time{}, nil
+	}
+	return entry.lockedUntil, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// Len 返回记录数(可能包含尚未清理的过期记录)
+func (s *MemoryStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.entries)
+}
+
+// 清理没有失败记录且未锁定的key(调用需要加锁)
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		entry.trim(now)
+		if len(entry.failures) == 0 && !entry.lockedUntil.After(now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// RedisStore 基于Redis的存储，多个服务副本共享。
+// 失败记录保存在以时间为分数的有序集合中，锁定状态保存为带过期时间的key。
+type RedisStore struct {
+	client redis.UniversalClient
+	// key前缀
+	prefix string
+}
+
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) failureKey(key string) string {
+	return s.prefix + "fail:" + key
+}
+
+func (s *RedisStore) lockKey(key string) string {
+	return s.prefix + "lock:" + key
+}
+
+func (s *RedisStore) AddFailure(key string, now time.Time, window time.Duration) (int, error) {
+	ctx := context.Background()
+	redisKey := s.failureKey(key)
+	// 同一时刻的多次失败需要不同的成员
+	member := strconv.FormatInt(now.UnixNano(), 10) + ":" + strconv.FormatInt(rand.Int63(), 36)
+	var card *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, redisKey, "-inf", strconv.FormatInt(now.Add(-window).UnixNano(), 10))
+		pipe.ZAdd(ctx, redisKey, redis.Z{Score: float64(now.UnixNano()), Member: member})
+		card = pipe.ZCard(ctx, redisKey)
+		pipe.PExpire(ctx, redisKey, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(card.Val()), nil
+}
+
+// RemoveFailure 删除分数为at的成员，同一纳秒的多次失败会被一起删除
+func (s *RedisStore) RemoveFailure(key string, at time.Time) error {
+	score := strconv.FormatInt(at.UnixNano(), 10)
+	return s.client.ZRemRangeByScore(context.Background(), s.failureKey(key), score, score).Err()
+}
+
+func (s *RedisStore) Lock(key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	return s.client.Set(context.Background(), s.lockKey(key), until.UnixNano(), ttl).Err()
+}
+
+func (s *RedisStore) LockedUntil(key string, now time.Time) (time.Time, error) {
+	value, err := s.client.Get(context.Background(), s.lockKey(key)).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	until := time.Unix(0, value)
+	if !until.After(now) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (s *RedisStore) Reset(key string) error {
+	return s.client.Del(context.Background(), s.failureKey(key), s.lockKey(key)).Err()
+}
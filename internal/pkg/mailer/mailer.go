@@ -0,0 +1,158 @@
+// Package mailer 发送邮件。
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrNoRecipient    = errors.New("mailer: no recipient")
+	ErrInvalidAddress = errors.New("mailer: invalid address")
+	ErrInvalidHeader  = errors.New("mailer: header contains line break")
+)
+
+// Message 纯文本邮件
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Mailer 发送邮件
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// CaptureMailer 只保存邮件而不发送，用于测试，并发安全
+type CaptureMailer struct {
+	lock     sync.Mutex
+	messages []Message
+}
+
+func NewCaptureMailer() *CaptureMailer {
+	return &CaptureMailer{}
+}
+
+func (m *CaptureMailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipient
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages 返回已发送的邮件
+func (m *CaptureMailer) Messages() []Message {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	messages := make([]Message, len(m.messages))
+	copy(messages, m.messages)
+	return messages
+}
+
+// Last 返回最后一封邮件
+func (m *CaptureMailer) Last() (Message, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if len(m.messages) == 0 {
+		return Message{}, false
+	}
+	return m.messages[len(m.messages)-1], true
+}
+
+// LogMailer 只将邮件输出到日志，用于开发环境
+type LogMailer struct{}
+
+func (LogMailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipient
+	}
+	logrus.WithField("to", strings.Join(msg.To, ",")).Info("邮件: ", msg.Subject, "\n", msg.Body)
+	return nil
+}
+
+// SMTPMailer 通过SMTP服务器发送邮件，服务器支持时使用STARTTLS。
+// 未加密的连接只能对本机的服务器使用用户名和密码认证。
+type SMTPMailer struct {
+	// host:port
+	addr string
+	host string
+	// 为空时不认证
+	username string
+	password string
+	from     mail.Address
+}
+
+func NewSMTPMailer(host string, port int, username, password string, from mail.Address) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if len(msg.To) == 0 {
+		return ErrNoRecipient
+	}
+	to := make([]string, 0, len(msg.To))
+	for _, addr := range msg.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return ErrInvalidAddress
+		}
+		to = append(to, parsed.Address)
+	}
+	data, err := buildMessage(m.from, to, msg.Subject, msg.Body, time.Now())
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	return smtp.SendMail(m.addr, auth, m.from.Address, to, data)
+}
+
+// buildMessage 生成RFC 5322格式的邮件，正文使用base64编码
+func buildMessage(from mail.Address, to []string, subject string, body string, date time.Time) ([]byte, error) {
+	if strings.ContainsAny(subject, "\r\n") || strings.ContainsAny(from.Name, "\r\n") {
+		return nil, ErrInvalidHeader
+	}
+	var buf bytes.Buffer
+	writeHeader := func(key, value string) {
+		buf.WriteString(key + ": " + value + "\r\n")
+	}
+	writeHeader("From", from.String())
+	writeHeader("To", strings.Join(to, ", "))
+	writeHeader("Subject", mime.BEncoding.Encode("UTF-8", subject))
+	writeHeader("Date", date.Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+	writeHeader("Content-Type", "text/plain; charset=UTF-8")
+	writeHeader("Content-Transfer-Encoding", "base64")
+	buf.WriteString("\r\n")
+	// 每行最多76个字符
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes(), nil
+}
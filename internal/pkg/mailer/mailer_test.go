@@ -0,0 +1,194 @@
+package mailer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer 只支持AUTH PLAIN的简易SMTP服务器，记录收到的邮件
+type fakeSMTPServer struct {
+	listener net.Listener
+	auth     chan string
+	from     chan string
+	rcpt     chan []string
+	data     chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTPServer{
+		listener: listener,
+		auth:     make(chan string, 1),
+		from:     make(chan string, 1),
+		rcpt:     make(chan []string, 1),
+		data:     make(chan string, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP")
+	var rcpt []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			tp.PrintfLine("250-localhost")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			s.auth <- line[len("AUTH PLAIN "):]
+			tp.PrintfLine("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			s.from <- line[len("MAIL FROM:"):]
+			tp.PrintfLine("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			rcpt = append(rcpt, line[len("RCPT TO:"):])
+			tp.PrintfLine("250 OK")
+		case cmd == "DATA":
+			tp.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.rcpt <- rcpt
+			s.data <- string(data)
+			tp.PrintfLine("250 OK")
+		case cmd == "QUIT":
+			tp.PrintfLine("221 Bye")
+			return
+		default:
+			tp.PrintfLine("502 Command not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) hostPort() (string, int) {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+func receive[T any](t *testing.T, ch chan T) T {
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for smtp server")
+	}
+	var zero T
+	return zero
+}
+
+func TestSMTPMailer(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port := server.hostPort()
+	from := mail.Address{Name: "抖音", Address: "noreply@example.com"}
+	m := NewSMTPMailer(host, port, "user", "secret", from)
+	body := strings.Repeat("您的验证码是 abc.def，请在30分钟内使用。\n", 5)
+	err := m.Send(Message{To: []string{"Alice <alice@example.com>", "bob@example.com"}, Subject: "邮箱验证", Body: body})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	auth, _ := base64.StdEncoding.DecodeString(receive(t, server.auth))
+	if string(auth) != "\x00user\x00secret" {
+		t.Errorf("auth = %q", auth)
+	}
+	if got := receive(t, server.from); got != "<noreply@example.com>" {
+		t.Errorf("MAIL FROM = %q", got)
+	}
+	if got := receive(t, server.rcpt); strings.Join(got, ",") != "<alice@example.com>,<bob@example.com>" {
+		t.Errorf("RCPT TO = %q", got)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(receive(t, server.data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "邮箱验证" {
+		t.Errorf("Subject = %q", subject)
+	}
+	if got, _ := mail.ParseAddress(msg.Header.Get("From")); got == nil || got.Name != from.Name || got.Address != from.Address {
+		t.Errorf("From = %q", msg.Header.Get("From"))
+	}
+	if msg.Header.Get("To") != "alice@example.com, bob@example.com" {
+		t.Errorf("To = %q", msg.Header.Get("To"))
+	}
+	decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != body {
+		t.Errorf("Body = %q, want %q", decoded, body)
+	}
+}
+
+func TestSMTPMailer_Invalid(t *testing.T) {
+	// 不会连接服务器
+	m := NewSMTPMailer("127.0.0.1", 1, "", "", mail.Address{Address: "noreply@example.com"})
+	if err := m.Send(Message{Subject: "s"}); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("Send() no recipient error = %v", err)
+	}
+	if err := m.Send(Message{To: []string{"not an address"}}); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Send() invalid address error = %v", err)
+	}
+	if err := m.Send(Message{To: []string{"a@example.com"}, Subject: "s\r\nBcc: x@example.com"}); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("Send() header injection error = %v", err)
+	}
+}
+
+func TestBuildMessage_LineLength(t *testing.T) {
+	data, err := buildMessage(mail.Address{Address: "a@example.com"}, []string{"b@example.com"}, "s", strings.Repeat("x", 1000), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		if len(scanner.Text()) > 78 {
+			t.Errorf("line too long: %d", len(scanner.Text()))
+		}
+	}
+}
+
+func TestCaptureMailer(t *testing.T) {
+	m := NewCaptureMailer()
+	if _, ok := m.Last(); ok {
+		t.Errorf("Last() on empty mailer = true")
+	}
+	if err := m.Send(Message{}); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("Send() no recipient error = %v", err)
+	}
+	m.Send(Message{To: []string{"a@example.com"}, Subject: "1"})
+	m.Send(Message{To: []string{"b@example.com"}, Subject: "2"})
+	if msgs := m.Messages(); len(msgs) != 2 || msgs[0].Subject != "1" {
+		t.Errorf("Messages() = %+v", msgs)
+	}
+	if last, ok := m.Last(); !ok || last.Subject != "2" {
+		t.Errorf("Last() = %+v, %v", last, ok)
+	}
+}
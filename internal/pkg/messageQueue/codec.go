@@ -0,0 +1,22 @@
+package messageQueue
+
+import "encoding/json"
+
+// Codec 消息的编解码器，用于在进程外的消息代理(broker)中传递消息
+type Codec[T any] interface {
+	Marshal(T) ([]byte, error)
+	Unmarshal([]byte) (T, error)
+}
+
+// JSONCodec 使用消息结构体上的json tag进行编解码
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(msg T) ([]byte, error) {
+	return json.Marshal(msg)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var msg T
+	err := json.Unmarshal(data, &msg)
+	return msg, err
+}
@@ -0,0 +1,116 @@
+package messageQueue
+
+import (
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/pkg/third_party/priorityQueue"
+)
+
+// delayedMsg 延迟消息，按到期时间排序，到期时间相同时按PushAt的顺序
+type delayedMsg[T any] struct {
+	due time.Time
+	seq uint64
+	msg T
+}
+
+func (m delayedMsg[T]) Less(other delayedMsg[T]) bool {
+	if !m.due.Equal(other.due) {
+		return m.due.Before(other.due)
+	}
+	return m.seq < other.seq
+}
+
+func newDelayQueue[T any]() *priorityQueue.PriorityQueue[delayedMsg[T]] {
+	return priorityQueue.NewPriorityQueue[delayedMsg[T]]()
+}
+
+// PushAt 在at时刻将消息放入队列，at不晚于当前时间时等同于Push。
+// 到期的消息不受最大长度的限制。
+// 队列关闭时尚未到期的消息会被立即放入队列处理，不会丢失。
+func (mq *SimpleMQ[T]) PushAt(msg T, at time.Time) error {
+	if !at.After(time.Now()) {
+		return mq.Push(msg)
+	}
+	mq.delayLock.Lock()
+	defer mq.delayLock.Unlock()
+	if mq.delayClosed {
+		return ErrMQClosed
+	}
+	mq.delaySeq++
+	mq.delayQue.Push(delayedMsg[T]{due: at, seq: mq.delaySeq, msg: msg})
+	// 新消息最早到期，通知delayLoop重新计时
+	if mq.delayQue.Top().seq == mq.delaySeq {
+		select {
+		case mq.delayWake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// PushAfter 在d时间后将消息放入队列
+func (mq *SimpleMQ[T]) PushAfter(msg T, d time.Duration) error {
+	return mq.PushAt(msg, time.Now().Add(d))
+}
+
+// delayLoop 等待延迟消息到期，将到期的消息放入队列。
+// 关闭后将剩余的延迟消息全部放入队列再退出。
+func (mq *SimpleMQ[T]) delayLoop() {
+	for {
+		var due []T
+		var wait time.Duration = -1
+		mq.delayLock.Lock()
+		now := time.Now()
+		for !mq.delayQue.IsEmpty() && (mq.delayClosed || !mq.delayQue.Top().due.After(now)) {
+			due = append(due, mq.delayQue.Pop().msg)
+		}
+		if !mq.delayQue.IsEmpty() {
+			wait = mq.delayQue.Top().due.Sub(now)
+		}
+		closed := mq.delayClosed
+		mq.delayLock.Unlock()
+
+		if len(due) > 0 {
+			mq.queLock.Lock()
+			for _, msg := range due {
+				mq.push(msg)
+			}
+			mq.queLock.Unlock()
+		}
+		if closed {
+			close(mq.delayDone)
+			return
+		}
+		if wait < 0 {
+			<-mq.delayWake
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-mq.delayWake:
+		}
+		timer.Stop()
+	}
+}
+
+// closeDelay 不再接受延迟消息，并等待剩余的延迟消息放入队列
+func (mq *SimpleMQ[T]) closeDelay() {
+	mq.delayLock.Lock()
+	if !mq.delayClosed {
+		mq.delayClosed = true
+		select {
+		case mq.delayWake <- struct{}{}:
+		default:
+		}
+	}
+	mq.delayLock.Unlock()
+	<-mq.delayDone
+}
+
+// DelayedLen 返回尚未到期的延迟消息数
+func (mq *SimpleMQ[T]) DelayedLen() int {
+	mq.delayLock.Lock()
+	defer mq.delayLock.Unlock()
+	return mq.delayQue.Len()
+}
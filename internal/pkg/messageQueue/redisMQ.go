@@ -0,0 +1,301 @@
+package messageQueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamMQ 基于Redis Stream的消息队列，多个服务副本使用同一个消费组(group)，
+// 同一条消息只会被其中一个副本的一个worker处理。
+// 消息处理成功后才会被ACK并从Stream中删除，因此Len返回的是尚未处理完的消息数。
+// 处理失败或因实例退出而未处理完的消息留在消费组的待确认列表(PEL)中，
+// 空闲超过ClaimMinIdle后由任一副本通过XAUTOCLAIM认领并重新处理。
+type RedisStreamMQ[T any] struct {
+	client redis.UniversalClient
+	codec  Codec[T]
+	opts   RedisStreamOptions
+
+	// 通知worker停止拉取消息
+	cancel context.CancelFunc
+	// 所有worker退出后关闭
+	done chan struct{}
+
+	closeLock sync.RWMutex
+	closed    bool
+
+	metrics *mqMetrics
+}
+
+type RedisStreamOptions struct {
+	// Stream的key
+	Stream string
+	// 消费组名称，同一个消费组内的消费者共同消费一个Stream
+	Group string
+	// 消费者名称，同一个消费组内需唯一，通常为主机名或实例ID。
+	// 重启后应保持不变，以便继续处理重启前未确认的消息
+	Consumer string
+	// 每次阻塞读取的最长时间，默认1s
+	BlockTimeout time.Duration
+	// 待确认的消息空闲超过该时间后会被认领并重新处理，默认1min。
+	// 应大于处理一条消息的最长时间，否则正在处理的消息可能被重复处理
+	ClaimMinIdle time.Duration
+	// 检查待确认消息的间隔，默认与ClaimMinIdle相同
+	ClaimInterval time.Duration
+	// 一条消息的最大投递次数，超过后不再处理并从Stream中删除，默认10
+	MaxDeliveries int64
+}
+
+// 消息在Stream中的字段名
+const redisStreamDataField = "data"
+
+const (
+	defaultClaimMinIdle  = time.Minute
+	defaultMaxDeliveries = 10
+	// 每次认领的消息数
+	claimBatchSize = 10
+)
+
+// errUndecodable 消息无法解析，重新投递也无法处理
+var errUndecodable = errors.New("redis stream message can not be decoded")
+
+// NewRedisStreamMQ 创建基于Redis Stream的消息队列，并启动workerNum个worker。
+// 消费组不存在时会自动创建。
+// msgHandler 必须是并发安全的，返回的error计入Stats.Errors。
+func NewRedisStreamMQ[T any](client redis.UniversalClient, opts RedisStreamOptions, codec Codec[T],
+	workerNum int, msgHandler func(T) error) (*RedisStreamMQ[T], error) {
+	if opts.Stream == "" || opts.Group == "" || opts.Consumer == "" {
+		return nil, errors.New("redis stream, group and consumer must not be empty")
+	}
+	if opts.BlockTimeout <= 0 {
+		opts.BlockTimeout = time.Second
+	}
+	if opts.ClaimMinIdle <= 0 {
+		opts.ClaimMinIdle = defaultClaimMinIdle
+	}
+	if opts.ClaimInterval <= 0 {
+		opts.ClaimInterval = opts.ClaimMinIdle
+	}
+	if opts.MaxDeliveries <= 0 {
+		opts.MaxDeliveries = defaultMaxDeliveries
+	}
+	err := client.XGroupCreateMkStream(context.Background(), opts.Stream, opts.Group, "0").Err()
+	// 消费组已存在
+	if err != nil && err.Error() != "BUSYGROUP Consumer Group name already exists" {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	mq := &RedisStreamMQ[T]{
+		client:  client,
+		codec:   codec,
+		opts:    opts,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		metrics: newMQMetrics(workerNum),
+	}
+	var workerWg sync.WaitGroup
+	workerWg.Add(workerNum + 1)
+	for i := 0; i < workerNum; i++ {
+		go func() {
+			mq.worker(ctx, msgHandler)
+			workerWg.Done()
+		}()
+	}
+	go func() {
+		mq.claimer(ctx, msgHandler)
+		workerWg.Done()
+	}()
+	go func() {
+		workerWg.Wait()
+		close(mq.done)
+	}()
+	return mq, nil
+}
+
+func (mq *RedisStreamMQ[T]) worker(ctx context.Context, msgHandler func(T) error) {
+	for ctx.Err() == nil {
+		// 不使用ctx，避免Close时取消已被Redis投递的读取，导致消息留在PEL中直到被认领。
+		// 阻塞时间不超过BlockTimeout，Close最多多等待一个BlockTimeout
+		streams, err := mq.client.XReadGroup(context.Background(), &redis.XReadGroupArgs{
+			Group:    mq.opts.Group,
+			Consumer: mq.opts.Consumer,
+			Streams:  []string{mq.opts.Stream, ">"},
+			Count:    1,
+			Block:    mq.opts.BlockTimeout,
+		}).Result()
+		if err != nil {
+			if !errors.Is(err, redis.Nil) && ctx.Err() == nil {
+				// 连接异常，稍后重试
+				time.Sleep(mq.opts.BlockTimeout)
+			}
+			continue
+		}
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				mq.handle(msg, msgHandler)
+			}
+		}
+	}
+}
+
+// claimer 启动时以及每隔ClaimInterval认领一次空闲的待确认消息
+func (mq *RedisStreamMQ[T]) claimer(ctx context.Context, msgHandler func(T) error) {
+	ticker := time.NewTicker(mq.opts.ClaimInterval)
+	defer ticker.Stop()
+	for {
+		mq.claimStale(ctx, msgHandler)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimStale 认领消费组中空闲超过ClaimMinIdle的待确认消息(包括已退出的消费者的消息)并重新处理
+func (mq *RedisStreamMQ[T]) claimStale(ctx context.Context, msgHandler func(T) error) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		msgs, next, err := mq.client.XAutoClaim(context.Background(), &redis.XAutoClaimArgs{
+			Stream:   mq.opts.Stream,
+			Group:    mq.opts.Group,
+			Consumer: mq.opts.Consumer,
+			MinIdle:  mq.opts.ClaimMinIdle,
+			Start:    start,
+			Count:    claimBatchSize,
+		}).Result()
+		if err != nil {
+			return
+		}
+		// 已认领的消息属于本消费者，即使正在关闭也处理完
+		for _, msg := range msgs {
+			if mq.exceedMaxDeliveries(msg.ID) {
+				mq.metrics.dropped.Add(1)
+				mq.ack(msg.ID)
+				continue
+			}
+			mq.handle(msg, msgHandler)
+		}
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// exceedMaxDeliveries 消息的投递次数是否超过MaxDeliveries，查询出错时返回false
+func (mq *RedisStreamMQ[T]) exceedMaxDeliveries(id string) bool {
+	pending, err := mq.client.XPendingExt(context.Background(), &redis.XPendingExtArgs{
+		Stream: mq.opts.Stream,
+		Group:  mq.opts.Group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return false
+	}
+	return pending[0].RetryCount > mq.opts.MaxDeliveries
+}
+
+// handle 处理一条消息，成功后ACK并删除。
+// 处理失败的消息留在PEL中等待重新投递，无法解析的消息重新投递也无法处理，直接删除。
+func (mq *RedisStreamMQ[T]) handle(msg redis.XMessage, msgHandler func(T) error) {
+	var handleErr error
+	mq.metrics.observe(1, func() error {
+		handleErr = mq.decodeAndHandle(msg, msgHandler)
+		return handleErr
+	})
+	if handleErr == nil || errors.Is(handleErr, errUndecodable) {
+		mq.ack(msg.ID)
+	}
+}
+
+func (mq *RedisStreamMQ[T]) decodeAndHandle(msg redis.XMessage, msgHandler func(T) error) error {
+	data, ok := msg.Values[redisStreamDataField].(string)
+	if !ok {
+		return errUndecodable
+	}
+	val, err := mq.codec.Unmarshal([]byte(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUndecodable, err)
+	}
+	return msgHandler(val)
+}
+
+// ack 确认消息并从Stream中删除
+func (mq *RedisStreamMQ[T]) ack(id string) {
+	ctx := context.Background()
+	mq.client.XAck(ctx, mq.opts.Stream, mq.opts.Group, id)
+	mq.client.XDel(ctx, mq.opts.Stream, id)
+}
+
+// Push 将消息编码后写入Stream，队列关闭后返回ErrMQClosed
+func (mq *RedisStreamMQ[T]) Push(msg T) error {
+	return mq.PushContext(context.Background(), msg)
+}
+
+// PushContext 与Push相同，写入Redis时使用ctx
+func (mq *RedisStreamMQ[T]) PushContext(ctx context.Context, msg T) error {
+	mq.closeLock.RLock()
+	defer mq.closeLock.RUnlock()
+	if mq.closed {
+		return ErrMQClosed
+	}
+	data, err := mq.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	err = mq.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: mq.opts.Stream,
+		Values: map[string]interface{}{redisStreamDataField: data},
+	}).Err()
+	if err == nil {
+		mq.metrics.onPush()
+	}
+	return err
+}
+
+// TryPush 与Push相同，Redis Stream不限制长度
+func (mq *RedisStreamMQ[T]) TryPush(msg T) error {
+	return mq.Push(msg)
+}
+
+// Len 返回Stream中尚未处理完的消息数，出错时返回0
+func (mq *RedisStreamMQ[T]) Len() int {
+	n, err := mq.client.XLen(context.Background(), mq.opts.Stream).Result()
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// Stats 返回本实例的运行状态，Depth为Stream中所有副本共享的待处理消息数
+func (mq *RedisStreamMQ[T]) Stats() Stats {
+	stats := mq.metrics.stats()
+	stats.Depth = mq.Len()
+	return stats
+}
+
+// Close 不再接受新的消息，并等待正在处理的消息处理完毕。
+// 尚未被本实例读取的消息保留在Stream中，由其他副本或重启后的实例继续处理；
+// 处理失败的消息留在PEL中，空闲超过ClaimMinIdle后被认领。
+func (mq *RedisStreamMQ[T]) Close(ctx context.Context) error {
+	mq.closeLock.Lock()
+	if !mq.closed {
+		mq.closed = true
+		mq.cancel()
+	}
+	mq.closeLock.Unlock()
+
+	select {
+	case <-mq.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
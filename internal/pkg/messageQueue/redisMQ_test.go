@@ -0,0 +1,245 @@
+package messageQueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type testMsg struct {
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+}
+
+func newTestRedisClient(t *testing.T) redis.UniversalClient {
+	s := miniredis.RunT(t)
+	return redis.NewClient(&redis.Options{Addr: s.Addr()})
+}
+
+func TestRedisStreamMQ_PushAndClose(t *testing.T) {
+	client := newTestRedisClient(t)
+	var lock sync.Mutex
+	var got = make(map[int]testMsg)
+	opts := RedisStreamOptions{
+		Stream:       "test_stream",
+		Group:        "test_group",
+		Consumer:     "consumer1",
+		BlockTimeout: 20 * time.Millisecond,
+	}
+	mq, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 3, func(msg testMsg) error {
+		lock.Lock()
+		got[msg.ID] = msg
+		lock.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	msgNum := 50
+	for i := 0; i < msgNum; i++ {
+		if err := mq.Push(testMsg{ID: i, Text: "hello"}); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for mq.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(got) != msgNum {
+		t.Errorf("handled %v messages, want %v", len(got), msgNum)
+	}
+	if got[7].Text != "hello" {
+		t.Errorf("got[7] = %v, want text hello", got[7])
+	}
+	if err := mq.Push(testMsg{}); err != ErrMQClosed {
+		t.Errorf("Push() after Close error = %v, want %v", err, ErrMQClosed)
+	}
+}
+
+func TestRedisStreamMQ_SharedGroup(t *testing.T) {
+	client := newTestRedisClient(t)
+	var lock sync.Mutex
+	var count = make(map[int]int)
+	handler := func(msg testMsg) error {
+		lock.Lock()
+		count[msg.ID]++
+		lock.Unlock()
+		return nil
+	}
+	opts := RedisStreamOptions{Stream: "s", Group: "g", BlockTimeout: 20 * time.Millisecond}
+	// 两个副本共享一个消费组
+	opts.Consumer = "replica1"
+	mq1, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 2, handler)
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	opts.Consumer = "replica2"
+	mq2, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 2, handler)
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	msgNum := 40
+	for i := 0; i < msgNum; i++ {
+		mq1.Push(testMsg{ID: i})
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for mq1.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq1.Close(ctx)
+	mq2.Close(ctx)
+	if len(count) != msgNum {
+		t.Errorf("handled %v distinct messages, want %v", len(count), msgNum)
+	}
+	for id, n := range count {
+		if n != 1 {
+			t.Errorf("message %v handled %v times, want 1", id, n)
+		}
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	codec := JSONCodec[testMsg]{}
+	data, err := codec.Marshal(testMsg{ID: 1, Text: "a"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"id":1,"text":"a"}` {
+		t.Errorf("Marshal() = %s", data)
+	}
+	msg, err := codec.Unmarshal(data)
+	if err != nil || msg.ID != 1 || msg.Text != "a" {
+		t.Errorf("Unmarshal() = %v, %v", msg, err)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisStreamMQ_RetryFailed(t *testing.T) {
+	client := newTestRedisClient(t)
+	var lock sync.Mutex
+	attempts := make(map[int]int)
+	opts := RedisStreamOptions{
+		Stream:       "s",
+		Group:        "g",
+		Consumer:     "c",
+		BlockTimeout: 20 * time.Millisecond,
+		ClaimMinIdle: 50 * time.Millisecond,
+	}
+	mq, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 1, func(msg testMsg) error {
+		lock.Lock()
+		defer lock.Unlock()
+		attempts[msg.ID]++
+		// 第一次处理失败
+		if attempts[msg.ID] == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	mq.Push(testMsg{ID: 1})
+	waitFor(t, func() bool { return mq.Len() == 0 })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq.Close(ctx)
+	if attempts[1] != 2 {
+		t.Errorf("message handled %v times, want 2", attempts[1])
+	}
+	if stats := mq.Stats(); stats.Errors != 1 || stats.Processed != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestRedisStreamMQ_ClaimFromDeadConsumer(t *testing.T) {
+	client := newTestRedisClient(t)
+	ctx := context.Background()
+	client.XGroupCreateMkStream(ctx, "s", "g", "0")
+	client.XAdd(ctx, &redis.XAddArgs{Stream: "s", Values: map[string]interface{}{redisStreamDataField: `{"id":1}`}})
+	// 已退出的消费者读取后未确认
+	client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "g", Consumer: "dead", Streams: []string{"s", ">"}, Count: 1})
+
+	got := make(chan testMsg, 1)
+	opts := RedisStreamOptions{
+		Stream:       "s",
+		Group:        "g",
+		Consumer:     "alive",
+		BlockTimeout: 20 * time.Millisecond,
+		ClaimMinIdle: 50 * time.Millisecond,
+	}
+	mq, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 1, func(msg testMsg) error {
+		got <- msg
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	defer mq.Close(ctx)
+	select {
+	case msg := <-got:
+		if msg.ID != 1 {
+			t.Errorf("got %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending message was not claimed")
+	}
+	waitFor(t, func() bool {
+		pending, _ := client.XPending(ctx, "s", "g").Result()
+		return pending.Count == 0
+	})
+}
+
+func TestRedisStreamMQ_MaxDeliveries(t *testing.T) {
+	client := newTestRedisClient(t)
+	var lock sync.Mutex
+	attempts := 0
+	opts := RedisStreamOptions{
+		Stream:        "s",
+		Group:         "g",
+		Consumer:      "c",
+		BlockTimeout:  20 * time.Millisecond,
+		ClaimMinIdle:  20 * time.Millisecond,
+		MaxDeliveries: 3,
+	}
+	mq, err := NewRedisStreamMQ[testMsg](client, opts, JSONCodec[testMsg]{}, 1, func(msg testMsg) error {
+		lock.Lock()
+		attempts++
+		lock.Unlock()
+		return errors.New("always fail")
+	})
+	if err != nil {
+		t.Fatalf("NewRedisStreamMQ() error = %v", err)
+	}
+	mq.Push(testMsg{ID: 1})
+	waitFor(t, func() bool { return mq.Len() == 0 })
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	mq.Close(ctx)
+	if attempts != 3 {
+		t.Errorf("message handled %v times, want 3", attempts)
+	}
+	if stats := mq.Stats(); stats.Dropped != 1 {
+		t.Errorf("Stats().Dropped = %v, want 1", stats.Dropped)
+	}
+}
@@ -1,9 +1,14 @@
 package messageQueue
 
 import (
+	"context"
+	"errors"
+	"hash/fnv"
 	"sync"
+	"time"
 
 	"github.com/Doraemonkeys/arrayQueue"
+	"github.com/Doraemonkeys/douyin2/pkg/third_party/priorityQueue"
 )
 
 // This file contains the implementation of a SimpleMQ data structure in Go.
@@ -21,18 +26,115 @@ type SimpleMQ[T any] struct {
 	workerNum int
 	// 用于传递消息给worker的通道,容量为buf的长度(同样是为了尽快从消息队列中读取消息)
 	msgChan chan T
-	// 用于通知发送消息的协程，队列中有消息了,waitChan的容量为1，保证Push时不会阻塞
+	// 用于通知发送消息的协程，队列中有消息了,waitChan的容量为1，已有通知时不再发送，保证Push时不会阻塞
 	waitChan chan struct{}
 	//由于只有一个goroutine读取队列中的消息，可能发生读饥饿的情况，
 	// buf是用于存储消息的缓冲区,保证在低概率抢到锁的情况下，也能读取到足够的队列中的消息
 	buf []T
+	// 队列是否已关闭，关闭后不再接受新的消息(调用需要加锁)
+	closed bool
+	// 所有worker退出后关闭
+	done chan struct{}
+	// 分区模式下用于计算消息的key，key相同的消息总是由同一个worker按顺序处理
+	partitionKey func(T) string
+	// 分区模式下每个worker独占的通道，partitionChans[i]只由第i个worker读取
+	partitionChans []chan T
+	// 消息处理函数，返回的error计入Stats.Errors
+	msgHandler func(T) error
+	// 批量模式下的消息处理函数，每个worker攒够batchSize条消息或
+	// 每隔batchInterval调用一次
+	batchHandler  func([]T) error
+	batchSize     int
+	batchInterval time.Duration
+	// 队列的最大长度，0表示不限制
+	maxLen int
+	// 队列已满时Push的处理策略
+	overflowPolicy OverflowPolicy
+	// 队列已满时阻塞的Push在此等待，sendMsg取出消息后关闭它(调用需要加锁)
+	notFullChan chan struct{}
+	metrics     *mqMetrics
+
+	// 尚未到期的延迟消息，按到期时间排序(调用需要加delayLock)
+	delayQue    *priorityQueue.PriorityQueue[delayedMsg[T]]
+	delayLock   sync.Mutex
+	delaySeq    uint64
+	delayClosed bool
+	// 有更早到期的延迟消息或关闭时通知delayLoop，容量为1
+	delayWake chan struct{}
+	// delayLoop退出后关闭
+	delayDone chan struct{}
+}
+
+// OverflowPolicy 队列达到最大长度时Push的处理策略
+type OverflowPolicy int
+
+const (
+	// 阻塞直到队列有空位或ctx结束
+	OverflowBlock OverflowPolicy = iota
+	// 立即返回ErrMQFull
+	OverflowReject
+	// 丢弃队列中最旧的消息
+	OverflowDropOldest
+)
+
+// SimpleMQOption 创建SimpleMQ时的可选配置
+type SimpleMQOption[T any] func(*SimpleMQ[T])
+
+// WithPartitionKey 开启分区模式，keyFunc返回相同key的消息总是由同一个worker按Push的顺序处理，
+// 不同key的消息仍然并行处理。
+// 例如同一用户对同一视频的点赞和取消点赞需要按顺序执行。
+func WithPartitionKey[T any](keyFunc func(T) string) SimpleMQOption[T] {
+	return func(mq *SimpleMQ[T]) {
+		mq.partitionKey = keyFunc
+	}
+}
+
+var (
+	// 消息队列已关闭
+	ErrMQClosed = errors.New("message queue is closed")
+	// 消息队列已满
+	ErrMQFull = errors.New("message queue is full")
+)
+
+// WithErrHandler 使用返回error的处理函数替代NewSimpleMQ的msgHandler(此时msgHandler可以为nil)，
+// 返回的error计入Stats.Errors。
+func WithErrHandler[T any](msgHandler func(T) error) SimpleMQOption[T] {
+	return func(mq *SimpleMQ[T]) {
+		mq.msgHandler = msgHandler
+	}
+}
+
+// WithBatchHandler 开启批量模式，每个worker攒够batchSize条消息或每隔flushInterval，
+// 将攒下的消息一次性交给batchHandler处理，此时NewSimpleMQ的msgHandler不再使用(可以为nil)。
+// 与WithPartitionKey同时使用时，同一批次内key相同的消息保持Push的顺序。
+func WithBatchHandler[T any](batchSize int, flushInterval time.Duration, batchHandler func([]T) error) SimpleMQOption[T] {
+	return func(mq *SimpleMQ[T]) {
+		if batchSize <= 0 {
+			batchSize = 1
+		}
+		if flushInterval <= 0 {
+			flushInterval = 100 * time.Millisecond
+		}
+		mq.batchHandler = batchHandler
+		mq.batchSize = batchSize
+		mq.batchInterval = flushInterval
+	}
+}
+
+// WithMaxLen 限制队列的最大长度，队列已满时按policy处理Push。
+// TryPush在队列已满时总是立即返回ErrMQFull(OverflowDropOldest除外)。
+func WithMaxLen[T any](maxLen int, policy OverflowPolicy) SimpleMQOption[T] {
+	return func(mq *SimpleMQ[T]) {
+		mq.maxLen = maxLen
+		mq.overflowPolicy = policy
+	}
 }
 
 // NewSimpleMQ function creates a new SimpleMQ instance and starts the worker goroutines.
 // The worker function processes messages from the message channel
 // and calls the provided message handler function.
 // The message handler must be a safe function that can be called concurrently.
-func NewSimpleMQ[T any](workerNum int, msgHandler func(T)) *SimpleMQ[T] {
+func NewSimpleMQ[T any](workerNum int, msgHandler func(T), opts ...SimpleMQOption[T]) *SimpleMQ[T] {
 	var buf []T = make([]T, workerNum*2)
 	var Msg chan T = make(chan T, len(buf))
 	var Wait chan struct{} = make(chan struct{}, 1)
@@ -43,20 +145,116 @@ func NewSimpleMQ[T any](workerNum int, msgHandler func(T)) *SimpleMQ[T] {
 		msgChan:   Msg,
 		waitChan:  Wait,
 		buf:       buf,
+		done:      make(chan struct{}),
+		metrics:   newMQMetrics(workerNum),
+		delayQue:  newDelayQueue[T](),
+		delayWake: make(chan struct{}, 1),
+		delayDone: make(chan struct{}),
+	}
+	if msgHandler != nil {
+		ret.msgHandler = func(msg T) error {
+			msgHandler(msg)
+			return nil
+		}
 	}
 	ret.queMinCap = 200
+	for _, opt := range opts {
+		if opt != nil {
+			opt(ret)
+		}
+	}
+	if ret.partitionKey != nil {
+		ret.partitionChans = make([]chan T, workerNum)
+		for i := 0; i < workerNum; i++ {
+			ret.partitionChans[i] = make(chan T, 2)
+		}
+	}
+	var workerWg sync.WaitGroup
+	workerWg.Add(workerNum)
 	for i := 0; i < workerNum; i++ {
-		go ret.worker(msgHandler)
+		msgChan := ret.msgChan
+		if ret.partitionKey != nil {
+			msgChan = ret.partitionChans[i]
+		}
+		go func() {
+			if ret.batchHandler != nil {
+				ret.batchWorker(msgChan)
+			} else {
+				ret.worker(msgChan)
+			}
+			workerWg.Done()
+		}()
 	}
+	go func() {
+		workerWg.Wait()
+		close(ret.done)
+	}()
 
-	go sendMsg(ret, msgHandler)
+	go sendMsg(ret)
+	go ret.delayLoop()
 	return ret
 }
 
-func (mq *SimpleMQ[T]) worker(msgHandler func(T)) {
+// worker 处理消息，msgChan关闭后退出
+func (mq *SimpleMQ[T]) worker(msgChan chan T) {
+	for msg := range msgChan {
+		mq.metrics.observe(1, func() error {
+			return mq.msgHandler(msg)
+		})
+	}
+}
+
+// batchWorker 批量处理消息，msgChan关闭后处理完剩余的消息再退出
+func (mq *SimpleMQ[T]) batchWorker(msgChan chan T) {
+	batch := make([]T, 0, mq.batchSize)
+	ticker := time.NewTicker(mq.batchInterval)
+	defer ticker.Stop()
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		mq.metrics.observe(len(batch), func() error {
+			return mq.batchHandler(batch)
+		})
+		// batchHandler可能持有batch，不复用底层数组
+		batch = make([]T, 0, mq.batchSize)
+	}
 	for {
-		msg := <-mq.msgChan
-		msgHandler(msg)
+		select {
+		case msg, ok := <-msgChan:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, msg)
+			if len(batch) >= mq.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// dispatch 将消息发送给worker，分区模式下根据key选择worker
+func (mq *SimpleMQ[T]) dispatch(msg T) {
+	if mq.partitionKey == nil {
+		mq.msgChan <- msg
+		return
+	}
+	h := fnv.New32a()
+	h.Write([]byte(mq.partitionKey(msg)))
+	mq.partitionChans[h.Sum32()%uint32(len(mq.partitionChans))] <- msg
+}
+
+// closeWorkerChans 通知所有worker退出
+func (mq *SimpleMQ[T]) closeWorkerChans() {
+	if mq.partitionKey == nil {
+		close(mq.msgChan)
+		return
+	}
+	for _, ch := range mq.partitionChans {
+		close(ch)
 	}
 }
 
@@ -64,7 +262,7 @@ func (mq *SimpleMQ[T]) worker(msgHandler func(T)) {
 // The implementation also includes a wait channel to notify the sendMsg function
 // when the queue is not empty.
 // 单线程读取队列中的消息，发送到消息通道中
-func sendMsg[T any](mq *SimpleMQ[T], msgHandler func(T)) {
+func sendMsg[T any](mq *SimpleMQ[T]) {
 	for {
 		var empty bool = false
 		var msgNum int = 0
@@ -78,11 +276,21 @@ func sendMsg[T any](mq *SimpleMQ[T], msgHandler func(T)) {
 		if mq.que.Empty() {
 			empty = true
 		}
+		// 队列有空位了，唤醒阻塞的Push
+		if msgNum > 0 {
+			mq.wakeBlockedPush()
+		}
+		closed := mq.closed
 		mq.queLock.Unlock()
 		//log.Printf("msgNum: %v, empty: %v,msgChan len: %v\n", msgNum, empty, len(mq.msgChan))
 		// 发送消息到消息通道中
 		for i := 0; i < msgNum; i++ {
-			mq.msgChan <- mq.buf[i]
+			mq.dispatch(mq.buf[i])
+		}
+		// 队列已关闭且消息已全部发送，通知worker退出
+		if empty && closed {
+			mq.closeWorkerChans()
+			return
 		}
 		// 读取完队列中的消息后，若队列为空，
 		// 其他goroutine再次调用Push时，必然会给waitChan发送消息
@@ -102,16 +310,139 @@ func (mq *SimpleMQ[T]) tryShrink() {
 	}
 }
 
-func (mq *SimpleMQ[T]) Push(msg T) {
+// Push 将消息放入队列，队列关闭后返回ErrMQClosed。
+// 设置了最大长度时，队列已满按OverflowPolicy处理，OverflowBlock会一直阻塞到队列有空位。
+func (mq *SimpleMQ[T]) Push(msg T) error {
+	return mq.PushContext(context.Background(), msg)
+}
+
+// PushContext 与Push相同，但在OverflowBlock策略下阻塞到ctx结束时返回ctx.Err()
+func (mq *SimpleMQ[T]) PushContext(ctx context.Context, msg T) error {
 	mq.queLock.Lock()
-	mq.que.Push(msg)
-	if mq.Len() == 1 {
-		// 通知发送消息的协程,队列中有消息了
-		mq.waitChan <- struct{}{}
+	for {
+		if mq.closed {
+			mq.queLock.Unlock()
+			return ErrMQClosed
+		}
+		if !mq.isFull() {
+			break
+		}
+		switch mq.overflowPolicy {
+		case OverflowReject:
+			mq.queLock.Unlock()
+			mq.metrics.rejected.Add(1)
+			return ErrMQFull
+		case OverflowDropOldest:
+			mq.que.Pop()
+			mq.metrics.dropped.Add(1)
+			mq.push(msg)
+			mq.queLock.Unlock()
+			return nil
+		}
+		// OverflowBlock
+		if mq.notFullChan == nil {
+			mq.notFullChan = make(chan struct{})
+		}
+		notFull := mq.notFullChan
+		mq.queLock.Unlock()
+		select {
+		case <-notFull:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		mq.queLock.Lock()
 	}
+	mq.push(msg)
 	mq.queLock.Unlock()
+	return nil
+}
+
+// TryPush 尝试将消息放入队列，不会阻塞。
+// 队列已满时返回ErrMQFull(OverflowDropOldest策略下丢弃最旧的消息)，队列关闭后返回ErrMQClosed。
+func (mq *SimpleMQ[T]) TryPush(msg T) error {
+	mq.queLock.Lock()
+	defer mq.queLock.Unlock()
+	if mq.closed {
+		return ErrMQClosed
+	}
+	if mq.isFull() {
+		if mq.overflowPolicy != OverflowDropOldest {
+			mq.metrics.rejected.Add(1)
+			return ErrMQFull
+		}
+		mq.que.Pop()
+		mq.metrics.dropped.Add(1)
+	}
+	mq.push(msg)
+	return nil
+}
+
+// 队列是否已满(调用需要加锁)
+func (mq *SimpleMQ[T]) isFull() bool {
+	return mq.maxLen > 0 && mq.que.Len() >= mq.maxLen
+}
+
+// 放入消息并在需要时通知sendMsg(调用需要加锁)
+func (mq *SimpleMQ[T]) push(msg T) {
+	mq.que.Push(msg)
+	mq.metrics.onPush()
+	if mq.que.Len() == 1 {
+		// 通知发送消息的协程,队列中有消息了。
+		// OverflowDropOldest替换消息时队列长度同样为1，此时waitChan中可能已有未被取走的通知，
+		// 不能阻塞，否则持有锁等待sendMsg，而sendMsg需要锁才能继续
+		select {
+		case mq.waitChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 唤醒所有因队列已满而阻塞的Push(调用需要加锁)
+func (mq *SimpleMQ[T]) wakeBlockedPush() {
+	if mq.notFullChan != nil {
+		close(mq.notFullChan)
+		mq.notFullChan = nil
+	}
 }
 
 func (mq *SimpleMQ[T]) Len() int {
+	mq.queLock.Lock()
+	defer mq.queLock.Unlock()
 	return mq.que.Len()
 }
+
+// Stats 返回消息队列当前的运行状态
+func (mq *SimpleMQ[T]) Stats() Stats {
+	stats := mq.metrics.stats()
+	stats.Depth = mq.Len()
+	stats.Delayed = mq.DelayedLen()
+	return stats
+}
+
+// Close 关闭消息队列，不再接受新的消息，并等待队列中剩余的消息和正在处理的消息处理完毕。
+// 尚未到期的延迟消息会被立即放入队列处理。
+// 若ctx先结束，返回ctx.Err()，剩余的消息仍会在后台继续处理。
+// 重复调用Close是安全的。
+func (mq *SimpleMQ[T]) Close(ctx context.Context) error {
+	// 先将延迟消息放入队列，再关闭队列
+	mq.closeDelay()
+	mq.queLock.Lock()
+	if !mq.closed {
+		mq.closed = true
+		// 唤醒可能正在等待的sendMsg协程
+		select {
+		case mq.waitChan <- struct{}{}:
+		default:
+		}
+		// 唤醒阻塞的Push，它们会返回ErrMQClosed
+		mq.wakeBlockedPush()
+	}
+	mq.queLock.Unlock()
+
+	select {
+	case <-mq.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,8 +1,11 @@
 package messageQueue
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -98,3 +101,382 @@ func BenchmarkSimpleMQ_Push2(b *testing.B) {
 	}
 	<-done
 }
+
+func TestSimpleMQ_Close(t *testing.T) {
+	var handled int64
+	var lock sync.Mutex
+	mq := NewSimpleMQ(4, func(msg int) {
+		time.Sleep(time.Millisecond)
+		lock.Lock()
+		handled++
+		lock.Unlock()
+	})
+	msgNum := 1000
+	for i := 0; i < msgNum; i++ {
+		if err := mq.Push(i); err != nil {
+			t.Fatalf("Push() error = %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if handled != int64(msgNum) {
+		t.Errorf("handled = %v, want %v", handled, msgNum)
+	}
+	if err := mq.Push(msgNum); err != ErrMQClosed {
+		t.Errorf("Push() after Close error = %v, want %v", err, ErrMQClosed)
+	}
+	// 重复关闭
+	if err := mq.Close(ctx); err != nil {
+		t.Errorf("Close() twice error = %v", err)
+	}
+}
+
+func TestSimpleMQ_CloseTimeout(t *testing.T) {
+	block := make(chan struct{})
+	mq := NewSimpleMQ(1, func(msg int) {
+		<-block
+	})
+	mq.Push(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := mq.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(block)
+	if err := mq.Close(context.Background()); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestSimpleMQ_CloseEmpty(t *testing.T) {
+	mq := NewSimpleMQ(2, func(msg int) {})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestSimpleMQ_PartitionKey(t *testing.T) {
+	type keyedMsg struct {
+		Key string
+		Seq int
+	}
+	var lock sync.Mutex
+	var lastSeq = make(map[string]int)
+	var outOfOrder int
+	mq := NewSimpleMQ(8, func(msg keyedMsg) {
+		// 让处理时间随机一些，非分区模式下很容易乱序
+		time.Sleep(time.Duration(msg.Seq%3) * time.Millisecond)
+		lock.Lock()
+		if msg.Seq != lastSeq[msg.Key]+1 {
+			outOfOrder++
+		}
+		lastSeq[msg.Key] = msg.Seq
+		lock.Unlock()
+	}, WithPartitionKey(func(msg keyedMsg) string { return msg.Key }))
+
+	keys := []string{"1:1", "1:2", "2:1", "3:7"}
+	seqNum := 100
+	for seq := 1; seq <= seqNum; seq++ {
+		for _, key := range keys {
+			mq.Push(keyedMsg{Key: key, Seq: seq})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if outOfOrder != 0 {
+		t.Errorf("%v messages handled out of order", outOfOrder)
+	}
+	for _, key := range keys {
+		if lastSeq[key] != seqNum {
+			t.Errorf("lastSeq[%v] = %v, want %v", key, lastSeq[key], seqNum)
+		}
+	}
+}
+
+func TestSimpleMQ_BatchHandler(t *testing.T) {
+	var lock sync.Mutex
+	var total int
+	var maxBatch int
+	mq := NewSimpleMQ(2, nil, WithBatchHandler(10, 20*time.Millisecond, func(msgs []int) error {
+		lock.Lock()
+		total += len(msgs)
+		if len(msgs) > maxBatch {
+			maxBatch = len(msgs)
+		}
+		lock.Unlock()
+		return nil
+	}))
+	msgNum := 105
+	for i := 0; i < msgNum; i++ {
+		mq.Push(i)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if total != msgNum {
+		t.Errorf("total = %v, want %v", total, msgNum)
+	}
+	if maxBatch > 10 {
+		t.Errorf("maxBatch = %v, want <= 10", maxBatch)
+	}
+}
+
+func TestSimpleMQ_BatchFlushInterval(t *testing.T) {
+	flushed := make(chan []int, 1)
+	mq := NewSimpleMQ(1, nil, WithBatchHandler(100, 20*time.Millisecond, func(msgs []int) error {
+		flushed <- msgs
+		return nil
+	}))
+	mq.Push(1)
+	mq.Push(2)
+	select {
+	case msgs := <-flushed:
+		if len(msgs) != 2 {
+			t.Errorf("flushed %v, want 2 messages", msgs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("batch not flushed by interval")
+	}
+	mq.Close(context.Background())
+}
+
+func TestSimpleMQ_MaxLen(t *testing.T) {
+	block := make(chan struct{})
+	newBlockedMQ := func(policy OverflowPolicy) *SimpleMQ[int] {
+		// worker阻塞在第一条消息上，sendMsg随后阻塞在发送消息上，队列不再被消费
+		mq := NewSimpleMQ(1, func(msg int) { <-block }, WithMaxLen[int](5, policy))
+		for i := 0; i < 10; i++ {
+			mq.TryPush(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		for i := 10; i < 20; i++ {
+			mq.TryPush(i)
+		}
+		return mq
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		mq := newBlockedMQ(OverflowReject)
+		if err := mq.TryPush(100); err != ErrMQFull {
+			t.Errorf("TryPush() error = %v, want %v", err, ErrMQFull)
+		}
+		if err := mq.Push(100); err != ErrMQFull {
+			t.Errorf("Push() error = %v, want %v", err, ErrMQFull)
+		}
+		if mq.Len() != 5 {
+			t.Errorf("Len() = %v, want 5", mq.Len())
+		}
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		mq := newBlockedMQ(OverflowDropOldest)
+		for i := 100; i < 110; i++ {
+			if err := mq.Push(i); err != nil {
+				t.Errorf("Push() error = %v", err)
+			}
+		}
+		if mq.Len() != 5 {
+			t.Errorf("Len() = %v, want 5", mq.Len())
+		}
+		mq.queLock.Lock()
+		oldest := mq.que.Front()
+		mq.queLock.Unlock()
+		if oldest != 105 {
+			t.Errorf("oldest = %v, want 105", oldest)
+		}
+	})
+
+	t.Run("block", func(t *testing.T) {
+		mq := newBlockedMQ(OverflowBlock)
+		if err := mq.TryPush(100); err != ErrMQFull {
+			t.Errorf("TryPush() error = %v, want %v", err, ErrMQFull)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		if err := mq.PushContext(ctx, 100); err != context.DeadlineExceeded {
+			t.Errorf("PushContext() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+		pushed := make(chan error, 1)
+		go func() {
+			pushed <- mq.Push(101)
+		}()
+		select {
+		case <-pushed:
+			t.Fatal("Push() returned while queue is full")
+		case <-time.After(30 * time.Millisecond):
+		}
+		// 关闭队列会唤醒阻塞的Push
+		go mq.Close(context.Background())
+		if err := <-pushed; err != ErrMQClosed {
+			t.Errorf("Push() error = %v, want %v", err, ErrMQClosed)
+		}
+	})
+	close(block)
+}
+
+// 队列长度为1时，OverflowDropOldest替换消息不应阻塞Push
+func TestSimpleMQ_DropOldestMaxLenOne(t *testing.T) {
+	block := make(chan struct{})
+	mq := NewSimpleMQ(1, func(msg int) { <-block }, WithMaxLen[int](1, OverflowDropOldest))
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		for i := 0; i < 100; i++ {
+			if err := mq.Push(i); err != nil {
+				t.Errorf("Push() error = %v", err)
+			}
+		}
+	}()
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Push() blocked")
+	}
+	if mq.Len() > 1 {
+		t.Errorf("Len() = %v, want <= 1", mq.Len())
+	}
+	close(block)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+func TestSimpleMQ_Stats(t *testing.T) {
+	mq := NewSimpleMQ[int](2, nil, WithErrHandler(func(msg int) error {
+		time.Sleep(2 * time.Millisecond)
+		if msg%10 == 0 {
+			return errors.New("handle failed")
+		}
+		return nil
+	}))
+	msgNum := 50
+	for i := 0; i < msgNum; i++ {
+		mq.Push(i)
+	}
+	if err := mq.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	stats := mq.Stats()
+	if stats.Pushed != uint64(msgNum) || stats.Processed != uint64(msgNum) {
+		t.Errorf("Pushed = %v, Processed = %v, want %v", stats.Pushed, stats.Processed, msgNum)
+	}
+	if stats.Errors != 5 {
+		t.Errorf("Errors = %v, want 5", stats.Errors)
+	}
+	if stats.Depth != 0 || stats.BusyWorkers != 0 {
+		t.Errorf("Depth = %v, BusyWorkers = %v, want 0", stats.Depth, stats.BusyWorkers)
+	}
+	total := stats.LatencyCounts[len(stats.LatencyCounts)-1]
+	if total != uint64(msgNum) {
+		t.Errorf("latency +Inf count = %v, want %v", total, msgNum)
+	}
+	// 处理耗时约2ms，不应落入1ms的桶
+	if stats.LatencyCounts[0] != 0 {
+		t.Errorf("latency <=1ms count = %v, want 0", stats.LatencyCounts[0])
+	}
+	if stats.LatencySum < time.Duration(msgNum)*2*time.Millisecond {
+		t.Errorf("LatencySum = %v, too small", stats.LatencySum)
+	}
+	if stats.Utilization <= 0 || stats.Utilization > 1 {
+		t.Errorf("Utilization = %v, want (0,1]", stats.Utilization)
+	}
+}
+
+func TestRateCounter(t *testing.T) {
+	var r rateCounter
+	now := time.Unix(1000, 0)
+	for sec := int64(0); sec < 20; sec++ {
+		for i := 0; i < 5; i++ {
+			r.add(now.Add(time.Duration(sec) * time.Second))
+		}
+	}
+	if rate := r.rate(now.Add(19 * time.Second)); rate != 5 {
+		t.Errorf("rate() = %v, want 5", rate)
+	}
+	// 最近10秒中只有5秒有数据
+	if rate := r.rate(now.Add(25 * time.Second)); rate != 2.5 {
+		t.Errorf("rate() = %v, want 2.5", rate)
+	}
+	if rate := r.rate(now.Add(100 * time.Second)); rate != 0 {
+		t.Errorf("rate() = %v, want 0", rate)
+	}
+}
+
+func TestSimpleMQ_PushAt(t *testing.T) {
+	var lock sync.Mutex
+	var got []int
+	mq := NewSimpleMQ(1, func(msg int) {
+		lock.Lock()
+		got = append(got, msg)
+		lock.Unlock()
+	})
+	now := time.Now()
+	mq.PushAt(3, now.Add(150*time.Millisecond))
+	mq.PushAt(1, now.Add(50*time.Millisecond))
+	mq.PushAfter(2, 100*time.Millisecond)
+	mq.PushAt(0, now.Add(-time.Second))
+	if n := mq.DelayedLen(); n != 3 {
+		t.Errorf("DelayedLen() = %v, want 3", n)
+	}
+	time.Sleep(20 * time.Millisecond)
+	lock.Lock()
+	if len(got) != 1 || got[0] != 0 {
+		t.Errorf("got %v before due, want [0]", got)
+	}
+	lock.Unlock()
+	deadline := time.Now().Add(2 * time.Second)
+	for mq.DelayedLen() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	want := []int{0, 1, 2, 3}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+			break
+		}
+	}
+	if err := mq.PushAfter(4, time.Second); err != ErrMQClosed {
+		t.Errorf("PushAfter() after Close error = %v, want %v", err, ErrMQClosed)
+	}
+}
+
+func TestSimpleMQ_CloseFlushesDelayed(t *testing.T) {
+	var count int32
+	mq := NewSimpleMQ(2, func(msg int) {
+		atomic.AddInt32(&count, 1)
+	})
+	for i := 0; i < 10; i++ {
+		mq.PushAfter(i, time.Hour)
+	}
+	if s := mq.Stats(); s.Delayed != 10 {
+		t.Errorf("Stats().Delayed = %v, want 10", s.Delayed)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := mq.Close(ctx); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if n := atomic.LoadInt32(&count); n != 10 {
+		t.Errorf("handled %v messages, want 10", n)
+	}
+}
@@ -0,0 +1,165 @@
+package messageQueue
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LatencyBuckets 消息处理耗时直方图的桶上界
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Stats 消息队列的运行状态，计数类字段均为启动以来的累计值
+type Stats struct {
+	// 队列中等待处理的消息数
+	Depth int
+	// 尚未到期的延迟消息数
+	Delayed int
+	// 累计成功放入队列的消息数
+	Pushed uint64
+	// 因队列已满被拒绝的消息数
+	Rejected uint64
+	// 因OverflowDropOldest被丢弃，或超过Redis Stream最大投递次数被丢弃的消息数
+	Dropped uint64
+	// 最近rateWindow秒的平均Push速率(条/秒)
+	PushRate float64
+	// 处理完成的消息数
+	Processed uint64
+	// 处理函数返回error的次数(批量模式下一个批次计一次)
+	Errors uint64
+	// LatencyCounts[i]为处理耗时不超过LatencyBuckets[i]的次数，
+	// 最后一个元素为总次数(即+Inf桶)
+	LatencyCounts []uint64
+	// 处理耗时总和
+	LatencySum time.Duration
+	WorkerNum  int
+	// 正在处理消息的worker数
+	BusyWorkers int
+	// 启动以来worker处于忙碌状态的时间占比，范围[0,1]
+	Utilization float64
+}
+
+// mqMetrics 消息队列的统计数据，所有方法都是并发安全的
+type mqMetrics struct {
+	startTime       time.Time
+	workerNum       int
+	pushed          atomic.Uint64
+	rejected        atomic.Uint64
+	dropped         atomic.Uint64
+	processed       atomic.Uint64
+	errors          atomic.Uint64
+	busyWorkers     atomic.Int64
+	busyNanos       atomic.Int64
+	latencyCounts   []atomic.Uint64
+	latencySumNanos atomic.Int64
+	pushRate        rateCounter
+}
+
+func newMQMetrics(workerNum int) *mqMetrics {
+	return &mqMetrics{
+		startTime:     time.Now(),
+		workerNum:     workerNum,
+		latencyCounts: make([]atomic.Uint64, len(LatencyBuckets)+1),
+	}
+}
+
+func (m *mqMetrics) onPush() {
+	m.pushed.Add(1)
+	m.pushRate.add(time.Now())
+}
+
+// observe 在消息处理函数前后调用，msgNum为本次处理的消息数
+func (m *mqMetrics) observe(msgNum int, handle func() error) {
+	m.busyWorkers.Add(1)
+	start := time.Now()
+	err := handle()
+	cost := time.Since(start)
+	m.busyWorkers.Add(-1)
+
+	m.busyNanos.Add(int64(cost))
+	m.processed.Add(uint64(msgNum))
+	if err != nil {
+		m.errors.Add(1)
+	}
+	for i, bucket := range LatencyBuckets {
+		if cost <= bucket {
+			m.latencyCounts[i].Add(1)
+		}
+	}
+	m.latencyCounts[len(LatencyBuckets)].Add(1)
+	m.latencySumNanos.Add(int64(cost))
+}
+
+func (m *mqMetrics) stats() Stats {
+	var s Stats
+	s.Pushed = m.pushed.Load()
+	s.Rejected = m.rejected.Load()
+	s.Dropped = m.dropped.Load()
+	s.PushRate = m.pushRate.rate(time.Now())
+	s.Processed = m.processed.Load()
+	s.Errors = m.errors.Load()
+	s.LatencyCounts = make([]uint64, len(m.latencyCounts))
+	for i := range m.latencyCounts {
+		s.LatencyCounts[i] = m.latencyCounts[i].Load()
+	}
+	s.LatencySum = time.Duration(m.latencySumNanos.Load())
+	s.WorkerNum = m.workerNum
+	s.BusyWorkers = int(m.busyWorkers.Load())
+	elapsed := time.Since(m.startTime)
+	if elapsed > 0 && m.workerNum > 0 {
+		s.Utilization = float64(m.busyNanos.Load()) / (float64(elapsed) * float64(m.workerNum))
+		if s.Utilization > 1 {
+			s.Utilization = 1
+		}
+	}
+	return s
+}
+
+// 计算Push速率的时间窗口(秒)
+const rateWindow = 10
+
+// rateCounter 按秒分桶统计最近rateWindow秒的事件数
+type rateCounter struct {
+	lock sync.Mutex
+	// 环形数组，seconds[i]为buckets[i]对应的unix秒
+	buckets [rateWindow + 1]uint64
+	seconds [rateWindow + 1]int64
+}
+
+func (r *rateCounter) add(now time.Time) {
+	sec := now.Unix()
+	i := sec % int64(len(r.buckets))
+	r.lock.Lock()
+	if r.seconds[i] != sec {
+		r.seconds[i] = sec
+		r.buckets[i] = 0
+	}
+	r.buckets[i]++
+	r.lock.Unlock()
+}
+
+// rate 返回最近rateWindow个完整秒内的平均速率，不包含当前这一秒
+func (r *rateCounter) rate(now time.Time) float64 {
+	sec := now.Unix()
+	var sum uint64
+	r.lock.Lock()
+	for i := range r.buckets {
+		if d := sec - r.seconds[i]; d >= 1 && d <= rateWindow {
+			sum += r.buckets[i]
+		}
+	}
+	r.lock.Unlock()
+	return float64(sum) / rateWindow
+}
@@ -1,8 +1,19 @@
 package messageQueue
 
+import (
+	"context"
+	"time"
+)
+
 type MQ[T any] interface {
 	// Push push a message to queue
-	Push(T)
+	Push(T) error
+	// PushContext push a message to queue, if the queue is full and blocks,
+	// wait until ctx is done and return ctx.Err()
+	PushContext(context.Context, T) error
+	// TryPush push a message to queue without blocking,
+	// return ErrMQFull if the queue is full
+	TryPush(T) error
 	// Pop pop a message from queue
 	//Pop()
 	// PopWithTimeout pop a message from queue with timeout
@@ -10,4 +21,19 @@ type MQ[T any] interface {
 
 	// Len get the length of queue
 	Len() int
+
+	// Stats get the running statistics of queue
+	Stats() Stats
+
+	// Close stop accepting messages and wait for the queue to drain
+	Close(ctx context.Context) error
+}
+
+// DelayMQ 支持延迟投递的消息队列
+type DelayMQ[T any] interface {
+	MQ[T]
+	// PushAt push a message to queue at the given time
+	PushAt(T, time.Time) error
+	// PushAfter push a message to queue after the given duration
+	PushAfter(T, time.Duration) error
 }
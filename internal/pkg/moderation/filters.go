@@ -0,0 +1,137 @@
+package moderation
+
+import (
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	ReasonEmpty         = "内容为空"
+	ReasonTooLong       = "内容过长"
+	ReasonTooManyLinks  = "链接过多"
+	ReasonSensitiveWord = "包含敏感词"
+	ReasonTooFrequent   = "发布过于频繁"
+	ReasonDuplicate     = "重复发布相同内容"
+)
+
+// LengthFilter 限制内容长度，为0的限制不生效
+type LengthFilter struct {
+	// 是否允许空内容
+	AllowEmpty bool
+	// 最大字符数
+	MaxRunes int
+	// 最大字节数
+	MaxBytes int
+}
+
+func (f LengthFilter) Check(content Content) Result {
+	if !f.AllowEmpty && len(content.Text) == 0 {
+		return Result{Action: Reject, Text: content.Text, Reason: ReasonEmpty}
+	}
+	if f.MaxBytes > 0 && len(content.Text) > f.MaxBytes {
+		return Result{Action: Reject, Text: content.Text, Reason: ReasonTooLong}
+	}
+	if f.MaxRunes > 0 && utf8.RuneCountInString(content.Text) > f.MaxRunes {
+		return Result{Action: Reject, Text: content.Text, Reason: ReasonTooLong}
+	}
+	return accept(content)
+}
+
+var linkRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+`)
+
+// LinkFilter 限制内容中的链接数，超过MaxLinks时返回Action(默认Reject)
+type LinkFilter struct {
+	MaxLinks int
+	Action   Action
+}
+
+func (f LinkFilter) Check(content Content) Result {
+	if len(linkRegexp.FindAllStringIndex(content.Text, f.MaxLinks+1)) <= f.MaxLinks {
+		return accept(content)
+	}
+	action := f.Action
+	if action == Accept {
+		action = Reject
+	}
+	return Result{Action: action, Text: content.Text, Reason: ReasonTooManyLinks}
+}
+
+// RateFilter 按用户限制发布频率：
+// Window内发布超过MaxCount条，或重复发布相同内容超过MaxDuplicate条时返回Action(默认Hold)。
+// 为0的限制不生效。
+type RateFilter struct {
+	Window       time.Duration
+	MaxCount     int
+	MaxDuplicate int
+	Action       Action
+
+	lock    sync.Mutex
+	history map[uint][]rateRecord
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+type rateRecord struct {
+	at   time.Time
+	text string
+}
+
+func NewRateFilter(window time.Duration, maxCount int, maxDuplicate int, action Action) *RateFilter {
+	if action == Accept {
+		action = Hold
+	}
+	return &RateFilter{
+		Window:       window,
+		MaxCount:     maxCount,
+		MaxDuplicate: maxDuplicate,
+		Action:       action,
+		history:      make(map[uint][]rateRecord),
+		now:          time.Now,
+	}
+}
+
+func (f *RateFilter) Check(content Content) Result {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	now := f.now()
+	// 清理窗口外的记录
+	records := f.history[content.UserID]
+	i := 0
+	for i < len(records) && now.Sub(records[i].at) >= f.Window {
+		i++
+	}
+	records = append(records[i:], rateRecord{at: now, text: content.Text})
+	f.history[content.UserID] = records
+	f.sweep(now)
+
+	if f.MaxCount > 0 && len(records) > f.MaxCount {
+		return Result{Action: f.Action, Text: content.Text, Reason: ReasonTooFrequent}
+	}
+	if f.MaxDuplicate > 0 {
+		duplicate := 0
+		for _, r := range records {
+			if r.text == content.Text {
+				duplicate++
+			}
+		}
+		if duplicate > f.MaxDuplicate {
+			return Result{Action: f.Action, Text: content.Text, Reason: ReasonDuplicate}
+		}
+	}
+	return accept(content)
+}
+
+// sweep 记录的用户过多时，清理窗口内没有发布记录的用户(调用需要加锁)
+func (f *RateFilter) sweep(now time.Time) {
+	const maxUsers = 100000
+	if len(f.history) <= maxUsers {
+		return
+	}
+	for userID, records := range f.history {
+		if len(records) == 0 || now.Sub(records[len(records)-1].at) >= f.Window {
+			delete(f.history, userID)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package moderation
+
+// Action 审核结果，按严重程度递增
+type Action int
+
+const (
+	// 通过
+	Accept Action = iota
+	// 通过，但部分内容被替换为*
+	Mask
+	// 暂不公开，等待人工审核
+	Hold
+	// 拒绝
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Mask:
+		return "mask"
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	}
+	return "unknown"
+}
+
+// Content 待审核的内容
+type Content struct {
+	// 发布者id，用于频率限制等按用户统计的规则
+	UserID uint
+	Text   string
+}
+
+// Result 审核结果
+type Result struct {
+	Action Action
+	// 审核后的内容，部分内容可能已被替换为*
+	Text string
+	// 未通过或被替换的原因
+	Reason string
+}
+
+// Filter 审核规则
+type Filter interface {
+	// Check 审核内容，Text为之前的规则处理后的内容
+	Check(content Content) Result
+}
+
+// FilterFunc 函数形式的Filter
+type FilterFunc func(content Content) Result
+
+func (f FilterFunc) Check(content Content) Result {
+	return f(content)
+}
+
+// Chain 依次执行多个审核规则，结果取最严重的一个，遇到Reject时立即返回。
+// 前一个规则处理后的内容会传给后一个规则。
+type Chain struct {
+	filters []Filter
+}
+
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{filters: filters}
+}
+
+func (c *Chain) Check(content Content) Result {
+	res := Result{Action: Accept, Text: content.Text}
+	for _, filter := range c.filters {
+		r := filter.Check(content)
+		if r.Action != Reject {
+			content.Text = r.Text
+			res.Text = r.Text
+		}
+		if r.Action > res.Action {
+			res.Action = r.Action
+			res.Reason = r.Reason
+		}
+		if res.Action == Reject {
+			return res
+		}
+	}
+	return res
+}
+
+func accept(content Content) Result {
+	return Result{Action: Accept, Text: content.Text}
+}
@@ -0,0 +1,151 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestWordFilter(t *testing.T) *SensitiveWordFilter {
+	f := NewSensitiveWordFilter()
+	dict := `
+# 测试词典
+坏蛋
+坏人,mask
+广告,hold
+Spam,reject
+`
+	if err := f.LoadWords(strings.NewReader(dict)); err != nil {
+		t.Fatalf("LoadWords() error = %v", err)
+	}
+	return f
+}
+
+func TestSensitiveWordFilter(t *testing.T) {
+	f := newTestWordFilter(t)
+	tests := []struct {
+		text   string
+		action Action
+		want   string
+	}{
+		{"你好", Accept, "你好"},
+		{"你是坏蛋", Mask, "你是**"},
+		{"坏人坏蛋坏", Mask, "****坏"},
+		{"看广告", Hold, "看广告"},
+		{"this is SPAM", Reject, "this is SPAM"},
+		{"坏蛋的广告", Hold, "**的广告"},
+		{"", Accept, ""},
+	}
+	for _, tt := range tests {
+		res := f.Check(Content{Text: tt.text})
+		if res.Action != tt.action || res.Text != tt.want {
+			t.Errorf("Check(%q) = %v %q, want %v %q", tt.text, res.Action, res.Text, tt.action, tt.want)
+		}
+	}
+}
+
+func TestSensitiveWordFilter_LongestMatch(t *testing.T) {
+	f := NewSensitiveWordFilter()
+	f.AddWord("ab", Mask)
+	f.AddWord("abcd", Mask)
+	res := f.Check(Content{Text: "xabcdx abx"})
+	if res.Text != "x****x **x" {
+		t.Errorf("Check() = %q", res.Text)
+	}
+}
+
+func TestLengthFilter(t *testing.T) {
+	f := LengthFilter{MaxRunes: 3, MaxBytes: 8}
+	tests := []struct {
+		text   string
+		action Action
+	}{
+		{"", Reject},
+		{"abc", Accept},
+		{"abcd", Reject},
+		{"你好呀", Reject},
+		{"你好", Accept},
+	}
+	for _, tt := range tests {
+		if res := f.Check(Content{Text: tt.text}); res.Action != tt.action {
+			t.Errorf("Check(%q) = %v, want %v", tt.text, res.Action, tt.action)
+		}
+	}
+	if res := (LengthFilter{AllowEmpty: true}).Check(Content{}); res.Action != Accept {
+		t.Errorf("AllowEmpty Check() = %v, want accept", res.Action)
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	f := LinkFilter{MaxLinks: 1}
+	if res := f.Check(Content{Text: "看 https://a.com"}); res.Action != Accept {
+		t.Errorf("one link = %v, want accept", res.Action)
+	}
+	if res := f.Check(Content{Text: "http://a.com www.b.com"}); res.Action != Reject || res.Reason != ReasonTooManyLinks {
+		t.Errorf("two links = %v %v, want reject", res.Action, res.Reason)
+	}
+	f = LinkFilter{MaxLinks: 0, Action: Hold}
+	if res := f.Check(Content{Text: "HTTP://A.COM"}); res.Action != Hold {
+		t.Errorf("no links allowed = %v, want hold", res.Action)
+	}
+}
+
+func TestRateFilter(t *testing.T) {
+	f := NewRateFilter(time.Minute, 3, 1, Accept)
+	base := time.Now()
+	var offset time.Duration
+	f.now = func() time.Time { return base.Add(offset) }
+
+	for i, text := range []string{"a", "b", "c"} {
+		if res := f.Check(Content{UserID: 1, Text: text}); res.Action != Accept {
+			t.Fatalf("check %v = %v, want accept", i, res.Action)
+		}
+	}
+	if res := f.Check(Content{UserID: 1, Text: "d"}); res.Action != Hold || res.Reason != ReasonTooFrequent {
+		t.Errorf("4th check = %v %v, want hold too frequent", res.Action, res.Reason)
+	}
+	// 其他用户不受影响
+	if res := f.Check(Content{UserID: 2, Text: "a"}); res.Action != Accept {
+		t.Errorf("other user = %v, want accept", res.Action)
+	}
+	if res := f.Check(Content{UserID: 2, Text: "a"}); res.Action != Hold || res.Reason != ReasonDuplicate {
+		t.Errorf("duplicate = %v %v, want hold duplicate", res.Action, res.Reason)
+	}
+	// 窗口过后恢复
+	offset = time.Minute
+	if res := f.Check(Content{UserID: 1, Text: "e"}); res.Action != Accept {
+		t.Errorf("after window = %v, want accept", res.Action)
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := NewChain(LengthFilter{MaxRunes: 10}, newTestWordFilter(t), LinkFilter{MaxLinks: 0})
+	tests := []struct {
+		text   string
+		action Action
+		want   string
+	}{
+		{"你好", Accept, "你好"},
+		{"坏蛋", Mask, "**"},
+		{"坏蛋广告", Hold, "**广告"},
+		{"坏蛋 www.a.com", Reject, ""},
+		{"这是一条非常非常长的评论", Reject, ""},
+	}
+	for _, tt := range tests {
+		res := chain.Check(Content{Text: tt.text})
+		if res.Action != tt.action || (tt.want != "" && res.Text != tt.want) {
+			t.Errorf("Check(%q) = %v %q, want %v %q", tt.text, res.Action, res.Text, tt.action, tt.want)
+		}
+	}
+
+	// 后续规则看到的是替换后的内容
+	var seen string
+	chain = NewChain(newTestWordFilter(t), FilterFunc(func(c Content) Result {
+		seen = c.Text
+		return Result{Action: Accept, Text: c.Text}
+	}))
+	chain.Check(Content{Text: "坏蛋"})
+	if seen != "**" {
+		t.Errorf("next filter saw %q, want masked text", seen)
+	}
+}
@@ -0,0 +1,122 @@
+package moderation
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+)
+
+const maskRune = '*'
+
+type trieNode struct {
+	children map[rune]*trieNode
+	// 是否为敏感词的结尾
+	end bool
+	// 命中该敏感词时的处理方式
+	action Action
+}
+
+// SensitiveWordFilter 基于字典树的敏感词过滤，忽略英文大小写。
+// 命中Mask级别的敏感词时替换为*，命中更严重级别的敏感词时返回对应的Action。
+type SensitiveWordFilter struct {
+	root *trieNode
+}
+
+func NewSensitiveWordFilter() *SensitiveWordFilter {
+	return &SensitiveWordFilter{root: &trieNode{children: make(map[rune]*trieNode)}}
+}
+
+// AddWord 添加敏感词，action为命中时的处理方式，Accept视为Mask
+func (f *SensitiveWordFilter) AddWord(word string, action Action) {
+	word = strings.TrimSpace(word)
+	if word == "" {
+		return
+	}
+	if action == Accept {
+		action = Mask
+	}
+	node := f.root
+	for _, r := range word {
+		r = unicode.ToLower(r)
+		child, ok := node.children[r]
+		if !ok {
+			child = &trieNode{children: make(map[rune]*trieNode)}
+			node.children[r] = child
+		}
+		node = child
+	}
+	node.end = true
+	if action > node.action {
+		node.action = action
+	}
+}
+
+// LoadWords 从reader中读取敏感词，每行一个。
+// 行格式为"敏感词"或"敏感词,级别"，级别为mask/hold/reject，默认mask。
+// 空行和#开头的行会被忽略。
+func (f *SensitiveWordFilter) LoadWords(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, level, _ := strings.Cut(line, ",")
+		f.AddWord(word, parseAction(strings.TrimSpace(level)))
+	}
+	return scanner.Err()
+}
+
+func parseAction(level string) Action {
+	switch strings.ToLower(level) {
+	case "hold":
+		return Hold
+	case "reject":
+		return Reject
+	}
+	return Mask
+}
+
+// match 从text[start]开始查找最长的敏感词，返回敏感词的字符数和处理方式
+func (f *SensitiveWordFilter) match(text []rune, start int) (int, Action) {
+	node := f.root
+	length := 0
+	action := Accept
+	for i := start; i < len(text); i++ {
+		child, ok := node.children[unicode.ToLower(text[i])]
+		if !ok {
+			break
+		}
+		node = child
+		if node.end {
+			length = i - start + 1
+			action = node.action
+		}
+	}
+	return length, action
+}
+
+func (f *SensitiveWordFilter) Check(content Content) Result {
+	text := []rune(content.Text)
+	res := Result{Action: Accept}
+	for i := 0; i < len(text); {
+		length, action := f.match(text, i)
+		if length == 0 {
+			i++
+			continue
+		}
+		if action == Mask {
+			for j := i; j < i+length; j++ {
+				text[j] = maskRune
+			}
+		}
+		if action > res.Action {
+			res.Action = action
+			res.Reason = ReasonSensitiveWord
+		}
+		i += length
+	}
+	res.Text = string(text)
+	return res
+}
@@ -0,0 +1,105 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// List 已吊销的token id列表，记录在ttl后过期(ttl应不短于token的剩余有效期)
+type List interface {
+	// Revoke 吊销id，id此前未被吊销时返回true
+	Revoke(id string, ttl time.Duration) (bool, error)
+	// IsRevoked id是否已被吊销
+	IsRevoked(id string) (bool, error)
+}
+
+// 过期记录的清理间隔
+const memorySweepInterval = time.Minute
+
+// MemoryList 进程内的吊销列表，只在单个服务副本内有效
+type MemoryList struct {
+	lock      sync.Mutex
+	items     map[string]time.Time
+	lastSweep time.Time
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+func NewMemoryList() *MemoryList {
+	return &MemoryList{
+		items:     make(map[string]time.Time),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// 是否已吊销且未过期(调用需要加锁)
+func (l *MemoryList) revoked(id string, now time.Time) bool {
+	expireAt, ok := l.items[id]
+	if !ok {
+		return false
+	}
+	if !now.Before(expireAt) {
+		delete(l.items, id)
+		return false
+	}
+	return true
+}
+
+func (l *MemoryList) Revoke(id string, ttl time.Duration) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	if l.revoked(id, now) {
+		return false, nil
+	}
+	l.items[id] = now.Add(ttl)
+	if now.Sub(l.lastSweep) >= memorySweepInterval {
+		l.lastSweep = now
+		for k, expireAt := range l.items {
+			if !now.Before(expireAt) {
+				delete(l.items, k)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (l *MemoryList) IsRevoked(id string) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.revoked(id, l.now()), nil
+}
+
+// Len 返回记录数(可能包含尚未清理的过期记录)
+func (l *MemoryList) Len() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return len(l.items)
+}
+
+// RedisList 基于Redis的吊销列表，多个服务副本共享
+type RedisList struct {
+	client redis.UniversalClient
+	// key前缀
+	prefix string
+}
+
+func NewRedisList(client redis.UniversalClient, prefix string) *RedisList {
+	return &RedisList{client: client, prefix: prefix}
+}
+
+func (l *RedisList) Revoke(id string, ttl time.Duration) (bool, error) {
+	return l.client.SetNX(context.Background(), l.prefix+id, 1, ttl).Result()
+}
+
+func (l *RedisList) IsRevoked(id string) (bool, error) {
+	n, err := l.client.Exists(context.Background(), l.prefix+id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
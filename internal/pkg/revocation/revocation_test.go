@@ -0,0 +1,66 @@
+package revocation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func testList(t *testing.T, list List, expire func(time.Duration)) {
+	ttl := time.Minute
+	if revoked, err := list.IsRevoked("a"); err != nil || revoked {
+		t.Fatalf("IsRevoked() = %v, %v, want false", revoked, err)
+	}
+	if ok, err := list.Revoke("a", ttl); err != nil || !ok {
+		t.Fatalf("Revoke() = %v, %v, want true", ok, err)
+	}
+	if ok, err := list.Revoke("a", ttl); err != nil || ok {
+		t.Fatalf("second Revoke() = %v, %v, want false", ok, err)
+	}
+	if revoked, err := list.IsRevoked("a"); err != nil || !revoked {
+		t.Fatalf("IsRevoked() after Revoke = %v, %v, want true", revoked, err)
+	}
+	if revoked, _ := list.IsRevoked("b"); revoked {
+		t.Errorf("IsRevoked(b) = true, want false")
+	}
+
+	expire(ttl)
+	if revoked, _ := list.IsRevoked("a"); revoked {
+		t.Errorf("IsRevoked() after ttl = true, want false")
+	}
+	if ok, _ := list.Revoke("a", ttl); !ok {
+		t.Errorf("Revoke() after ttl = false, want true")
+	}
+}
+
+func TestMemoryList(t *testing.T) {
+	list := NewMemoryList()
+	var offset time.Duration
+	list.now = func() time.Time { return time.Now().Add(offset) }
+	testList(t, list, func(d time.Duration) { offset += d })
+}
+
+func TestMemoryList_Sweep(t *testing.T) {
+	list := NewMemoryList()
+	var offset time.Duration
+	list.now = func() time.Time { return time.Now().Add(offset) }
+	list.Revoke("a", time.Second)
+	list.Revoke("b", time.Second)
+	offset = 2 * memorySweepInterval
+	list.Revoke("c", time.Second)
+	if n := list.Len(); n != 1 {
+		t.Errorf("Len() after sweep = %d, want 1", n)
+	}
+}
+
+func TestRedisList(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	testList(t, NewRedisList(client, "test:revoked:"), mr.FastForward)
+	if !mr.Exists("test:revoked:a") {
+		t.Errorf("key without prefix")
+	}
+}
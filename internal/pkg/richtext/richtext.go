@@ -0,0 +1,100 @@
+package richtext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TokenType 文本中结构化片段的类型
+type TokenType int
+
+const (
+	// @用户名
+	Mention TokenType = iota + 1
+	// #话题
+	Hashtag
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case Mention:
+		return "mention"
+	case Hashtag:
+		return "hashtag"
+	}
+	return "unknown"
+}
+
+const (
+	MentionPrefix = '@'
+	HashtagPrefix = '#'
+	// 用户名最大字节数，与注册时的限制一致
+	MaxMentionBytes = 32
+	// 话题最大字符数
+	MaxHashtagRunes = 50
+)
+
+// Token 文本中的一个@或#片段，Start和End为字符(rune)下标，[Start, End)包含前缀
+type Token struct {
+	Type  TokenType
+	Start int
+	End   int
+	// 不含前缀的用户名或话题名
+	Value string
+}
+
+// 用户名中不能出现的分隔符
+const mentionStopRunes = "@#,.!?;:'\"()[]{}<>，。！？、；：“”‘’（）【】《》"
+
+func isMentionRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(mentionStopRunes, r)
+}
+
+func isASCIIAlnum(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isHashtagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Parse 解析文本中的@用户名和#话题，按出现顺序返回。
+// 前缀前面紧挨着ASCII字母或数字时(如邮箱地址)不解析。
+func Parse(text string) []Token {
+	runes := []rune(text)
+	var tokens []Token
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r != MentionPrefix && r != HashtagPrefix {
+			continue
+		}
+		if i > 0 && isASCIIAlnum(runes[i-1]) {
+			continue
+		}
+		end := i + 1
+		if r == MentionPrefix {
+			bytes := 0
+			for end < len(runes) && isMentionRune(runes[end]) {
+				bytes += len(string(runes[end]))
+				if bytes > MaxMentionBytes {
+					break
+				}
+				end++
+			}
+		} else {
+			for end < len(runes) && isHashtagRune(runes[end]) && end-i-1 < MaxHashtagRunes {
+				end++
+			}
+		}
+		if end == i+1 {
+			continue
+		}
+		token := Token{Type: Mention, Start: i, End: end, Value: string(runes[i+1 : end])}
+		if r == HashtagPrefix {
+			token.Type = Hashtag
+		}
+		tokens = append(tokens, token)
+		i = end - 1
+	}
+	return tokens
+}
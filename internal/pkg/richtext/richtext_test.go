@@ -0,0 +1,49 @@
+package richtext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want []Token
+	}{
+		{"", nil},
+		{"没有标记", nil},
+		{"@alice 你好", []Token{{Mention, 0, 6, "alice"}}},
+		{"你好@小明，看#美食 和 #旅行_2023!", []Token{
+			{Mention, 2, 5, "小明"},
+			{Hashtag, 7, 10, "美食"},
+			{Hashtag, 13, 21, "旅行_2023"},
+		}},
+		{"@a@b", []Token{{Mention, 0, 2, "a"}}},
+		{"@a @b", []Token{{Mention, 0, 2, "a"}, {Mention, 3, 5, "b"}}},
+		// 邮箱地址不是@
+		{"mail me: bob@example.com", nil},
+		// 单独的前缀
+		{"@ # @", nil},
+		{"#话题#另一个", []Token{{Hashtag, 0, 3, "话题"}, {Hashtag, 3, 7, "另一个"}}},
+	}
+	for _, tt := range tests {
+		got := Parse(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParse_MaxLength(t *testing.T) {
+	name := strings.Repeat("a", MaxMentionBytes+5)
+	got := Parse("@" + name)
+	if len(got) != 1 || got[0].Value != name[:MaxMentionBytes] {
+		t.Errorf("Parse() mention = %v, want truncated to %v bytes", got, MaxMentionBytes)
+	}
+	tag := strings.Repeat("话", MaxHashtagRunes+5)
+	got = Parse("#" + tag)
+	if len(got) != 1 || len([]rune(got[0].Value)) != MaxHashtagRunes {
+		t.Errorf("Parse() hashtag = %v, want truncated to %v runes", got, MaxHashtagRunes)
+	}
+}
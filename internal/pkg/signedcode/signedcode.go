@@ -0,0 +1,137 @@
+// Package signedcode 签发和校验带签名的一次性验证码，用于邮箱验证和重置密码等链接。
+//
+// 验证码为 base64url(载荷).base64url(HMAC-SHA256签名)，载荷中包含用途、用户id、邮箱、
+// 签发时的用户版本、过期时间和随机数。服务端不保存未使用的验证码，只在使用后记录其随机数直到过期，
+// 因此每个验证码只能使用一次。
+package signedcode
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/internal/pkg/revocation"
+)
+
+var (
+	ErrInvalidCode = errors.New("signedcode: invalid code")
+	ErrCodeExpired = errors.New("signedcode: code expired")
+	ErrCodeUsed    = errors.New("signedcode: code already used")
+	ErrKeyTooShort = errors.New("signedcode: key must be at least 32 bytes")
+)
+
+// 签名密钥的最小长度
+const MinKeyLength = 32
+
+// Claims 验证码中的信息
+type Claims struct {
+	// 用途，不同用途的验证码不能混用
+	Purpose string `json:"p"`
+	UserID  uint   `json:"u"`
+	Email   string `json:"e,omitempty"`
+	// 签发时的用户版本，调用方校验版本未变化，版本增加后之前签发的验证码全部无效
+	Version   uint   `json:"v,omitempty"`
+	ExpiresAt int64  `json:"x"`
+	Nonce     string `json:"n"`
+}
+
+type Signer struct {
+	key []byte
+	// 已使用的验证码
+	used revocation.List
+	// 获取当前时间，便于测试
+	now func() time.Time
+}
+
+// NewSigner used保存已使用的验证码，多个服务副本时应使用共享的存储
+func NewSigner(key []byte, used revocation.List) (*Signer, error) {
+	if len(key) < MinKeyLength {
+		return nil, ErrKeyTooShort
+	}
+	return &Signer{key: key, used: used, now: time.Now}, nil
+}
+
+// Issue 签发验证码，有效期为ttl，version为用户当前的版本
+func (s *Signer) Issue(purpose string, userID uint, email string, version uint, ttl time.Duration) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(Claims{
+		Purpose:   purpose,
+		UserID:    userID,
+		Email:     email,
+		Version:   version,
+		ExpiresAt: s.now().Add(ttl).Unix(),
+		Nonce:     hex.EncodeToString(nonce),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(s.sign(encoded)), nil
+}
+
+func (s *Signer) sign(encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
+
+// Verify 校验验证码的签名、用途、有效期以及是否已被使用，不会使验证码失效
+func (s *Signer) Verify(code string, purpose string) (Claims, error) {
+	var claims Claims
+	encoded, sig, ok := strings.Cut(code, ".")
+	if !ok {
+		return claims, ErrInvalidCode
+	}
+	sigBytes, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(sigBytes, s.sign(encoded)) {
+		return claims, ErrInvalidCode
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, ErrInvalidCode
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Purpose != purpose || claims.Nonce == "" {
+		return Claims{}, ErrInvalidCode
+	}
+	if !s.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Claims{}, ErrCodeExpired
+	}
+	used, err := s.used.IsRevoked(claims.Nonce)
+	if err != nil {
+		return Claims{}, err
+	}
+	if used {
+		return Claims{}, ErrCodeUsed
+	}
+	return claims, nil
+}
+
+// Consume 校验验证码并使其失效，并发使用同一验证码时只有一个能成功
+func (s *Signer) Consume(code string, purpose string) (Claims, error) {
+	claims, err := s.Verify(code, purpose)
+	if err != nil {
+		return claims, err
+	}
+	// 记录保留到验证码过期
+	ttl := time.Unix(claims.ExpiresAt, 0).Sub(s.now())
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	first, err := s.used.Revoke(claims.Nonce, ttl)
+	if err != nil {
+		return Claims{}, err
+	}
+	if !first {
+		return Claims{}, ErrCodeUsed
+	}
+	return claims, nil
+}
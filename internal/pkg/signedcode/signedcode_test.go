@@ -0,0 +1,126 @@
+package signedcode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/internal/pkg/revocation"
+)
+
+var testKey = bytes.Repeat([]byte("k"), MinKeyLength)
+
+func newTestSigner(t *testing.T) *Signer {
+	s, err := NewSigner(testKey, revocation.NewMemoryList())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNewSigner_ShortKey(t *testing.T) {
+	if _, err := NewSigner([]byte("short"), revocation.NewMemoryList()); !errors.Is(err, ErrKeyTooShort) {
+		t.Errorf("NewSigner() error = %v, want ErrKeyTooShort", err)
+	}
+}
+
+func TestSigner_IssueConsume(t *testing.T) {
+	s := newTestSigner(t)
+	code, err := s.Issue("verify", 42, "a@example.com", 3, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.Verify(code, "verify")
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if claims.UserID != 42 || claims.Email != "a@example.com" || claims.Version != 3 {
+		t.Errorf("Verify() claims = %+v", claims)
+	}
+	// Verify不会使验证码失效
+	if _, err := s.Consume(code, "verify"); err != nil {
+		t.Fatalf("Consume() error = %v", err)
+	}
+	if _, err := s.Consume(code, "verify"); !errors.Is(err, ErrCodeUsed) {
+		t.Errorf("second Consume() error = %v, want ErrCodeUsed", err)
+	}
+	if _, err := s.Verify(code, "verify"); !errors.Is(err, ErrCodeUsed) {
+		t.Errorf("Verify() after Consume error = %v, want ErrCodeUsed", err)
+	}
+	// 每次签发的验证码不同
+	other, _ := s.Issue("verify", 42, "a@example.com", 0, time.Hour)
+	if other == code {
+		t.Errorf("Issue() returned the same code twice")
+	}
+}
+
+func TestSigner_Invalid(t *testing.T) {
+	s := newTestSigner(t)
+	code, _ := s.Issue("verify", 1, "a@example.com", 0, time.Hour)
+	payload, sig, _ := strings.Cut(code, ".")
+	otherKey, _ := NewSigner(bytes.Repeat([]byte("x"), MinKeyLength), revocation.NewMemoryList())
+	otherCode, _ := otherKey.Issue("verify", 1, "a@example.com", 0, time.Hour)
+	otherPayload, _, _ := strings.Cut(otherCode, ".")
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"empty", ""},
+		{"no signature", payload},
+		{"bad signature encoding", payload + ".!!"},
+		{"truncated signature", payload + "." + sig[:10]},
+		{"other key", otherCode},
+		{"swapped payload", otherPayload + "." + sig},
+		{"appended", code + "a"},
+	}
+	for _, tt := range tests {
+		if _, err := s.Verify(tt.code, "verify"); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("%s: Verify() error = %v, want ErrInvalidCode", tt.name, err)
+		}
+	}
+	// 不同用途的验证码不能混用
+	if _, err := s.Consume(code, "reset"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Consume() wrong purpose error = %v, want ErrInvalidCode", err)
+	}
+	if _, err := s.Consume(code, "verify"); err != nil {
+		t.Errorf("Consume() after wrong purpose error = %v", err)
+	}
+}
+
+func TestSigner_Expired(t *testing.T) {
+	s := newTestSigner(t)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	code, _ := s.Issue("reset", 1, "", 0, time.Minute)
+	now = now.Add(time.Minute)
+	if _, err := s.Consume(code, "reset"); !errors.Is(err, ErrCodeExpired) {
+		t.Errorf("Consume() error = %v, want ErrCodeExpired", err)
+	}
+}
+
+func TestSigner_ConcurrentConsume(t *testing.T) {
+	s := newTestSigner(t)
+	code, _ := s.Issue("reset", 1, "", 0, time.Hour)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	success := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Consume(code, "reset"); err == nil {
+				lock.Lock()
+				success++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if success != 1 {
+		t.Errorf("%d concurrent Consume() succeeded, want 1", success)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"errors"
 
 	"github.com/Doraemonkeys/douyin2/config"
+	"github.com/Doraemonkeys/douyin2/internal/pkg/idgen"
 	"github.com/Doraemonkeys/douyin2/utils"
 	"gorm.io/gorm"
 )
@@ -238,5 +239,12 @@ func generateNewVideoName() string {
 }
 
 func (s *LocalDouyinVedioSaver) CreateVedio(video *VedioObjectModel) error {
+	if video.UID == 0 {
+		uid, err := idgen.NextID()
+		if err != nil {
+			return err
+		}
+		video.UID = uid
+	}
 	return s.db.Create(video).Error
 }
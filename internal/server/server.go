@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
 
@@ -10,11 +13,15 @@ import (
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/favorite"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/feed"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/follow"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/metrics"
+	"github.com/Doraemonkeys/douyin2/internal/app/handlers/notification"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/publish"
 	"github.com/Doraemonkeys/douyin2/internal/app/handlers/user"
 	"github.com/Doraemonkeys/douyin2/internal/app/middleware"
+	"github.com/Doraemonkeys/douyin2/pkg/log"
 	"github.com/Doraemonkeys/douyin2/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
 type DouyinServer struct {
@@ -22,15 +29,59 @@ type DouyinServer struct {
 	PanicHandler gin.HandlerFunc
 	// Service
 	// ...
+	httpServer *http.Server
+	// 监控接口单独监听的服务，未配置时为nil
+	metricsServer *http.Server
 }
 
 func NewDouyinServer() *DouyinServer {
 	router := initDouyinRouter()
-	return &DouyinServer{Router: router}
+	s := &DouyinServer{
+		Router:     router,
+		httpServer: &http.Server{Handler: router},
+	}
+	if conf := config.GetMetricsConfig(); conf.ListenAddr != "" {
+		s.metricsServer = &http.Server{Addr: conf.ListenAddr, Handler: initMetricsRouter(conf)}
+	}
+	return s
 }
 
+// Run 启动HTTP服务，阻塞直到服务出错或被Shutdown。
+// 被Shutdown时返回nil。
 func (s *DouyinServer) Run(addr string) error {
-	return s.Router.Run(addr)
+	if s.metricsServer != nil {
+		go func() {
+			err := s.metricsServer.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logrus.Error("启动监控接口失败, error: ", err)
+			}
+		}()
+	}
+	s.httpServer.Addr = addr
+	err := s.httpServer.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Shutdown 停止接收新的请求，并等待正在处理的请求完成
+func (s *DouyinServer) Shutdown(ctx context.Context) error {
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			logrus.Error("关闭监控接口失败, error: ", err)
+		}
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+// initMetricsRouter 监控接口只在单独的地址上提供，不挂在对外的路由上
+func initMetricsRouter(conf config.MetricsConfig) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	// 消息队列监控，供Prometheus抓取
+	router.GET("/metrics", metrics.TokenAuth(conf.Token), metrics.MQMetricsHandler)
+	return router
 }
 
 func initPanicLogWriter() io.Writer {
@@ -45,9 +96,15 @@ func initPanicLogWriter() io.Writer {
 // middleware -> handler -> service -> cache -> database
 func initDouyinRouter() *gin.Engine {
 	router := gin.New()
+	// 默认信任所有代理，客户端可以伪造X-Forwarded-For绕过按IP的登录限制
+	if err := router.SetTrustedProxies(config.GetTrustedProxies()); err != nil {
+		logrus.Panic("可信代理配置错误, error: ", err)
+	}
 	writer := io.MultiWriter(initPanicLogWriter(), os.Stdout)
 	if config.IsDebug() {
-		router.Use(gin.Logger(), gin.RecoveryWithWriter(writer))
+		// 访问日志中隐藏查询参数里的密码和token
+		accessLogWriter := log.NewRedactWriter(gin.DefaultWriter, log.NewRedactor())
+		router.Use(gin.LoggerWithWriter(accessLogWriter), gin.RecoveryWithWriter(writer))
 	} else {
 		router.Use(gin.RecoveryWithWriter(writer))
 	}
@@ -59,20 +116,40 @@ func initDouyinRouter() *gin.Engine {
 	baseGroup.GET("/feed", middleware.JWTMiddleWare("/douyin/feed"), feed.FeedVideoListHandler)
 	baseGroup.POST("/user/register/", user.UserRegisterHandler)
 	baseGroup.POST("/user/login/", middleware.UserLoginHandler)
+	baseGroup.POST("/user/token/refresh/", middleware.RefreshTokenHandler)
+	baseGroup.POST("/user/logout/", middleware.JWTMiddleWare(), middleware.LogoutHandler)
+	baseGroup.POST("/user/logout/all/", middleware.JWTMiddleWare(), middleware.LogoutAllHandler)
+	baseGroup.POST("/user/email/verify/", user.PostEmailVerifyHandler)
+	baseGroup.POST("/user/email/resend/", middleware.JWTMiddleWare(), user.PostEmailResendHandler)
+	baseGroup.POST("/user/password/forgot/", user.PostPasswordForgotHandler)
+	baseGroup.POST("/user/password/reset/", user.PostPasswordResetHandler)
 	baseGroup.GET("/user/", middleware.JWTMiddleWare(), user.GetUserInfoHandler)
 	baseGroup.POST("/publish/action/", middleware.JWTMiddleWare(), publish.PublishVedioHandler)
 	baseGroup.GET("/publish/list/", middleware.JWTMiddleWare(), publish.QueryPublishListHandler)
 
 	//extend 1
-	baseGroup.POST("/favorite/action/", middleware.JWTMiddleWare(), favorite.PostFavorHandler)
+	baseGroup.POST("/favorite/action/", middleware.JWTMiddleWare(), middleware.IdempotencyMiddleWare(), favorite.PostFavorHandler)
 	baseGroup.GET("/favorite/list/", middleware.JWTMiddleWare(), favorite.QueryFavorVideoListHandler)
-	baseGroup.POST("/comment/action/", middleware.JWTMiddleWare(), comment.PostCommentHandler)
+	baseGroup.POST("/comment/action/", middleware.JWTMiddleWare(), middleware.IdempotencyMiddleWare(), comment.PostCommentHandler)
 	baseGroup.GET("/comment/list/", middleware.JWTMiddleWare(), comment.QueryCommentListHandler)
+	baseGroup.GET("/comment/reply/list/", middleware.JWTMiddleWare(), comment.QueryCommentReplyListHandler)
+	baseGroup.POST("/comment/like/action/", middleware.JWTMiddleWare(), middleware.IdempotencyMiddleWare(), comment.PostCommentLikeHandler)
+	// 视频作者管理评论
+	baseGroup.POST("/comment/hide/action/", middleware.JWTMiddleWare(), comment.PostCommentHideHandler)
+	baseGroup.POST("/comment/pin/action/", middleware.JWTMiddleWare(), comment.PostCommentPinHandler)
+	baseGroup.POST("/comment/switch/action/", middleware.JWTMiddleWare(), comment.PostCommentSwitchHandler)
+	// 审核员人工审核评论
+	baseGroup.GET("/comment/review/list/", middleware.JWTMiddleWare(), comment.QueryPendingCommentListHandler)
+	baseGroup.POST("/comment/review/action/", middleware.JWTMiddleWare(), comment.PostCommentReviewHandler)
 
 	//extend 2
-	baseGroup.POST("/relation/action/", middleware.JWTMiddleWare(), follow.PostFollowActionHandler)
+	baseGroup.POST("/relation/action/", middleware.JWTMiddleWare(), middleware.IdempotencyMiddleWare(), follow.PostFollowActionHandler)
 	baseGroup.GET("/relation/follow/list/", middleware.JWTMiddleWare(), follow.QueryFollowListHandler)
 	baseGroup.GET("/relation/follower/list/", middleware.JWTMiddleWare(), follow.QueryFanListHandler)
 
+	// 通知收件箱
+	baseGroup.GET("/notification/list/", middleware.JWTMiddleWare(), notification.QueryNotificationListHandler)
+	baseGroup.POST("/notification/read/", middleware.JWTMiddleWare(), notification.PostNotificationReadHandler)
+
 	return router
 }
@@ -1,7 +1,18 @@
 package main
 
-import "github.com/Doraemonkeys/douyin2/initiate"
+import (
+	"flag"
+
+	"github.com/Doraemonkeys/douyin2/initiate"
+)
+
+var backfillCommentCount = flag.Bool("backfill_comment_count", false, "根据评论表重新计算视频和用户的评论数后退出")
 
 func main() {
+	flag.Parse()
+	if *backfillCommentCount {
+		initiate.BackfillCommentCount()
+		return
+	}
 	initiate.Run()
 }
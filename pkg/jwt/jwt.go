@@ -1,6 +1,11 @@
 package jwt
 
 import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -9,44 +14,211 @@ type Cryptoer interface {
 	Decrypt(string) (string, error)
 }
 
+// KeyIDSeparator separates the key ID prefix from the encrypted token.
+const KeyIDSeparator = "."
+
+// KeyIDHeader is the JWT header carrying the key ID.
+const KeyIDHeader = "kid"
+
+var (
+	ErrKeyRingEmpty       = errors.New("jwt: key ring is empty")
+	ErrCurrentKeyNotFound = errors.New("jwt: current key not found in key ring")
+	ErrDuplicateKeyID     = errors.New("jwt: duplicate key id")
+	ErrInvalidKeyID       = errors.New("jwt: key id must not contain " + KeyIDSeparator)
+	ErrKeyIDMismatch      = errors.New("jwt: key id mismatch")
+	ErrNotAccessToken     = errors.New("jwt: not an access token")
+)
+
+// Key is a signing key and the cryptoer used with it.
+type Key struct {
+	// ID is written to the kid header and the token prefix.
+	// An empty ID means a legacy key: tokens carry neither.
+	ID         string
+	SigningKey []byte
+	// Cryptoer can be nil.
+	Cryptoer Cryptoer
+}
+
+// KeyRing holds the current key used for signing and all keys accepted for verification.
+type KeyRing struct {
+	current Key
+	// keys in verification order, the current key first
+	keys []Key
+}
+
+// NewKeyRing creates a key ring that signs with the key named currentID
+// and verifies against all of keys.
+func NewKeyRing(currentID string, keys ...Key) (*KeyRing, error) {
+	if len(keys) == 0 {
+		return nil, ErrKeyRingEmpty
+	}
+	ring := &KeyRing{keys: make([]Key, 0, len(keys))}
+	seen := make(map[string]bool, len(keys))
+	found := false
+	for _, key := range keys {
+		if strings.Contains(key.ID, KeyIDSeparator) {
+			return nil, ErrInvalidKeyID
+		}
+		if seen[key.ID] {
+			return nil, ErrDuplicateKeyID
+		}
+		seen[key.ID] = true
+		if key.ID == currentID {
+			ring.current = key
+			found = true
+		}
+	}
+	if !found {
+		return nil, ErrCurrentKeyNotFound
+	}
+	ring.keys = append(ring.keys, ring.current)
+	for _, key := range keys {
+		if key.ID != currentID {
+			ring.keys = append(ring.keys, key)
+		}
+	}
+	return ring, nil
+}
+
+// CurrentID returns the ID of the key used for signing.
+func (r *KeyRing) CurrentID() string {
+	return r.current.ID
+}
+
+func (r *KeyRing) get(id string) (Key, bool) {
+	for _, key := range r.keys {
+		if key.ID == id {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
 // CryptJWT signing Key
 type CryptJWT struct {
-	signingKey []byte
-	cryptoer   Cryptoer
+	ring atomic.Pointer[KeyRing]
 }
 
 type CustomClaims struct {
 	Username string `json:"username"`
+	// token类型，access或refresh
+	TokenType string `json:"typ,omitempty"`
+	// token的唯一id，用于吊销(RegisteredClaims.ID为用户id)
+	TokenID string `json:"tid,omitempty"`
+	// 签发时用户的token版本，用户的版本增加后之前签发的token全部失效
+	TokenVersion uint `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
+const (
+	// 访问接口使用的token
+	TokenTypeAccess = "access"
+	// 只能用于换取新token的token
+	TokenTypeRefresh = "refresh"
+)
+
 // NewJWT creates a new JWT instance.
 // The signing key is used to sign the token.
 // The cryptoer is used to encrypt and decrypt the token.It can be nil.
 func NewJWT(SigningKey []byte, cryptoer Cryptoer) *CryptJWT {
-	return &CryptJWT{signingKey: SigningKey, cryptoer: cryptoer}
+	ring, _ := NewKeyRing("", Key{SigningKey: SigningKey, Cryptoer: cryptoer})
+	return NewJWTWithKeyRing(ring)
 }
 
-// CreateToken creates a new token
+// NewJWTWithKeyRing creates a new JWT instance using the key ring.
+func NewJWTWithKeyRing(ring *KeyRing) *CryptJWT {
+	j := &CryptJWT{}
+	j.ring.Store(ring)
+	return j
+}
+
+// SetKeyRing replaces the key ring. It is safe to call concurrently with
+// CreateToken and ParseToken.
+func (j *CryptJWT) SetKeyRing(ring *KeyRing) {
+	j.ring.Store(ring)
+}
+
+// KeyRing returns the key ring in use.
+func (j *CryptJWT) KeyRing() *KeyRing {
+	return j.ring.Load()
+}
+
+// CreateToken creates a new token signed with the current key
 func (j *CryptJWT) CreateToken(claims CustomClaims) (string, error) {
+	key := j.ring.Load().current
 	jwTtoken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := jwTtoken.SignedString(j.signingKey)
+	if key.ID != "" {
+		jwTtoken.Header[KeyIDHeader] = key.ID
+	}
+	token, err := jwTtoken.SignedString(key.SigningKey)
 	if err != nil {
 		return "", err
 	}
-	if j.cryptoer != nil {
-		return j.cryptoer.Encrypt(token)
+	if key.Cryptoer == nil {
+		return token, nil
+	}
+	token, err = key.Cryptoer.Encrypt(token)
+	if err != nil {
+		return "", err
+	}
+	// 加密后无法读取kid，以前缀标明使用的密钥
+	if key.ID != "" {
+		token = key.ID + KeyIDSeparator + token
 	}
 	return token, nil
 }
 
 // ParseToken parses the token.
+// Tokens prefixed with a key ID are verified with that key only,
+// other tokens are tried against every key in the ring.
 func (j *CryptJWT) ParseToken(tokenString string) (*CustomClaims, error) {
+	ring := j.ring.Load()
+	candidates := ring.keys
+	if id, rest, ok := strings.Cut(tokenString, KeyIDSeparator); ok {
+		if key, exist := ring.get(id); exist && key.ID != "" && key.Cryptoer != nil {
+			candidates = []Key{key}
+			tokenString = rest
+		}
+	}
+	var firstErr error
+	for _, key := range candidates {
+		claims, err := parseWithKey(tokenString, key)
+		if err == nil {
+			return claims, nil
+		}
+		// 签名正确但已过期时优先返回过期错误
+		if firstErr == nil || errors.Is(err, jwt.ErrTokenExpired) {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
 
+// ParseAccessToken parses the token and checks that it is an access token.
+// Tokens without a type were issued before token types existed and carry
+// neither an expiry nor a token id; they are accepted as access tokens
+// until legacyCutoff. A zero legacyCutoff rejects them.
+func (j *CryptJWT) ParseAccessToken(tokenString string, legacyCutoff time.Time) (*CustomClaims, error) {
+	claims, err := j.ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	switch claims.TokenType {
+	case TokenTypeAccess:
+		return claims, nil
+	case "":
+		if time.Now().Before(legacyCutoff) {
+			return claims, nil
+		}
+	}
+	return nil, ErrNotAccessToken
+}
+
+func parseWithKey(tokenString string, key Key) (*CustomClaims, error) {
 	// 解密token
-	if j.cryptoer != nil {
+	if key.Cryptoer != nil {
 		var err error
-		tokenString, err = j.cryptoer.Decrypt(tokenString)
+		tokenString, err = key.Cryptoer.Decrypt(tokenString)
 		if err != nil {
 			return nil, err
 		}
@@ -54,7 +226,11 @@ func (j *CryptJWT) ParseToken(tokenString string) (*CustomClaims, error) {
 
 	// 解析token
 	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (i interface{}, err error) {
-		return j.signingKey, nil
+		// 没有kid的token由旧版本签发，可用任意密钥验证
+		if kid, _ := token.Header[KeyIDHeader].(string); kid != "" && kid != key.ID {
+			return nil, ErrKeyIDMismatch
+		}
+		return key.SigningKey, nil
 	})
 	if err != nil {
 		return nil, err
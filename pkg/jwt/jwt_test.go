@@ -0,0 +1,212 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Doraemonkeys/douyin2/utils"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKey(t *testing.T, id string, signKey string, secretHex string) Key {
+	key := Key{ID: id, SigningKey: []byte(signKey)}
+	if secretHex != "" {
+		cryptoer, err := utils.NewAESCrypt(secretHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key.Cryptoer = cryptoer
+	}
+	return key
+}
+
+func newTestClaims(username string, ttl time.Duration) CustomClaims {
+	claims := CustomClaims{Username: username}
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(ttl))
+	return claims
+}
+
+func mustKeyRing(t *testing.T, currentID string, keys ...Key) *KeyRing {
+	ring, err := NewKeyRing(currentID, keys...)
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	return ring
+}
+
+func TestNewKeyRing(t *testing.T) {
+	k1 := Key{ID: "k1", SigningKey: []byte("a")}
+	k2 := Key{ID: "k2", SigningKey: []byte("b")}
+	tests := []struct {
+		name    string
+		current string
+		keys    []Key
+		wantErr error
+	}{
+		{"ok", "k2", []Key{k1, k2}, nil},
+		{"empty", "k1", nil, ErrKeyRingEmpty},
+		{"current missing", "k3", []Key{k1, k2}, ErrCurrentKeyNotFound},
+		{"duplicate", "k1", []Key{k1, k1}, ErrDuplicateKeyID},
+		{"invalid id", "a.b", []Key{{ID: "a.b"}}, ErrInvalidKeyID},
+	}
+	for _, tt := range tests {
+		ring, err := NewKeyRing(tt.current, tt.keys...)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: NewKeyRing() error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (ring.CurrentID() != tt.current || ring.keys[0].ID != tt.current) {
+			t.Errorf("%s: current key = %v, want %v", tt.name, ring.keys[0].ID, tt.current)
+		}
+	}
+}
+
+func TestCryptJWT_Rotation(t *testing.T) {
+	for _, secret := range []string{"", "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4"} {
+		k1 := newTestKey(t, "k1", "sign-1", secret)
+		k2 := newTestKey(t, "k2", "sign-2", secret)
+		j := NewJWTWithKeyRing(mustKeyRing(t, "k1", k1))
+		oldToken, err := j.CreateToken(newTestClaims("alice", time.Hour))
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+
+		// 轮换：使用k2签发，仍接受k1签发的token
+		j.SetKeyRing(mustKeyRing(t, "k2", k1, k2))
+		newToken, err := j.CreateToken(newTestClaims("bob", time.Hour))
+		if err != nil {
+			t.Fatalf("CreateToken() error = %v", err)
+		}
+		for token, want := range map[string]string{oldToken: "alice", newToken: "bob"} {
+			claims, err := j.ParseToken(token)
+			if err != nil || claims.Username != want {
+				t.Errorf("secret %q: ParseToken() = %v, %v, want %v", secret, claims, err, want)
+			}
+		}
+
+		// 移除k1后，k1签发的token失效
+		j.SetKeyRing(mustKeyRing(t, "k2", k2))
+		if _, err := j.ParseToken(oldToken); err == nil {
+			t.Errorf("secret %q: ParseToken() with removed key succeeded", secret)
+		}
+		if _, err := j.ParseToken(newToken); err != nil {
+			t.Errorf("secret %q: ParseToken() error = %v", secret, err)
+		}
+	}
+}
+
+func TestCryptJWT_LegacyToken(t *testing.T) {
+	secret := "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4"
+	legacy := newTestKey(t, "", "legacy", secret)
+	j := NewJWT(legacy.SigningKey, legacy.Cryptoer)
+	token, err := j.CreateToken(newTestClaims("alice", time.Hour))
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	// 旧密钥加入密钥环并改用AES-GCM加密后，没有kid的AES-CBC旧token仍然有效
+	cryptoer, err := utils.NewVersionedAESCrypt(secret, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := Key{ID: "old", SigningKey: []byte("legacy"), Cryptoer: cryptoer}
+	j.SetKeyRing(mustKeyRing(t, "new", newTestKey(t, "new", "sign-new", "00112233445566778899AABBCCDDEEFF"), old))
+	claims, err := j.ParseToken(token)
+	if err != nil || claims.Username != "alice" {
+		t.Errorf("ParseToken() legacy = %v, %v", claims, err)
+	}
+	j.SetKeyRing(mustKeyRing(t, "old", old))
+	token, err = j.CreateToken(newTestClaims("bob", time.Hour))
+	if err != nil || !strings.HasPrefix(token, "old"+KeyIDSeparator+utils.GCMCipherPrefix) {
+		t.Fatalf("CreateToken() = %q, %v", token, err)
+	}
+	if claims, err := j.ParseToken(token); err != nil || claims.Username != "bob" {
+		t.Errorf("ParseToken() = %v, %v", claims, err)
+	}
+}
+
+func TestCryptJWT_Expired(t *testing.T) {
+	k1 := newTestKey(t, "k1", "sign-1", "")
+	k2 := newTestKey(t, "k2", "sign-2", "")
+	j := NewJWTWithKeyRing(mustKeyRing(t, "k2", k1, k2))
+	j.SetKeyRing(mustKeyRing(t, "k1", k1, k2))
+	token, _ := j.CreateToken(newTestClaims("alice", -time.Minute))
+	j.SetKeyRing(mustKeyRing(t, "k2", k1, k2))
+	if _, err := j.ParseToken(token); !errors.Is(err, jwt.ErrTokenExpired) {
+		t.Errorf("ParseToken() error = %v, want %v", err, jwt.ErrTokenExpired)
+	}
+}
+
+func TestCryptJWT_ParseAccessToken(t *testing.T) {
+	j := NewJWTWithKeyRing(mustKeyRing(t, "k1", newTestKey(t, "k1", "sign-1", "")))
+	newToken := func(tokenType string) string {
+		// 旧版本签发的token没有类型、id和有效期
+		claims := CustomClaims{Username: "alice", TokenType: tokenType}
+		token, err := j.CreateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name      string
+		tokenType string
+		cutoff    time.Time
+		wantErr   bool
+	}{
+		{"access", TokenTypeAccess, time.Time{}, false},
+		{"refresh", TokenTypeRefresh, future, true},
+		{"legacy before cutoff", "", future, false},
+		{"legacy after cutoff", "", past, true},
+		{"legacy without cutoff", "", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := j.ParseAccessToken(newToken(tt.tokenType), tt.cutoff)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNotAccessToken) {
+					t.Errorf("ParseAccessToken() error = %v, want %v", err, ErrNotAccessToken)
+				}
+				return
+			}
+			if err != nil || claims.Username != "alice" {
+				t.Errorf("ParseAccessToken() = %v, %v", claims, err)
+			}
+		})
+	}
+}
+
+func TestCryptJWT_ParseAccessToken_CBC(t *testing.T) {
+	const secretHex = "00112233445566778899AABBCCDDEEFF"
+	cbc, err := utils.NewAESCrypt(secretHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧版本使用AES-CBC加密，token没有类型
+	token, err := NewJWT([]byte("legacy"), cbc).CreateToken(CustomClaims{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newJWT := func(acceptCBC bool) *CryptJWT {
+		cryptoer, err := utils.NewVersionedAESCrypt(secretHex, acceptCBC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewJWT([]byte("legacy"), cryptoer)
+	}
+	future := time.Now().Add(time.Hour)
+
+	claims, err := newJWT(true).ParseAccessToken(token, future)
+	if err != nil || claims.Username != "alice" {
+		t.Errorf("ParseAccessToken() accept cbc = %v, %v", claims, err)
+	}
+	if _, err := newJWT(true).ParseAccessToken(token, time.Now().Add(-time.Hour)); !errors.Is(err, ErrNotAccessToken) {
+		t.Errorf("ParseAccessToken() after cutoff error = %v, want %v", err, ErrNotAccessToken)
+	}
+	if _, err := newJWT(false).ParseAccessToken(token, future); !errors.Is(err, utils.ErrUnknownCipherVersion) {
+		t.Errorf("ParseAccessToken() reject cbc error = %v, want %v", err, utils.ErrUnknownCipherVersion)
+	}
+}
@@ -47,6 +47,8 @@ type LogConfig struct {
 	LogLevel string
 	//时区
 	TimeLocation *time.Location
+	//需要脱敏的字段名(不区分大小写)，为空时使用DefaultRedactKeys
+	RedactKeys []string
 	//在每条log末尾添加key-value
 	key string
 	//在每条log末尾添加key-value
@@ -407,7 +409,8 @@ func initlLog(logger *logrus.Logger, config LogConfig) error {
 	hook.WriterLock = &sync.RWMutex{}
 	hook.LogConfig = config
 
-	//添加hook
+	//添加hook，脱敏需要在写入文件之前
+	logger.AddHook(NewRedactHook(NewRedactor(config.RedactKeys...)))
 	logger.AddHook(hook)
 
 	err := hook.updateNewLogPathAndFile()
@@ -0,0 +1,110 @@
+package log
+
+import (
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 脱敏后的值
+const RedactedValue = "******"
+
+// DefaultRedactKeys 默认需要脱敏的字段名，字段名以它们结尾(如refresh_token)时同样脱敏
+var DefaultRedactKeys = []string{"password", "token"}
+
+// Redactor 隐藏日志中的密码和token等敏感字段
+type Redactor struct {
+	keys []string
+	// key=value，如查询参数和表单
+	pairPattern *regexp.Regexp
+	// "key":"value"，如JSON
+	jsonPattern *regexp.Regexp
+	// Authorization头
+	bearerPattern *regexp.Regexp
+}
+
+// NewRedactor 创建脱敏器，字段名不区分大小写，为空时使用DefaultRedactKeys
+func NewRedactor(keys ...string) *Redactor {
+	if len(keys) == 0 {
+		keys = DefaultRedactKeys
+	}
+	r := &Redactor{}
+	quoted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		r.keys = append(r.keys, strings.ToLower(key))
+		quoted = append(quoted, regexp.QuoteMeta(key))
+	}
+	name := `[\w-]*(?:` + strings.Join(quoted, "|") + `)`
+	r.pairPattern = regexp.MustCompile(`(?i)\b(` + name + `=)[^&\s"']*`)
+	r.jsonPattern = regexp.MustCompile(`(?i)("` + name + `"\s*:\s*")(?:\\.|[^"\\])*"`)
+	r.bearerPattern = regexp.MustCompile(`(?i)(\bbearer\s+)[^\s"']+`)
+	return r
+}
+
+// IsSensitiveKey 字段名是否需要脱敏
+func (r *Redactor) IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range r.keys {
+		if strings.HasSuffix(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString 隐藏字符串中敏感字段的值
+func (r *Redactor) RedactString(s string) string {
+	s = r.pairPattern.ReplaceAllString(s, "${1}"+RedactedValue)
+	s = r.jsonPattern.ReplaceAllString(s, `${1}`+RedactedValue+`"`)
+	s = r.bearerPattern.ReplaceAllString(s, "${1}"+RedactedValue)
+	return s
+}
+
+// RedactHook 在日志输出前隐藏消息和字段中的敏感信息，需要在其他输出日志的hook之前添加
+type RedactHook struct {
+	redactor *Redactor
+}
+
+func NewRedactHook(redactor *Redactor) *RedactHook {
+	return &RedactHook{redactor: redactor}
+}
+
+func (hook *RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *RedactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = hook.redactor.RedactString(entry.Message)
+	for key, value := range entry.Data {
+		if hook.redactor.IsSensitiveKey(key) {
+			entry.Data[key] = RedactedValue
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = hook.redactor.RedactString(v)
+		case error:
+			entry.Data[key] = hook.redactor.RedactString(v.Error())
+		}
+	}
+	return nil
+}
+
+type redactWriter struct {
+	w        io.Writer
+	redactor *Redactor
+}
+
+// NewRedactWriter 返回隐藏敏感信息后再写入w的Writer，每次Write应为完整的一条或多条日志
+func NewRedactWriter(w io.Writer, redactor *Redactor) io.Writer {
+	return &redactWriter{w: w, redactor: redactor}
+}
+
+func (rw *redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, rw.redactor.RedactString(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,80 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRedactor_RedactString(t *testing.T) {
+	r := NewRedactor()
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"query", "/douyin/user/login/?username=alice&password=abc123", "/douyin/user/login/?username=alice&password=******"},
+		{"token query", "GET /douyin/feed?token=v2:abc.def&latest_time=1", "GET /douyin/feed?token=******&latest_time=1"},
+		{"refresh token", "refresh_token=xyz", "refresh_token=******"},
+		{"case insensitive", "Password=abc", "Password=******"},
+		{"json", `{"username":"alice","password":"a\"b c"}`, `{"username":"alice","password":"******"}`},
+		{"json spaces", `{"token" : "abc"}`, `{"token" : "******"}`},
+		{"bearer", "Authorization: Bearer k1.abc==", "Authorization: Bearer ******"},
+		{"other keys", "token_type=access&expires_in=7200", "token_type=access&expires_in=7200"},
+		{"no secret", "用户名不存在", "用户名不存在"},
+	}
+	for _, tt := range tests {
+		if got := r.RedactString(tt.in); got != tt.want {
+			t.Errorf("%s: RedactString(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactor_CustomKeys(t *testing.T) {
+	r := NewRedactor("secret")
+	if got := r.RedactString("secret=a&password=b"); got != "secret=******&password=b" {
+		t.Errorf("RedactString() = %q", got)
+	}
+	if !r.IsSensitiveKey("client_SECRET") || r.IsSensitiveKey("password") {
+		t.Errorf("IsSensitiveKey() wrong for custom keys")
+	}
+}
+
+func TestRedactHook(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(NewRedactHook(NewRedactor()))
+	logger.WithFields(logrus.Fields{
+		"password":      "p1",
+		"refresh_token": "t1",
+		"url":           "/login?password=p2",
+		"count":         3,
+	}).WithError(errors.New("bad token=t2")).Info("login password=p3")
+	out := buf.String()
+	for _, secret := range []string{"p1", "t1", "p2", "t2", "p3"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"count":3`) {
+		t.Errorf("log lost other fields: %s", out)
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRedactWriter(&buf, NewRedactor())
+	line := "[GIN] POST /douyin/user/login/?username=a&password=secret\n"
+	n, err := w.Write([]byte(line))
+	if err != nil || n != len(line) {
+		t.Fatalf("Write() = %d, %v, want %d", n, err, len(line))
+	}
+	if want := "[GIN] POST /douyin/user/login/?username=a&password=******\n"; buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
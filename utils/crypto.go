@@ -1,10 +1,15 @@
 package utils
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
 	"strconv"
+	"strings"
 
-	"github.com/wumansgy/goEncrypt/aes"
+	goaes "github.com/wumansgy/goEncrypt/aes"
 )
 
 type CbcAESCrypt struct {
@@ -24,17 +29,117 @@ func (a *CbcAESCrypt) Encrypt(plainText string) (string, error) {
 	if plainText == "" {
 		return "", errors.New("plainText is empty")
 	}
-	return aes.AesCbcEncryptHex([]byte(plainText), a.secretKey, nil)
+	return goaes.AesCbcEncryptHex([]byte(plainText), a.secretKey, nil)
 }
 
 func (a *CbcAESCrypt) Decrypt(cipherTextHex string) (string, error) {
 	if cipherTextHex == "" {
 		return "", errors.New("cipherTextHex is empty")
 	}
-	plaintext, err := aes.AesCbcDecryptByHex(cipherTextHex, a.secretKey, nil)
+	plaintext, err := goaes.AesCbcDecryptByHex(cipherTextHex, a.secretKey, nil)
 	return string(plaintext), err
 }
 
+var (
+	ErrCipherTextTooShort   = errors.New("ciphertext too short")
+	ErrUnknownCipherVersion = errors.New("unknown ciphertext version")
+)
+
+// GcmAESCrypt AES-GCM认证加密，每次加密使用随机nonce，密文被篡改时解密失败
+type GcmAESCrypt struct {
+	aead cipher.AEAD
+}
+
+// NewGCMCrypt 创建AES-GCM加密器, HexSecretKey为16进制字符串，长度为16、24或32字节
+func NewGCMCrypt(HexSecretKey string) (*GcmAESCrypt, error) {
+	secretKey, err := HexStrToBytes(HexSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(secretKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &GcmAESCrypt{aead: aead}, nil
+}
+
+// 加密为base64url字符串(nonce+密文)
+func (g *GcmAESCrypt) Encrypt(plainText string) (string, error) {
+	if plainText == "" {
+		return "", errors.New("plainText is empty")
+	}
+	nonce := make([]byte, g.aead.NonceSize(), g.aead.NonceSize()+len(plainText)+g.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(g.aead.Seal(nonce, nonce, []byte(plainText), nil)), nil
+}
+
+func (g *GcmAESCrypt) Decrypt(cipherText string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < g.aead.NonceSize()+g.aead.Overhead() {
+		return "", ErrCipherTextTooShort
+	}
+	nonce, sealed := data[:g.aead.NonceSize()], data[g.aead.NonceSize():]
+	plaintext, err := g.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// AES-GCM密文的版本前缀，没有前缀的为旧版本的AES-CBC密文
+const GCMCipherPrefix = "v2:"
+
+// VersionedAESCrypt 使用AES-GCM加密并加上版本前缀，
+// 解密时根据前缀选择算法，迁移期间仍可解密旧版本的AES-CBC密文
+type VersionedAESCrypt struct {
+	gcm *GcmAESCrypt
+	// 为nil时不接受AES-CBC密文
+	cbc *CbcAESCrypt
+}
+
+// NewVersionedAESCrypt 创建带版本前缀的加密器，acceptCBC为true时可解密旧版本的AES-CBC密文(使用同一密钥)
+func NewVersionedAESCrypt(HexSecretKey string, acceptCBC bool) (*VersionedAESCrypt, error) {
+	gcm, err := NewGCMCrypt(HexSecretKey)
+	if err != nil {
+		return nil, err
+	}
+	v := &VersionedAESCrypt{gcm: gcm}
+	if acceptCBC {
+		v.cbc, err = NewAESCrypt(HexSecretKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (v *VersionedAESCrypt) Encrypt(plainText string) (string, error) {
+	cipherText, err := v.gcm.Encrypt(plainText)
+	if err != nil {
+		return "", err
+	}
+	return GCMCipherPrefix + cipherText, nil
+}
+
+func (v *VersionedAESCrypt) Decrypt(cipherText string) (string, error) {
+	if rest, ok := strings.CutPrefix(cipherText, GCMCipherPrefix); ok {
+		return v.gcm.Decrypt(rest)
+	}
+	if v.cbc == nil {
+		return "", ErrUnknownCipherVersion
+	}
+	return v.cbc.Decrypt(cipherText)
+}
+
 // HexStrToBytes convert hex string to bytes
 func HexStrToBytes(hexStr string) ([]byte, error) {
 	if len(hexStr)%2 != 0 {
@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"encoding/base64"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +45,92 @@ func TestHexStrToBytes(t *testing.T) {
 		})
 	}
 }
+
+const testSecretHex = "A1B2C3D4E5F6A1B2C3D4E5F6A1B2C3D4"
+
+func TestGcmAESCrypt(t *testing.T) {
+	g, err := NewGCMCrypt(testSecretHex)
+	if err != nil {
+		t.Fatalf("NewGCMCrypt() error = %v", err)
+	}
+	c1, err := g.Encrypt("hello")
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	c2, _ := g.Encrypt("hello")
+	if c1 == c2 {
+		t.Errorf("Encrypt() returned identical ciphertexts for identical plaintexts")
+	}
+	for _, c := range []string{c1, c2} {
+		if got, err := g.Decrypt(c); err != nil || got != "hello" {
+			t.Errorf("Decrypt() = %q, %v, want hello", got, err)
+		}
+	}
+	if _, err := NewGCMCrypt("1234"); err == nil {
+		t.Errorf("NewGCMCrypt() with short key succeeded")
+	}
+}
+
+func TestGcmAESCrypt_Tamper(t *testing.T) {
+	g, _ := NewGCMCrypt(testSecretHex)
+	other, _ := NewGCMCrypt("00112233445566778899AABBCCDDEEFF")
+	cipherText, _ := g.Encrypt(`{"username":"alice"}`)
+	data, _ := base64.RawURLEncoding.DecodeString(cipherText)
+	encode := base64.RawURLEncoding.EncodeToString
+
+	flip := func(i int) string {
+		b := append([]byte(nil), data...)
+		b[i] ^= 0x01
+		return encode(b)
+	}
+	tests := map[string]string{
+		"nonce":     flip(0),
+		"body":      flip(g.aead.NonceSize()),
+		"tag":       flip(len(data) - 1),
+		"truncated": encode(data[:len(data)-1]),
+		"too short": encode(data[:g.aead.NonceSize()]),
+		"appended":  encode(append(append([]byte(nil), data...), 0)),
+		"not b64":   cipherText + "!",
+		"empty":     "",
+	}
+	for name, c := range tests {
+		if got, err := g.Decrypt(c); err == nil {
+			t.Errorf("%s: Decrypt() = %q, want error", name, got)
+		}
+	}
+	if got, err := other.Decrypt(cipherText); err == nil {
+		t.Errorf("Decrypt() with wrong key = %q, want error", got)
+	}
+}
+
+func TestVersionedAESCrypt(t *testing.T) {
+	cbc, _ := NewAESCrypt(testSecretHex)
+	legacy, _ := cbc.Encrypt("legacy")
+
+	v, err := NewVersionedAESCrypt(testSecretHex, true)
+	if err != nil {
+		t.Fatalf("NewVersionedAESCrypt() error = %v", err)
+	}
+	c, _ := v.Encrypt("new")
+	if !strings.HasPrefix(c, GCMCipherPrefix) {
+		t.Errorf("Encrypt() = %q, want prefix %q", c, GCMCipherPrefix)
+	}
+	if got, err := v.Decrypt(c); err != nil || got != "new" {
+		t.Errorf("Decrypt() = %q, %v, want new", got, err)
+	}
+	if got, err := v.Decrypt(legacy); err != nil || got != "legacy" {
+		t.Errorf("Decrypt() legacy = %q, %v, want legacy", got, err)
+	}
+	// 去掉版本前缀的GCM密文不能被当作CBC密文解密
+	if _, err := v.Decrypt(strings.TrimPrefix(c, GCMCipherPrefix)); err == nil {
+		t.Errorf("Decrypt() without prefix succeeded")
+	}
+
+	strict, _ := NewVersionedAESCrypt(testSecretHex, false)
+	if _, err := strict.Decrypt(legacy); !errors.Is(err, ErrUnknownCipherVersion) {
+		t.Errorf("Decrypt() legacy without CBC = %v, want %v", err, ErrUnknownCipherVersion)
+	}
+	if got, err := strict.Decrypt(c); err != nil || got != "new" {
+		t.Errorf("Decrypt() = %q, %v, want new", got, err)
+	}
+}
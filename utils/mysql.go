@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL违反唯一索引的错误码
+const mysqlErrDupEntry = 1062
+
+// IsMysqlDuplicateKey err是否为违反名为index的唯一索引的错误。
+// MySQL 8的错误信息中索引名带有表名前缀，如 for key 'users_models.idx_user_email'
+func IsMysqlDuplicateKey(err error, index string) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlErrDupEntry {
+		return false
+	}
+	return strings.HasSuffix(mysqlErr.Message, "'"+index+"'") || strings.HasSuffix(mysqlErr.Message, "."+index+"'")
+}
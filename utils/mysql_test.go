@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsMysqlDuplicateKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"mysql 8", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'users_models.idx_user_email'"}, true},
+		{"mysql 5.7", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'idx_user_email'"}, true},
+		{"wrapped", fmt.Errorf("create user: %w", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@example.com' for key 'idx_user_email'"}), true},
+		{"other index", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice' for key 'users_models.idx_users_models_username'"}, false},
+		{"index name suffix", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a' for key 'old_idx_user_email'"}, false},
+		{"other error", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, false},
+		{"not mysql", errors.New("Error 1062: Duplicate entry 'a' for key 'idx_user_email'"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsMysqlDuplicateKey(tt.err, "idx_user_email"); got != tt.want {
+				t.Errorf("IsMysqlDuplicateKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
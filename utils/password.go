@@ -1,18 +1,30 @@
 package utils
 
 import (
-	"log"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// BcryptHash 对传入字符串进行加密
-func BcryptHash(str string) string {
-	hash, err := bcrypt.GenerateFromPassword([]byte(str), bcrypt.MinCost)
+var (
+	ErrUnknownPasswordHash = errors.New("unknown password hash format")
+	ErrInvalidPasswordHash = errors.New("invalid password hash")
+	ErrInvalidBcryptCost   = errors.New("invalid bcrypt cost")
+)
+
+// BcryptHash 使用默认cost对传入字符串进行加密
+func BcryptHash(str string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(str), bcrypt.DefaultCost)
 	if err != nil {
-		log.Println(err)
+		return "", err
 	}
-	return string(hash)
+	return string(hash), nil
 }
 
 // BcryptMatch 对传入的加密字符串进行比对,str为明文
@@ -20,3 +32,173 @@ func BcryptMatch(hash string, str string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(str))
 	return err == nil
 }
+
+// PasswordHasher 计算和比对密码的hash
+type PasswordHasher interface {
+	// Hash 计算密码的hash，结果中包含算法和参数
+	Hash(password string) (string, error)
+	// NeedsRehash hash使用的算法或参数与当前配置不同时返回true
+	NeedsRehash(hash string) bool
+}
+
+// VerifyPassword 比对密码，根据hash的格式选择算法，支持bcrypt和argon2id
+func VerifyPassword(hash string, password string) (bool, error) {
+	switch {
+	case isBcryptHash(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, argon2idPrefix):
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	default:
+		return false, ErrUnknownPasswordHash
+	}
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// BcryptHasher 使用bcrypt计算密码的hash
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher cost为0时使用默认值
+func NewBcryptHasher(cost int) (*BcryptHasher, error) {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, ErrInvalidBcryptCost
+	}
+	return &BcryptHasher{cost: cost}, nil
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) NeedsRehash(hash string) bool {
+	if !isBcryptHash(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+const argon2idPrefix = "$argon2id$"
+
+// Argon2idParams argon2id的参数
+type Argon2idParams struct {
+	// 迭代次数
+	Time uint32
+	// 内存大小(KiB)
+	Memory uint32
+	// 并行度
+	Threads uint8
+	// 盐的长度(字节)
+	SaltLength uint32
+	// hash的长度(字节)
+	KeyLength uint32
+}
+
+// DefaultArgon2idParams 默认参数，参考OWASP的推荐值
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Time:       2,
+		Memory:     19 * 1024,
+		Threads:    1,
+		SaltLength: 16,
+		KeyLength:  32,
+	}
+}
+
+// Argon2idHasher 使用argon2id计算密码的hash，结果为PHC格式：
+// $argon2id$v=19$m=内存,t=迭代次数,p=并行度$盐$hash
+type Argon2idHasher struct {
+	params Argon2idParams
+}
+
+// NewArgon2idHasher 为0的参数使用默认值
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	def := DefaultArgon2idParams()
+	if params.Time == 0 {
+		params.Time = def.Time
+	}
+	if params.Memory == 0 {
+		params.Memory = def.Memory
+	}
+	if params.Threads == 0 {
+		params.Threads = def.Threads
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = def.SaltLength
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = def.KeyLength
+	}
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	p := h.params
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	p := h.params
+	return params.Time != p.Time || params.Memory != p.Memory || params.Threads != p.Threads ||
+		uint32(len(salt)) != p.SaltLength || uint32(len(key)) != p.KeyLength
+}
+
+// decodeArgon2id 解析PHC格式的argon2id hash
+func decodeArgon2id(hash string) (params Argon2idParams, salt []byte, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads); err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	if params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooShort         = errors.New("password too short")
+	ErrPasswordTooLong          = errors.New("password too long")
+	ErrPasswordTooSimple        = errors.New("password does not contain enough character classes")
+	ErrPasswordCommon           = errors.New("password is too common")
+	ErrPasswordContainsUsername = errors.New("password contains the username")
+)
+
+// bcrypt只使用密码的前72个字节
+const maxPasswordBytes = 72
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy 密码策略
+type PasswordPolicy struct {
+	// 最少字符数
+	MinLength int
+	// 最多字符数，不超过72字节
+	MaxLength int
+	// 至少包含几类字符：小写字母、大写字母、数字、其他符号
+	MinCharClasses int
+	// 常见密码，比较时不区分大小写
+	blocklist map[string]struct{}
+}
+
+// NewPasswordPolicy 创建密码策略，内置常见密码黑名单
+func NewPasswordPolicy(minLength, maxLength, minCharClasses int) *PasswordPolicy {
+	p := &PasswordPolicy{
+		MinLength:      minLength,
+		MaxLength:      maxLength,
+		MinCharClasses: minCharClasses,
+		blocklist:      make(map[string]struct{}),
+	}
+	p.LoadBlocklist(strings.NewReader(commonPasswords))
+	return p
+}
+
+// LoadBlocklist 加载常见密码，每行一个，忽略空行
+func (p *PasswordPolicy) LoadBlocklist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p.blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Check 检查密码是否符合策略
+func (p *PasswordPolicy) Check(password string, username string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	if (p.MaxLength > 0 && length > p.MaxLength) || len(password) > maxPasswordBytes {
+		return ErrPasswordTooLong
+	}
+	if countCharClasses(password) < p.MinCharClasses {
+		return ErrPasswordTooSimple
+	}
+	lower := strings.ToLower(password)
+	if _, ok := p.blocklist[lower]; ok {
+		return ErrPasswordCommon
+	}
+	if username != "" && strings.Contains(lower, strings.ToLower(username)) {
+		return ErrPasswordContainsUsername
+	}
+	return nil
+}
+
+// 密码包含的字符种类数
+func countCharClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
@@ -1,6 +1,12 @@
 package utils
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
 
 func TestBcryptHash(t *testing.T) {
 	type args struct {
@@ -16,10 +22,138 @@ func TestBcryptHash(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hash := BcryptHash(tt.args.str)
+			hash, err := BcryptHash(tt.args.str)
+			if err != nil {
+				t.Fatalf("BcryptHash() error = %v", err)
+			}
 			if !BcryptMatch(hash, tt.args.str) {
 				t.Errorf("BcryptHash() = %v, Match() = false", hash)
 			}
+			if cost, _ := bcrypt.Cost([]byte(hash)); cost != bcrypt.DefaultCost {
+				t.Errorf("BcryptHash() cost = %d, want %d", cost, bcrypt.DefaultCost)
+			}
+		})
+	}
+}
+
+// 测试中使用较小的参数
+var testArgon2idParams = Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestPasswordHasher(t *testing.T) {
+	bcryptHasher, err := NewBcryptHasher(bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashers := map[string]PasswordHasher{
+		"bcrypt":   bcryptHasher,
+		"argon2id": NewArgon2idHasher(testArgon2idParams),
+	}
+	for name, hasher := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := hasher.Hash("Q3a4s5d6!")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := VerifyPassword(hash, "Q3a4s5d6!"); !ok || err != nil {
+				t.Errorf("VerifyPassword() = %v, %v, want true", ok, err)
+			}
+			if ok, err := VerifyPassword(hash, "q3a4s5d6!"); ok || err != nil {
+				t.Errorf("VerifyPassword() wrong password = %v, %v, want false", ok, err)
+			}
+			if hasher.NeedsRehash(hash) {
+				t.Errorf("NeedsRehash() = true for a fresh hash")
+			}
+			// 相同密码的hash不同
+			if other, _ := hasher.Hash("Q3a4s5d6!"); other == hash {
+				t.Errorf("Hash() returned the same hash twice")
+			}
 		})
 	}
 }
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	oldBcrypt, _ := NewBcryptHasher(bcrypt.MinCost)
+	newBcrypt, _ := NewBcryptHasher(bcrypt.MinCost + 1)
+	argon := NewArgon2idHasher(testArgon2idParams)
+	stronger := testArgon2idParams
+	stronger.Time = 2
+	strongerArgon := NewArgon2idHasher(stronger)
+
+	bcryptHash, _ := oldBcrypt.Hash("password")
+	argonHash, _ := argon.Hash("password")
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"bcrypt same cost", oldBcrypt, bcryptHash, false},
+		{"bcrypt higher cost", newBcrypt, bcryptHash, true},
+		{"bcrypt to argon2id", argon, bcryptHash, true},
+		{"argon2id to bcrypt", oldBcrypt, argonHash, true},
+		{"argon2id same params", NewArgon2idHasher(testArgon2idParams), argonHash, false},
+		{"argon2id stronger params", strongerArgon, argonHash, true},
+		{"unknown", argon, "plain", true},
+	}
+	for _, tt := range tests {
+		if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyPassword_Invalid(t *testing.T) {
+	if _, err := VerifyPassword("plain", "plain"); !errors.Is(err, ErrUnknownPasswordHash) {
+		t.Errorf("VerifyPassword() unknown = %v, want ErrUnknownPasswordHash", err)
+	}
+	argonHash, _ := NewArgon2idHasher(testArgon2idParams).Hash("password")
+	invalid := []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+		"$argon2id$v=18" + argonHash[len("$argon2id$v=19"):],
+		strings.Replace(argonHash, "t=1", "t=x", 1),
+		strings.Replace(argonHash, "t=1", "t=0", 1),
+		argonHash + "!",
+	}
+	for _, hash := range invalid {
+		if _, err := VerifyPassword(hash, "password"); !errors.Is(err, ErrInvalidPasswordHash) {
+			t.Errorf("VerifyPassword(%q) = %v, want ErrInvalidPasswordHash", hash, err)
+		}
+	}
+}
+
+func TestNewBcryptHasher(t *testing.T) {
+	if h, err := NewBcryptHasher(0); err != nil || h.cost != bcrypt.DefaultCost {
+		t.Errorf("NewBcryptHasher(0) = %v, %v, want default cost", h, err)
+	}
+	for _, cost := range []int{bcrypt.MinCost - 1, bcrypt.MaxCost + 1} {
+		if _, err := NewBcryptHasher(cost); !errors.Is(err, ErrInvalidBcryptCost) {
+			t.Errorf("NewBcryptHasher(%d) error = %v, want ErrInvalidBcryptCost", cost, err)
+		}
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := NewPasswordPolicy(8, 64, 3)
+	policy.LoadBlocklist(strings.NewReader("Summer2023!\n\n"))
+	tests := []struct {
+		password string
+		username string
+		want     error
+	}{
+		{"Abc123!x", "", nil},
+		{"密码Abc123", "", nil},
+		{"Ab1!", "", ErrPasswordTooShort},
+		{strings.Repeat("Ab1", 22), "", ErrPasswordTooLong},
+		{strings.Repeat("密", 25), "", ErrPasswordTooLong},
+		{"abcdefgh", "", ErrPasswordTooSimple},
+		{"abcd1234", "", ErrPasswordTooSimple},
+		{"P@ssw0rd", "", ErrPasswordCommon},
+		{"summer2023!", "", ErrPasswordCommon},
+		{"xAlice123!", "alice", ErrPasswordContainsUsername},
+	}
+	for _, tt := range tests {
+		if err := policy.Check(tt.password, tt.username); err != tt.want {
+			t.Errorf("Check(%q, %q) = %v, want %v", tt.password, tt.username, err, tt.want)
+		}
+	}
+}